@@ -1,63 +1,276 @@
 package app
 
 import (
+	"context"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/muchlist/sagasql/config"
 	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/handler"
 	"github.com/muchlist/sagasql/middle"
+	"github.com/muchlist/sagasql/utils/mcrypt"
 	"github.com/muchlist/sagasql/utils/mjwt"
-	"log"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmail"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/moidc"
+	"github.com/muchlist/sagasql/utils/mpassword"
+	"github.com/muchlist/sagasql/utils/mtrace"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownGracePeriod waktu tunggu setelah readiness dilaporkan not-ready
+// agar orchestrator sempat berhenti mengirim trafik sebelum server dimatikan
+const shutdownGracePeriod = 5 * time.Second
+
+// metricsAddrKey env untuk menjalankan /metrics pada alamat terpisah (contoh :9100),
+// jika kosong /metrics dipasang di server utama dan memerlukan role admin
+const metricsAddrKey = "METRICS_ADDR"
+
+const proxyHeaderKey = "PROXY_HEADER"
+
+// appEnvKey env lingkungan aplikasi, rincian error internal hanya dikirim ke client
+// dan driver email log hanya dapat digunakan jika bernilai development
+const (
+	appEnvKey     = "APP_ENV"
+	appEnvDevelop = "development"
 )
 
 // RunApp menjalankan framework fiber
 func RunApp() {
+	ctx := context.Background()
+
+	// Inisiasi level log
+	logLevel.Set(mlog.ParseLevel(os.Getenv(mlog.LogLevelKey)))
+
+	// Inisiasi tracing
+	shutdownTracer, err := mtrace.Init(ctx)
+	if err != nil {
+		fatal("tracing tidak dapat dijalankan", err)
+	}
+	defer func() {
+		if err := shutdownTracer(ctx); err != nil {
+			logger.Error(ctx, "gagal mengirim sisa span", mlog.Err(err))
+		}
+	}()
 
 	// Inisiasi database pool
-	dbPool := db.InitDB()
+	dbPool, err := db.InitDB(ctx)
+	if err != nil {
+		fatal("tidak dapat terhubung ke database", err)
+	}
 	defer dbPool.Close()
+	logger.Info(ctx, "database terhubung")
+
+	// Menjalankan migrasi database
+	if err := db.Migrate(ctx, dbPool); err != nil {
+		fatal("migrasi database gagal", err)
+	}
+
+	// Mendaftarkan statistik pool database ke metric
+	mmetric.RegisterPool(dbPool)
 
 	// Inisiasi fiber
-	app := fiber.New()
+	// PROXY_HEADER (contoh X-Forwarded-For) diisi jika aplikasi berada di belakang load balancer
+	// agar c.IP() yang digunakan pembatasan login adalah ip client, bukan ip load balancer.
+	// error yang dikembalikan handler ditulis oleh middle.ErrorHandler dengan envelope error standar
+	development := os.Getenv(appEnvKey) == appEnvDevelop
+	errorHandler := middle.ErrorHandler(logger, development)
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		ProxyHeader:           os.Getenv(proxyHeaderKey),
+		ErrorHandler:          errorHandler,
+	})
 
 	// Inisiasi jwt
-	mjwt.Init()
+	if err := mjwt.Init(tokenPolicy.MaxLifetime()); err != nil {
+		fatal("jwt tidak dapat diinisiasi", err)
+	}
+	if err := jwtKeyService.Start(ctx); err != nil {
+		fatal("kunci jwt tidak dapat dimuat", err)
+	}
+	if err := mfaService.SealLegacySecrets(ctx); err != nil {
+		fatal("secret 2FA tidak dapat dienkripsi", err)
+	}
+	tokenRevocationService.StartPurge(ctx)
+	middle.SetRevocationChecker(tokenRevocationService)
+	middle.SetPermissionResolver(rbacService)
+	middle.SetAPIKeyAuthenticator(apiKeyService)
+	middle.SetOrgRoleResolver(orgService)
+
+	// Inisiasi hasher password
+	if err := mcrypt.Init(); err != nil {
+		fatal("hasher password tidak dapat diinisiasi", err)
+	}
+
+	// policy password mengikuti batas panjang hasher (bcrypt hanya memproses 72 byte pertama)
+	if maxBytes := mcrypt.MaxPasswordBytes(); maxBytes > 0 && (passwordPolicy.MaxBytes == 0 || passwordPolicy.MaxBytes > maxBytes) {
+		passwordPolicy.MaxBytes = maxBytes
+	}
+	mpassword.SetPolicy(passwordPolicy)
+
+	// Inisiasi blocklist password, daftar lengkap dimuat dari PASSWORD_BLOCKLIST_FILE
+	if err := mpassword.Init(); err != nil {
+		fatal("blocklist password tidak dapat dimuat", err)
+	}
+	logger.Info(ctx, "blocklist password dimuat", mlog.Int("entries", mpassword.BlocklistSize()))
+
+	// Inisiasi pengirim email
+	if err := mmail.Init(development, logger); err != nil {
+		fatal("mailer tidak dapat diinisiasi", err)
+	}
+
+	// Inisiasi konfigurasi login OIDC, nonaktif jika OIDC_ISSUER kosong
+	if err := moidc.Init(); err != nil {
+		fatal("konfigurasi oidc tidak valid", err)
+	}
 
 	// memasang middleware
-	app.Use(logger.New())
+	app.Use(middle.RequestID())
+	app.Use(middle.Recover(logger))
+	app.Use(middle.Language())
+	app.Use(middle.Tracing())
+	app.Use(middle.AccessLog(logger))
+	app.Use(middle.Metrics())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Content-Type, Accept, Authorization",
+		AllowOrigins:  "*",
+		AllowHeaders:  "Content-Type, Accept, Authorization, X-API-Key, X-Request-ID, traceparent, tracestate",
+		ExposeHeaders: "X-Request-ID, traceparent, tracestate",
 	}))
 
 	// file static gambar
 	app.Static("/image", "./static/image")
 
+	// public key jwt
+	app.Get("/.well-known/jwks.json", handler.JWKS)
+
+	// health check untuk orchestrator
+	app.Get("/healthz", healthHandler.Liveness)
+	app.Get("/readyz", healthHandler.Readiness)
+
+	// metric prometheus
+	metricsAddr := os.Getenv(metricsAddrKey)
+	var metricsApp *fiber.App
+	if metricsAddr != "" {
+		metricsApp = fiber.New(fiber.Config{DisableStartupMessage: true, ErrorHandler: errorHandler})
+		metricsApp.Get("/metrics", metricsHandler.Metrics)
+		go func() {
+			if err := metricsApp.Listen(metricsAddr); err != nil {
+				fatal("server metric tidak dapat dijalankan", err)
+			}
+		}()
+	} else {
+		app.Get("/metrics", middle.Require(config.PermMetricsRead), metricsHandler.Metrics)
+	}
+
 	// url mapping
 	api := app.Group("/api/v1")
 
 	//USER
-	api.Get("/users/:username", userHandler.Get)
-	api.Get("/users", userHandler.Find)
+	api.Get("/users/:username", middle.NormalAuth(), userHandler.Get)
+	api.Get("/users", middle.NormalAuth(), userHandler.Find)
 	api.Post("/login", userHandler.Login)
+	api.Post("/login/mfa", userHandler.LoginMFA)
+	api.Post("/login/mfa/enroll", userHandler.EnrollMFALogin)
+	api.Get("/oidc/login", oidcHandler.Login)
+	api.Get("/oidc/callback", oidcHandler.Callback)
 	api.Post("/refresh", userHandler.RefreshToken)
+	api.Post("/password/forgot", userHandler.ForgotPassword)
+	api.Post("/password/reset", userHandler.ResetPassword)
+	api.Get("/email/verify", userHandler.VerifyEmail)
+	api.Post("/email/verify/resend", userHandler.ResendVerification)
+	api.Post("/login/unlock", middle.Require(config.PermLoginUnlock), userHandler.UnlockLogin)
+	api.Post("/logout", middle.NormalAuth(), userHandler.Logout)
 	api.Get("/profile", middle.NormalAuth(), userHandler.GetProfile)
-	api.Post("/register-force", userHandler.Register)                                // <- seharusnya gunakan middleware agar hanya admin yang bisa meregistrasi
-	api.Post("/register", middle.NormalAuth(config.RoleAdmin), userHandler.Register) // <- hanya admin yang bisa meregistrasi
-	api.Put("/users/:username", middle.NormalAuth(config.RoleAdmin), userHandler.Edit)
-	api.Delete("/users/:username", middle.NormalAuth(config.RoleAdmin), userHandler.Delete)
+	api.Post("/profile/password", middle.FreshAuth(), userHandler.ChangePassword)
+	api.Put("/profile/language", middle.NormalAuth(), userHandler.SetLanguage)
+	api.Post("/profile/mfa/enroll", middle.FreshAuth(), mfaHandler.Enroll)
+	api.Post("/profile/mfa/enable", middle.FreshAuth(), mfaHandler.Enable)
+	api.Post("/profile/mfa/disable", middle.FreshAuth(), mfaHandler.Disable)
+	api.Post("/profile/mfa/recovery-codes", middle.FreshAuth(), mfaHandler.RegenerateRecoveryCodes)
+	api.Post("/register-force", userHandler.RegisterFirst)                            // <- hanya untuk user pertama instalasi baru
+	api.Post("/register", middle.Require(config.PermUserWrite), userHandler.Register) // <- hanya yang memiliki permission user:write yang bisa meregistrasi
+	api.Put("/users/:username", middle.Require(config.PermUserWrite), userHandler.Edit)
+	api.Delete("/users/:username", middle.Require(config.PermUserDelete), userHandler.Delete)
+	api.Post("/users/:username/revoke-tokens", middle.Require(config.PermUserRevoke), userHandler.RevokeTokens)
+	api.Post("/users/:username/force-password-reset", middle.Require(config.PermUserWrite), userHandler.ForcePasswordReset)
+	api.Put("/users/:username/roles", middle.Require(config.PermRoleManage), rbacHandler.SetUserRoles)
+	api.Delete("/users/:username/mfa", middle.Require(config.PermUserWrite), mfaHandler.Reset)
+
+	//RBAC
+	api.Get("/roles", middle.Require(config.PermRoleManage), rbacHandler.FindRoles)
+	api.Get("/roles/:name", middle.Require(config.PermRoleManage), rbacHandler.GetRole)
+	api.Post("/roles", middle.Require(config.PermRoleManage), rbacHandler.CreateRole)
+	api.Put("/roles/:name", middle.Require(config.PermRoleManage), rbacHandler.EditRole)
+	api.Delete("/roles/:name", middle.Require(config.PermRoleManage), rbacHandler.DeleteRole)
+	api.Put("/roles/:name/mfa", middle.Require(config.PermRoleManage), rbacHandler.SetRoleMFARequired)
+	api.Get("/permissions", middle.Require(config.PermRoleManage), rbacHandler.FindPermissions)
+
+	//API KEY
+	api.Get("/api-keys", middle.Require(config.PermAPIKeyManage), apiKeyHandler.Find)
+	api.Post("/api-keys", middle.Require(config.PermAPIKeyManage), apiKeyHandler.Create)
+	api.Delete("/api-keys/:id", middle.Require(config.PermAPIKeyManage), apiKeyHandler.Revoke)
+
+	//ORGANIZATION
+	api.Get("/orgs", middle.Require(config.PermOrgManage), orgHandler.Find)
+	api.Post("/orgs", middle.Require(config.PermOrgManage), orgHandler.Create)
+	api.Get("/orgs/mine", middle.NormalAuth(), orgHandler.FindMine)
+	api.Post("/orgs/switch", middle.NormalAuth(), orgHandler.Switch)
+	api.Get("/orgs/members", middle.Require(config.PermOrgMembers), orgHandler.FindMembers)
+	api.Put("/orgs/members/:username", middle.Require(config.PermOrgMembers), orgHandler.SetMember)
+	api.Delete("/orgs/members/:username", middle.Require(config.PermOrgMembers), orgHandler.RemoveMember)
+	api.Get("/orgs/invitations", middle.Require(config.PermOrgMembers), orgHandler.FindInvitations)
+	api.Post("/orgs/invitations", middle.Require(config.PermOrgMembers), orgHandler.Invite)
+	api.Delete("/orgs/invitations/:email", middle.Require(config.PermOrgMembers), orgHandler.CancelInvitation)
+	api.Post("/orgs/invitations/accept", middle.NormalAuth(), orgHandler.AcceptInvitation)
 
 	//PRODUCT
-	api.Get("/products/:id", middle.NormalAuth(), productHandler.Get)
-	api.Get("/products", middle.NormalAuth(), productHandler.Find)
-	api.Post("/products", middle.NormalAuth(), productHandler.Insert)
-	api.Put("/products/:id", middle.NormalAuth(), productHandler.Edit)
-	api.Delete("/products/:id", middle.NormalAuth(), productHandler.Delete)
-	api.Post("/products-image/:id", middle.NormalAuth(), productHandler.UploadImage) // <- upload image multipath
+	api.Get("/products/:id", middle.Require(config.PermProductRead), productHandler.Get)
+	api.Get("/products", middle.Require(config.PermProductRead), productHandler.Find)
+	api.Post("/products", middle.Require(config.PermProductWrite), productHandler.Insert)
+	api.Put("/products/:id", middle.Require(config.PermProductWrite), middle.Authorize(productPolicy, "id"), productHandler.Edit)
+	api.Delete("/products/:id", middle.Require(config.PermProductDelete), middle.Authorize(productPolicy, "id"), productHandler.Delete)
+	api.Post("/products-image/:id", middle.Require(config.PermProductWrite), middle.Authorize(productPolicy, "id"), productHandler.UploadImage) // <- upload image multipath
 
+	// route tidak ditemukan
+	app.Use(middle.NotFound)
+
+	go gracefulShutdown(app, metricsApp)
+
+	logger.Info(ctx, "aplikasi berjalan", mlog.String("addr", ":3500"))
 	if err := app.Listen(":3500"); err != nil {
-		log.Fatalf("Aplikasi tidak dapat dijalankan. Error : %s", err.Error())
+		fatal("aplikasi tidak dapat dijalankan", err)
 		return
 	}
 }
+
+// fatal mencatat error lalu menghentikan aplikasi
+func fatal(msg string, err error) {
+	logger.Error(context.Background(), msg, mlog.Err(err))
+	os.Exit(1)
+}
+
+// gracefulShutdown menunggu sinyal terminate, menandai aplikasi not-ready,
+// lalu mematikan server setelah masa tenggang
+func gracefulShutdown(app *fiber.App, metricsApp *fiber.App) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	ctx := context.Background()
+	logger.Info(ctx, "sinyal terminate diterima, aplikasi akan dimatikan", mlog.Duration("grace_period_ms", shutdownGracePeriod))
+	healthService.MarkShuttingDown()
+	time.Sleep(shutdownGracePeriod)
+
+	if err := app.Shutdown(); err != nil {
+		logger.Error(ctx, "aplikasi gagal dimatikan dengan baik", mlog.Err(err))
+	}
+	if metricsApp != nil {
+		if err := metricsApp.Shutdown(); err != nil {
+			logger.Error(ctx, "server metric gagal dimatikan dengan baik", mlog.Err(err))
+		}
+	}
+}
@@ -6,20 +6,106 @@ import (
 	"github.com/muchlist/sagasql/service"
 	"github.com/muchlist/sagasql/utils/mcrypt"
 	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmail"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/moidc"
+	"github.com/muchlist/sagasql/utils/mpassword"
+	"os"
 )
 
 var (
+	// Logger
+	logLevel = new(mlog.LevelVar)
+	logger   = mlog.New(os.Stdout, logLevel)
+
 	// Utils
 	cryptoUtils = mcrypt.NewCrypto()
 	jwt         = mjwt.NewJwt()
+	mailer      = mmail.NewMailer()
+
+	// umur token per role dan batas sesi, contoh role ADMIN dengan access token lebih pendek :
+	// tokenPolicy.Roles[config.RoleAdmin] = mjwt.Lifetime{AccessFresh: 15 * time.Minute}
+	tokenPolicy = mjwt.DefaultTokenPolicy
+
+	// aturan password baru, contoh mewajibkan 3 jenis karakter :
+	// passwordPolicy.MinClasses = 3
+	passwordPolicy = mpassword.DefaultPolicy
+
+	// JWT Key
+	jwtKeyDao     = dao.NewJWTKeyDao(logger)
+	jwtKeyService = service.NewJWTKeyService(jwtKeyDao, service.JWTKeyRotationPolicy{
+		Interval:         service.DefaultJWTKeyRotationPolicy.Interval,
+		Prepublish:       service.DefaultJWTKeyRotationPolicy.Prepublish,
+		MaxTokenLifetime: tokenPolicy.MaxLifetime(),
+	}, logger)
+
+	// Login Guard
+	loginAttemptDao   = dao.NewLoginAttemptDao(logger)
+	loginGuardService = service.NewLoginGuardService(loginAttemptDao, service.DefaultUsernameGuardPolicy, service.DefaultIPGuardPolicy, logger)
+
+	// Token Revocation
+	tokenRevocationDao     = dao.NewTokenRevocationDao(logger)
+	tokenRevocationService = service.NewTokenRevocationService(tokenRevocationDao, refreshTokenDao, logger)
 
 	// User Domain
-	userDao     = dao.NewUserDao()
-	userService = service.NewUserService(userDao, cryptoUtils, jwt)
-	userHandler = handler.NewUserHandler(userService)
+	userDao         = dao.NewUserDao(logger)
+	refreshTokenDao = dao.NewRefreshTokenDao(logger)
+	userService     = service.NewUserService(userDao, refreshTokenDao, loginGuardService, tokenRevocationService, rbacService, emailVerificationService, mfaService, orgService, cryptoUtils, jwt, tokenPolicy, logger)
+	userHandler     = handler.NewUserHandler(userService, loginGuardService, passwordResetService, emailVerificationService, logger)
+
+	// Password Reset
+	passwordResetDao     = dao.NewPasswordResetDao(logger)
+	passwordResetGuard   = service.NewPasswordResetGuardService(loginAttemptDao, service.DefaultResetEmailGuardPolicy, service.DefaultResetIPGuardPolicy, logger)
+	passwordResetService = service.NewPasswordResetService(passwordResetDao, userDao, passwordResetGuard, tokenRevocationService, cryptoUtils, mailer, logger)
+
+	// Email Verification
+	// DefaultUnverifiedEmailPolicy membatasi akun yang belum verifikasi menjadi read-only,
+	// gunakan service.UnverifiedEmailBlockLogin agar akun tersebut tidak dapat login
+	// kirim ulang tautan verifikasi dibatasi dengan aturan yang sama seperti permintaan reset password
+	emailVerificationGuard   = service.NewEmailVerificationGuardService(loginAttemptDao, service.DefaultResetEmailGuardPolicy, service.DefaultResetIPGuardPolicy, logger)
+	emailVerificationService = service.NewEmailVerificationService(userDao, emailVerificationGuard, mailer, service.DefaultUnverifiedEmailPolicy, logger)
+
+	// MFA
+	// kewajiban 2FA per role diatur melalui PUT /roles/:name/mfa
+	mfaDao     = dao.NewMFADao(logger)
+	mfaService = service.NewMFAService(mfaDao, userDao, rbacService, tokenRevocationService, logger)
+	mfaHandler = handler.NewMFAHandler(mfaService, logger)
+
+	// OIDC
+	// DefaultOIDCPolicy membuat user baru dengan role NORMAL dan tidak menghubungkan user lama berdasarkan email,
+	// isi RoleMapping untuk memetakan group provider ke role
+	oidcDao     = dao.NewOIDCDao(logger)
+	oidcService = service.NewOIDCService(oidcDao, userDao, userService, rbacService, moidc.NewProvider(), cryptoUtils, service.DefaultOIDCPolicy, logger)
+	oidcHandler = handler.NewOIDCHandler(oidcService, logger)
+
+	// API Key
+	apiKeyDao     = dao.NewAPIKeyDao(logger)
+	apiKeyService = service.NewAPIKeyService(apiKeyDao, userDao, rbacService, orgService, logger)
+	apiKeyHandler = handler.NewAPIKeyHandler(apiKeyService, logger)
+
+	// RBAC
+	rbacDao     = dao.NewRBACDao(logger)
+	rbacService = service.NewRBACService(rbacDao, userDao, tokenRevocationService, logger)
+	rbacHandler = handler.NewRBACHandler(rbacService, logger)
+
+	// Organization
+	// user baru tidak otomatis menjadi anggota organisasi, tambahkan melalui PUT /orgs/members/:username
+	orgDao     = dao.NewOrgDao(logger)
+	orgService = service.NewOrgService(orgDao, userDao, rbacService, mailer, logger)
+	orgHandler = handler.NewOrgHandler(orgService, userService, logger)
 
 	// Product Domain
-	productDao     = dao.NewProductDao()
-	productService = service.NewProductService(productDao)
-	productHandler = handler.NewProductHandler(productService)
+	productDao     = dao.NewProductDao(logger)
+	productService = service.NewProductService(productDao, logger)
+	productPolicy  = service.NewProductOwnerPolicy(productDao, rbacService, logger)
+	productHandler = handler.NewProductHandler(productService, logger)
+
+	// Health
+	healthDao     = dao.NewHealthDao()
+	healthService = service.NewHealthService(healthDao, "./static/image/product", logger)
+	healthHandler = handler.NewHealthHandler(healthService)
+
+	// Metrics
+	metricsHandler = handler.NewMetricsHandler(mmetric.Registry)
 )
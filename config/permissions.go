@@ -0,0 +1,20 @@
+package config
+
+// Permission yang dicek oleh middleware middle.Require.
+// permission baru ditambahkan melalui migrasi agar dapat diberikan ke role oleh admin
+const (
+	PermProductRead   = "product:read"
+	PermProductWrite  = "product:write"
+	PermProductDelete = "product:delete"
+	PermProductManage = "product:manage"
+	PermUserWrite     = "user:write"
+	PermUserDelete    = "user:delete"
+	PermUserRevoke    = "user:revoke"
+	PermLoginUnlock   = "login:unlock"
+	PermRoleManage    = "role:manage"
+	PermMetricsRead   = "metrics:read"
+	PermOrderManage   = "order:manage"
+	PermAPIKeyManage  = "apikey:manage"
+	PermOrgManage     = "org:manage"
+	PermOrgMembers    = "org:members"
+)
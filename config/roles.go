@@ -1,10 +1,14 @@
 package config
 
+// role bawaan yang dibuat oleh migrasi, role lain dikelola melalui endpoint /roles
 const (
 	RoleAdmin  = "ADMIN"
 	RoleNormal = "NORMAL"
 )
 
-func GetRolesAvailable() []string {
-	return []string{RoleAdmin, RoleNormal}
-}
+// role organisasi bawaan (org_scoped), hanya berlaku di dalam organisasi tempat role tersebut diberikan.
+// ORG_ADMIN mengelola anggota dan semua product organisasinya, berbeda dengan RoleAdmin yang berlaku global
+const (
+	RoleOrgAdmin  = "ORG_ADMIN"
+	RoleOrgMember = "ORG_MEMBER"
+)
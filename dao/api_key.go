@@ -0,0 +1,124 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+// apiKeyLastUsedInterval last_used_at hanya diperbarui jika sudah lewat interval ini
+// agar api key yang sering dipakai tidak menulis ke database pada setiap request
+const apiKeyLastUsedInterval = 60
+
+func NewAPIKeyDao(log mlog.LoggerAssumer) APIKeyDaoAssumer {
+	return &apiKeyDao{
+		log: log,
+	}
+}
+
+type APIKeyDaoAssumer interface {
+	Insert(ctx context.Context, key dto.APIKey) rest_err.APIError
+	Get(ctx context.Context, id string) (*dto.APIKey, rest_err.APIError)
+	Find(ctx context.Context) ([]dto.APIKey, rest_err.APIError)
+	Revoke(ctx context.Context, id string, revokedAt int64) rest_err.APIError
+	TouchLastUsed(ctx context.Context, id string, usedAt int64) rest_err.APIError
+}
+
+type apiKeyDao struct {
+	log mlog.LoggerAssumer
+}
+
+const apiKeySelect = `
+	SELECT k.id, k.key_hash, k.name, k.username, k.scopes,
+	COALESCE((SELECT array_agg(ur.role ORDER BY ur.role) FROM user_roles ur WHERE ur.username = k.username), '{}'),
+	COALESCE(k.created_by, ''), k.created_at, k.expires_at, k.last_used_at, k.revoked_at, COALESCE(k.org_id, '')
+	FROM api_keys k
+	`
+
+func scanAPIKey(row pgx.Row, key *dto.APIKey) error {
+	return row.Scan(&key.ID, &key.KeyHash, &key.Name, &key.Username, &key.Scopes, &key.OwnerRoles,
+		&key.CreatedBy, &key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.OrgID)
+}
+
+func (a *apiKeyDao) Insert(ctx context.Context, key dto.APIKey) rest_err.APIError {
+	defer mmetric.ObserveQuery("api_key", "Insert", time.Now())
+
+	_, err := db.Conn(ctx).Exec(ctx, `
+	INSERT INTO api_keys (id, key_hash, name, username, scopes, created_by, created_at, expires_at, org_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''));
+	`, key.ID, key.KeyHash, key.Name, dto.UppercaseString(key.Username), key.Scopes, key.CreatedBy, key.CreatedAt, key.ExpiresAt, key.OrgID)
+	if err != nil {
+		return parseQueryError(ctx, a.log, "api_key", "Insert", err)
+	}
+	return nil
+}
+
+// Get mengembalikan nil jika id tidak dikenal
+func (a *apiKeyDao) Get(ctx context.Context, id string) (*dto.APIKey, rest_err.APIError) {
+	defer mmetric.ObserveQuery("api_key", "Get", time.Now())
+
+	var key dto.APIKey
+	err := scanAPIKey(db.Conn(ctx).QueryRow(ctx, apiKeySelect+"WHERE k.id = $1;", id), &key)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, parseQueryError(ctx, a.log, "api_key", "Get", err)
+	}
+	return &key, nil
+}
+
+func (a *apiKeyDao) Find(ctx context.Context) ([]dto.APIKey, rest_err.APIError) {
+	defer mmetric.ObserveQuery("api_key", "Find", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, apiKeySelect+"ORDER BY k.created_at DESC;")
+	if err != nil {
+		return nil, parseQueryError(ctx, a.log, "api_key", "Find", err)
+	}
+	defer rows.Close()
+
+	var keys []dto.APIKey
+	for rows.Next() {
+		key := dto.APIKey{}
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, parseQueryError(ctx, a.log, "api_key", "Find", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, a.log, "api_key", "Find", err)
+	}
+	return keys, nil
+}
+
+// Revoke mencabut api key, api key yang sudah dicabut tidak dapat diaktifkan kembali
+func (a *apiKeyDao) Revoke(ctx context.Context, id string, revokedAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("api_key", "Revoke", time.Now())
+
+	res, err := db.Conn(ctx).Exec(ctx, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1;", id, revokedAt)
+	if err != nil {
+		return parseQueryError(ctx, a.log, "api_key", "Revoke", err)
+	}
+	if res.RowsAffected() != 1 {
+		return rest_err.NewNotFoundError("apikey.not_found", id)
+	}
+	return nil
+}
+
+func (a *apiKeyDao) TouchLastUsed(ctx context.Context, id string, usedAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("api_key", "TouchLastUsed", time.Now())
+
+	_, err := db.Conn(ctx).Exec(ctx, `
+	UPDATE api_keys SET last_used_at = $2
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at <= $2 - $3);
+	`, id, usedAt, apiKeyLastUsedInterval)
+	if err != nil {
+		return parseQueryError(ctx, a.log, "api_key", "TouchLastUsed", err)
+	}
+	return nil
+}
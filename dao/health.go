@@ -0,0 +1,33 @@
+package dao
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/db"
+)
+
+func NewHealthDao() HealthDaoAssumer {
+	return &healthDao{}
+}
+
+type HealthDaoAssumer interface {
+	Ping(ctx context.Context) error
+	PendingMigrations(ctx context.Context) ([]string, error)
+}
+
+type healthDao struct {
+}
+
+// Ping memastikan pool dapat memperoleh koneksi dan database merespon
+func (h *healthDao) Ping(ctx context.Context) error {
+	conn, err := db.DB.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return conn.Conn().Ping(ctx)
+}
+
+// PendingMigrations mengembalikan versi migrasi yang belum diterapkan
+func (h *healthDao) PendingMigrations(ctx context.Context) ([]string, error) {
+	return db.PendingMigrations(ctx, db.DB)
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+// jwtKeyRotationLock id advisory lock agar hanya satu instance yang membuat kunci baru
+const jwtKeyRotationLock = 7301
+
+func NewJWTKeyDao(log mlog.LoggerAssumer) JWTKeyDaoAssumer {
+	return &jwtKeyDao{
+		log: log,
+	}
+}
+
+type JWTKeyDaoAssumer interface {
+	FindUsable(ctx context.Context, now int64) ([]dto.JWTKey, rest_err.APIError)
+	Rotate(ctx context.Context, next dto.JWTKey, currentKID string, retiresAt int64) (bool, rest_err.APIError)
+}
+
+type jwtKeyDao struct {
+	log mlog.LoggerAssumer
+}
+
+// FindUsable mengembalikan kunci yang belum pensiun, urut dari yang paling baru aktif
+func (j *jwtKeyDao) FindUsable(ctx context.Context, now int64) ([]dto.JWTKey, rest_err.APIError) {
+	defer mmetric.ObserveQuery("jwt_key", "FindUsable", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, `
+	SELECT kid, alg, private_key, created_at, activates_at, retires_at 
+	FROM jwt_keys 
+	WHERE retires_at IS NULL OR retires_at > $1 
+	ORDER BY activates_at DESC;
+	`, now)
+	if err != nil {
+		return nil, parseQueryError(ctx, j.log, "jwt_key", "FindUsable", err)
+	}
+	defer rows.Close()
+
+	var keys []dto.JWTKey
+	for rows.Next() {
+		key := dto.JWTKey{}
+		err := rows.Scan(&key.KID, &key.Algorithm, &key.PrivateKey, &key.CreatedAt, &key.ActivatesAt, &key.RetiresAt)
+		if err != nil {
+			return nil, parseQueryError(ctx, j.log, "jwt_key", "FindUsable", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, j.log, "jwt_key", "FindUsable", err)
+	}
+	return keys, nil
+}
+
+// Rotate menyimpan kunci berikutnya dan menjadwalkan pensiun kunci saat ini dalam satu transaksi.
+// currentKID adalah kunci terbaru yang dilihat pemanggil ("" jika belum ada kunci),
+// jika instance lain sudah lebih dulu merotasi hasilnya false dan tidak ada yang diubah
+func (j *jwtKeyDao) Rotate(ctx context.Context, next dto.JWTKey, currentKID string, retiresAt int64) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("jwt_key", "Rotate", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return false, parseQueryError(ctx, j.log, "jwt_key", "Rotate", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1);", jwtKeyRotationLock); err != nil {
+		return false, parseQueryError(ctx, j.log, "jwt_key", "Rotate", err)
+	}
+
+	var latestKID string
+	err = tx.QueryRow(ctx, "SELECT kid FROM jwt_keys ORDER BY activates_at DESC, created_at DESC LIMIT 1;").Scan(&latestKID)
+	if err != nil && err != pgx.ErrNoRows {
+		return false, parseQueryError(ctx, j.log, "jwt_key", "Rotate", err)
+	}
+	if latestKID != currentKID {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO jwt_keys (kid, alg, private_key, created_at, activates_at) 
+	VALUES ($1, $2, $3, $4, $5);
+	`, next.KID, next.Algorithm, next.PrivateKey, next.CreatedAt, next.ActivatesAt)
+	if err != nil {
+		return false, parseQueryError(ctx, j.log, "jwt_key", "Rotate", err)
+	}
+
+	if currentKID != "" {
+		_, err = tx.Exec(ctx, "UPDATE jwt_keys SET retires_at = $2 WHERE kid = $1;", currentKID, retiresAt)
+		if err != nil {
+			return false, parseQueryError(ctx, j.log, "jwt_key", "Rotate", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, parseQueryError(ctx, j.log, "jwt_key", "Rotate", err)
+	}
+	return true, nil
+}
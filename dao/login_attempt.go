@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewLoginAttemptDao(log mlog.LoggerAssumer) LoginAttemptDaoAssumer {
+	return &loginAttemptDao{
+		log: log,
+	}
+}
+
+type LoginAttemptDaoAssumer interface {
+	Get(ctx context.Context, keyType string, key string) (*dto.LoginAttempt, rest_err.APIError)
+	RegisterFailure(ctx context.Context, keyType string, key string, now int64, windowStart int64) (int, rest_err.APIError)
+	Lock(ctx context.Context, keyType string, key string, lockedUntil int64) rest_err.APIError
+	Reset(ctx context.Context, keyType string, key string) rest_err.APIError
+}
+
+type loginAttemptDao struct {
+	log mlog.LoggerAssumer
+}
+
+// Get mengembalikan catatan percobaan login, nil jika belum pernah gagal
+func (l *loginAttemptDao) Get(ctx context.Context, keyType string, key string) (*dto.LoginAttempt, rest_err.APIError) {
+	defer mmetric.ObserveQuery("login_attempt", "Get", time.Now())
+
+	sqlStatement := `
+	SELECT key_type, key, failed_count, last_failed_at, locked_until 
+	FROM login_attempts 
+	WHERE key_type = $1 AND key = $2;
+	`
+	var attempt dto.LoginAttempt
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, keyType, key).Scan(&attempt.KeyType, &attempt.Key, &attempt.FailedCount, &attempt.LastFailedAt, &attempt.LockedUntil)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, parseQueryError(ctx, l.log, "login_attempt", "Get", err)
+	}
+	return &attempt, nil
+}
+
+// RegisterFailure menambah jumlah kegagalan secara atomik dan mengembalikan jumlah terbarunya.
+// jika kegagalan terakhir terjadi sebelum windowStart hitungan dimulai ulang dari 1
+func (l *loginAttemptDao) RegisterFailure(ctx context.Context, keyType string, key string, now int64, windowStart int64) (int, rest_err.APIError) {
+	defer mmetric.ObserveQuery("login_attempt", "RegisterFailure", time.Now())
+
+	sqlStatement := `
+	INSERT INTO login_attempts (key_type, key, failed_count, last_failed_at, locked_until) 
+	VALUES ($1, $2, 1, $3, 0) 
+	ON CONFLICT (key_type, key) DO UPDATE 
+	SET failed_count = CASE WHEN login_attempts.last_failed_at < $4 THEN 1 ELSE login_attempts.failed_count + 1 END, 
+		last_failed_at = $3 
+	RETURNING failed_count;
+	`
+	var failedCount int
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, keyType, key, now, windowStart).Scan(&failedCount)
+	if err != nil {
+		return 0, parseQueryError(ctx, l.log, "login_attempt", "RegisterFailure", err)
+	}
+	return failedCount, nil
+}
+
+// Lock menolak percobaan login berikutnya sampai lockedUntil (unix detik)
+func (l *loginAttemptDao) Lock(ctx context.Context, keyType string, key string, lockedUntil int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("login_attempt", "Lock", time.Now())
+
+	sqlStatement := `
+	UPDATE login_attempts 
+	SET locked_until = $3 
+	WHERE key_type = $1 AND key = $2;
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, keyType, key, lockedUntil)
+	if err != nil {
+		return parseQueryError(ctx, l.log, "login_attempt", "Lock", err)
+	}
+	return nil
+}
+
+// Reset menghapus catatan kegagalan, dipanggil ketika login berhasil atau dibuka oleh admin
+func (l *loginAttemptDao) Reset(ctx context.Context, keyType string, key string) rest_err.APIError {
+	defer mmetric.ObserveQuery("login_attempt", "Reset", time.Now())
+
+	sqlStatement := `
+	DELETE FROM login_attempts 
+	WHERE key_type = $1 AND key = $2;
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, keyType, key)
+	if err != nil {
+		return parseQueryError(ctx, l.log, "login_attempt", "Reset", err)
+	}
+	return nil
+}
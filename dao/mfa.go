@@ -0,0 +1,238 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewMFADao(log mlog.LoggerAssumer) MFADaoAssumer {
+	return &mfaDao{
+		log: log,
+	}
+}
+
+type MFADaoAssumer interface {
+	Get(ctx context.Context, username string) (*dto.UserMFA, rest_err.APIError)
+	StartEnrollment(ctx context.Context, username string, secret string, createdAt int64) rest_err.APIError
+	Enable(ctx context.Context, username string, step int64, enabledAt int64, recoveryCodeHashes []string) (bool, rest_err.APIError)
+	UseStep(ctx context.Context, username string, step int64) (bool, rest_err.APIError)
+	ReplaceRecoveryCodes(ctx context.Context, username string, codeHashes []string, createdAt int64) rest_err.APIError
+	ConsumeRecoveryCode(ctx context.Context, username string, codeHash string, usedAt int64) (bool, rest_err.APIError)
+	Delete(ctx context.Context, username string) rest_err.APIError
+	FindUnsealed(ctx context.Context) ([]dto.UserMFA, rest_err.APIError)
+	ReplaceSecret(ctx context.Context, username string, oldSecret string, newSecret string) rest_err.APIError
+}
+
+type mfaDao struct {
+	log mlog.LoggerAssumer
+}
+
+// Get mengembalikan nil jika user belum pernah memulai enrolment
+func (m *mfaDao) Get(ctx context.Context, username string) (*dto.UserMFA, rest_err.APIError) {
+	defer mmetric.ObserveQuery("mfa", "Get", time.Now())
+
+	var mfa dto.UserMFA
+	err := db.Conn(ctx).QueryRow(ctx, `
+	SELECT username, secret, enabled_at, last_used_step, created_at
+	FROM user_mfa
+	WHERE username = $1;
+	`, dto.UppercaseString(username)).Scan(&mfa.Username, &mfa.Secret, &mfa.EnabledAt, &mfa.LastUsedStep, &mfa.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, parseQueryError(ctx, m.log, "mfa", "Get", err)
+	}
+	return &mfa, nil
+}
+
+// StartEnrollment menyimpan secret baru (sudah terenkripsi, lihat mjwt.SealSecret) yang belum aktif, enrolment sebelumnya yang belum selesai ditimpa.
+// tidak mengubah apapun jika 2FA user sudah aktif
+func (m *mfaDao) StartEnrollment(ctx context.Context, username string, secret string, createdAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("mfa", "StartEnrollment", time.Now())
+
+	_, err := db.Conn(ctx).Exec(ctx, `
+	INSERT INTO user_mfa (username, secret, created_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (username) DO UPDATE
+	SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at, last_used_step = 0
+	WHERE user_mfa.enabled_at IS NULL;
+	`, dto.UppercaseString(username), secret, createdAt)
+	if err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "StartEnrollment", err)
+	}
+	return nil
+}
+
+// Enable mengaktifkan 2FA dan menyimpan recovery code dalam satu transaksi,
+// false jika tidak ada enrolment yang menunggu verifikasi
+func (m *mfaDao) Enable(ctx context.Context, username string, step int64, enabledAt int64, recoveryCodeHashes []string) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("mfa", "Enable", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return false, parseQueryError(ctx, m.log, "mfa", "Enable", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	res, err := tx.Exec(ctx, `
+	UPDATE user_mfa
+	SET enabled_at = $2, last_used_step = $3
+	WHERE username = $1 AND enabled_at IS NULL;
+	`, dto.UppercaseString(username), enabledAt, step)
+	if err != nil {
+		return false, parseQueryError(ctx, m.log, "mfa", "Enable", err)
+	}
+	if res.RowsAffected() != 1 {
+		return false, nil
+	}
+	if err := replaceRecoveryCodes(ctx, tx, dto.UppercaseString(username), recoveryCodeHashes, enabledAt); err != nil {
+		return false, parseQueryError(ctx, m.log, "mfa", "Enable", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, parseQueryError(ctx, m.log, "mfa", "Enable", err)
+	}
+	return true, nil
+}
+
+// UseStep mencatat step TOTP yang dipakai secara atomik, false jika step tersebut
+// (atau step setelahnya) sudah pernah dipakai sehingga kode tidak dapat digunakan ulang
+func (m *mfaDao) UseStep(ctx context.Context, username string, step int64) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("mfa", "UseStep", time.Now())
+
+	res, err := db.Conn(ctx).Exec(ctx, `
+	UPDATE user_mfa
+	SET last_used_step = $2
+	WHERE username = $1 AND enabled_at IS NOT NULL AND last_used_step < $2;
+	`, dto.UppercaseString(username), step)
+	if err != nil {
+		return false, parseQueryError(ctx, m.log, "mfa", "UseStep", err)
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// ReplaceRecoveryCodes mengganti seluruh recovery code user
+func (m *mfaDao) ReplaceRecoveryCodes(ctx context.Context, username string, codeHashes []string, createdAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("mfa", "ReplaceRecoveryCodes", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "ReplaceRecoveryCodes", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := replaceRecoveryCodes(ctx, tx, dto.UppercaseString(username), codeHashes, createdAt); err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "ReplaceRecoveryCodes", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "ReplaceRecoveryCodes", err)
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode menandai recovery code terpakai secara atomik, false jika tidak dikenal atau sudah dipakai
+func (m *mfaDao) ConsumeRecoveryCode(ctx context.Context, username string, codeHash string, usedAt int64) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("mfa", "ConsumeRecoveryCode", time.Now())
+
+	res, err := db.Conn(ctx).Exec(ctx, `
+	UPDATE mfa_recovery_codes
+	SET used_at = $3
+	WHERE username = $1 AND code_hash = $2 AND used_at IS NULL;
+	`, dto.UppercaseString(username), codeHash, usedAt)
+	if err != nil {
+		return false, parseQueryError(ctx, m.log, "mfa", "ConsumeRecoveryCode", err)
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// Delete menonaktifkan 2FA beserta seluruh recovery code user
+func (m *mfaDao) Delete(ctx context.Context, username string) rest_err.APIError {
+	defer mmetric.ObserveQuery("mfa", "Delete", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "Delete", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE username = $1;", dto.UppercaseString(username)); err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "Delete", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM user_mfa WHERE username = $1;", dto.UppercaseString(username)); err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "Delete", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "Delete", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, username dto.UppercaseString, codeHashes []string, createdAt int64) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE username = $1;", username); err != nil {
+		return err
+	}
+	if len(codeHashes) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+	INSERT INTO mfa_recovery_codes (code_hash, username, created_at)
+	SELECT unnest($2::VARCHAR[]), $1, $3;
+	`, username, codeHashes, createdAt)
+	return err
+}
+
+// FindUnsealed mengembalikan secret yang masih tersimpan sebagai base32 tanpa enkripsi (sebelum migrasi 0019)
+func (m *mfaDao) FindUnsealed(ctx context.Context) ([]dto.UserMFA, rest_err.APIError) {
+	defer mmetric.ObserveQuery("mfa", "FindUnsealed", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, `
+	SELECT username, secret, enabled_at, last_used_step, created_at
+	FROM user_mfa
+	WHERE secret ~ '^[A-Z2-7]+=*$';
+	`)
+	if err != nil {
+		return nil, parseQueryError(ctx, m.log, "mfa", "FindUnsealed", err)
+	}
+	defer rows.Close()
+
+	var result []dto.UserMFA
+	for rows.Next() {
+		var mfa dto.UserMFA
+		if err := rows.Scan(&mfa.Username, &mfa.Secret, &mfa.EnabledAt, &mfa.LastUsedStep, &mfa.CreatedAt); err != nil {
+			return nil, parseQueryError(ctx, m.log, "mfa", "FindUnsealed", err)
+		}
+		result = append(result, mfa)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, m.log, "mfa", "FindUnsealed", err)
+	}
+	return result, nil
+}
+
+// ReplaceSecret mengganti secret hanya jika nilainya masih oldSecret, sehingga enrolment baru tidak tertimpa
+func (m *mfaDao) ReplaceSecret(ctx context.Context, username string, oldSecret string, newSecret string) rest_err.APIError {
+	defer mmetric.ObserveQuery("mfa", "ReplaceSecret", time.Now())
+
+	_, err := db.Conn(ctx).Exec(ctx, `
+	UPDATE user_mfa SET secret = $3 WHERE username = $1 AND secret = $2;
+	`, dto.UppercaseString(username), oldSecret, newSecret)
+	if err != nil {
+		return parseQueryError(ctx, m.log, "mfa", "ReplaceSecret", err)
+	}
+	return nil
+}
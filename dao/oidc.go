@@ -0,0 +1,127 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewOIDCDao(log mlog.LoggerAssumer) OIDCDaoAssumer {
+	return &oidcDao{
+		log: log,
+	}
+}
+
+type OIDCDaoAssumer interface {
+	InsertState(ctx context.Context, state dto.OIDCLoginState) rest_err.APIError
+	ConsumeState(ctx context.Context, stateHash string, now int64) (*dto.OIDCLoginState, rest_err.APIError)
+	GetIdentity(ctx context.Context, issuer string, subject string) (*dto.UserIdentity, rest_err.APIError)
+	LinkIdentity(ctx context.Context, identity dto.UserIdentity) rest_err.APIError
+	TouchIdentity(ctx context.Context, issuer string, subject string, email string, loginAt int64) rest_err.APIError
+	UsernameTaken(ctx context.Context, username string) (bool, rest_err.APIError)
+}
+
+type oidcDao struct {
+	log mlog.LoggerAssumer
+}
+
+// InsertState menyimpan state baru dan menghapus state yang sudah kadaluarsa
+func (o *oidcDao) InsertState(ctx context.Context, state dto.OIDCLoginState) rest_err.APIError {
+	defer mmetric.ObserveQuery("oidc", "InsertState", time.Now())
+
+	if _, err := db.Conn(ctx).Exec(ctx, "DELETE FROM oidc_login_states WHERE expires_at <= $1;", state.CreatedAt); err != nil {
+		return parseQueryError(ctx, o.log, "oidc", "InsertState", err)
+	}
+
+	_, err := db.Conn(ctx).Exec(ctx, `
+	INSERT INTO oidc_login_states (state_hash, code_verifier, nonce, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5);
+	`, state.StateHash, state.CodeVerifier, state.Nonce, state.CreatedAt, state.ExpiresAt)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "oidc", "InsertState", err)
+	}
+	return nil
+}
+
+// ConsumeState menandai state terpakai secara atomik, nil jika tidak dikenal, sudah dipakai atau kadaluarsa
+func (o *oidcDao) ConsumeState(ctx context.Context, stateHash string, now int64) (*dto.OIDCLoginState, rest_err.APIError) {
+	defer mmetric.ObserveQuery("oidc", "ConsumeState", time.Now())
+
+	var state dto.OIDCLoginState
+	err := db.Conn(ctx).QueryRow(ctx, `
+	UPDATE oidc_login_states
+	SET used_at = $2
+	WHERE state_hash = $1 AND used_at IS NULL AND expires_at > $2
+	RETURNING state_hash, code_verifier, nonce, created_at, expires_at;
+	`, stateHash, now).Scan(&state.StateHash, &state.CodeVerifier, &state.Nonce, &state.CreatedAt, &state.ExpiresAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, parseQueryError(ctx, o.log, "oidc", "ConsumeState", err)
+	}
+	return &state, nil
+}
+
+// GetIdentity mengembalikan nil jika identitas belum terhubung ke user manapun
+func (o *oidcDao) GetIdentity(ctx context.Context, issuer string, subject string) (*dto.UserIdentity, rest_err.APIError) {
+	defer mmetric.ObserveQuery("oidc", "GetIdentity", time.Now())
+
+	var identity dto.UserIdentity
+	err := db.Conn(ctx).QueryRow(ctx, `
+	SELECT issuer, subject, username, COALESCE(email, ''), created_at, last_login_at
+	FROM user_identities
+	WHERE issuer = $1 AND subject = $2;
+	`, issuer, subject).Scan(&identity.Issuer, &identity.Subject, &identity.Username, &identity.Email, &identity.CreatedAt, &identity.LastLoginAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, parseQueryError(ctx, o.log, "oidc", "GetIdentity", err)
+	}
+	return &identity, nil
+}
+
+func (o *oidcDao) LinkIdentity(ctx context.Context, identity dto.UserIdentity) rest_err.APIError {
+	defer mmetric.ObserveQuery("oidc", "LinkIdentity", time.Now())
+
+	_, err := db.Conn(ctx).Exec(ctx, `
+	INSERT INTO user_identities (issuer, subject, username, email, created_at, last_login_at)
+	VALUES ($1, $2, $3, $4, $5, $5);
+	`, identity.Issuer, identity.Subject, dto.UppercaseString(identity.Username), identity.Email, identity.CreatedAt)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "oidc", "LinkIdentity", err)
+	}
+	return nil
+}
+
+// TouchIdentity mencatat waktu login dan email terbaru dari provider
+func (o *oidcDao) TouchIdentity(ctx context.Context, issuer string, subject string, email string, loginAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("oidc", "TouchIdentity", time.Now())
+
+	_, err := db.Conn(ctx).Exec(ctx, `
+	UPDATE user_identities SET email = $3, last_login_at = $4
+	WHERE issuer = $1 AND subject = $2;
+	`, issuer, subject, email, loginAt)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "oidc", "TouchIdentity", err)
+	}
+	return nil
+}
+
+// UsernameTaken digunakan ketika membuat username untuk user baru dari provider
+func (o *oidcDao) UsernameTaken(ctx context.Context, username string) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("oidc", "UsernameTaken", time.Now())
+
+	var taken bool
+	err := db.Conn(ctx).QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE username = $1);", dto.UppercaseString(username)).Scan(&taken)
+	if err != nil {
+		return false, parseQueryError(ctx, o.log, "oidc", "UsernameTaken", err)
+	}
+	return taken, nil
+}
@@ -0,0 +1,389 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/morg"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewOrgDao(log mlog.LoggerAssumer) OrgDaoAssumer {
+	return &orgDao{
+		log: log,
+	}
+}
+
+type OrgDaoAssumer interface {
+	Insert(ctx context.Context, org dto.Organization, admin string, adminRoles []string) rest_err.APIError
+	Find(ctx context.Context) ([]dto.Organization, rest_err.APIError)
+	FindByUser(ctx context.Context, username string) ([]dto.OrgMembership, rest_err.APIError)
+	MemberRoles(ctx context.Context, orgID string, username string) ([]string, bool, rest_err.APIError)
+	DefaultOrg(ctx context.Context, username string) (string, rest_err.APIError)
+	FindMembers(ctx context.Context, orgID string) ([]dto.OrgMember, rest_err.APIError)
+	SetMember(ctx context.Context, orgID string, username string, roles []string) rest_err.APIError
+	RemoveMember(ctx context.Context, orgID string, username string) rest_err.APIError
+	Invite(ctx context.Context, invitation dto.OrgInvitation) rest_err.APIError
+	FindInvitations(ctx context.Context, orgID string, now int64) ([]dto.OrgInvitation, rest_err.APIError)
+	CancelInvitation(ctx context.Context, orgID string, email string) rest_err.APIError
+	AcceptInvitation(ctx context.Context, tokenHash string, username string, email string, now int64) (string, rest_err.APIError)
+}
+
+type orgDao struct {
+	log mlog.LoggerAssumer
+}
+
+// activeOrg organisasi aktif dari ctx (diisi middleware dari token).
+// query data milik organisasi ditolak jika tidak ada organisasi aktif agar data tidak pernah terbaca lintas organisasi
+func activeOrg(ctx context.Context) (string, rest_err.APIError) {
+	orgID := morg.OrgIDFromContext(ctx)
+	if orgID == "" {
+		return "", rest_err.From(rest_err.ErrOrgRequired)
+	}
+	return orgID, nil
+}
+
+// orgMemberRolesColumn subquery role anggota di dalam organisasi
+const orgMemberRolesColumn = `COALESCE((SELECT array_agg(r.role ORDER BY r.role) FROM org_member_roles r WHERE r.org_id = m.org_id AND r.username = m.username), '{}')`
+
+// Insert membuat organisasi beserta admin pertamanya dalam satu transaksi
+func (o *orgDao) Insert(ctx context.Context, org dto.Organization, admin string, adminRoles []string) rest_err.APIError {
+	defer mmetric.ObserveQuery("org", "Insert", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "org", "Insert", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO organizations (org_id, name, created_at)
+	VALUES ($1, $2, $3);
+	`, org.OrgID, org.Name, org.CreatedAt)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "org", "Insert", err)
+	}
+	if err := replaceOrgMember(ctx, tx, org.OrgID, dto.UppercaseString(admin), adminRoles, org.CreatedAt); err != nil {
+		return parseQueryError(ctx, o.log, "org", "Insert", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, o.log, "org", "Insert", err)
+	}
+	return nil
+}
+
+func (o *orgDao) Find(ctx context.Context) ([]dto.Organization, rest_err.APIError) {
+	defer mmetric.ObserveQuery("org", "Find", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, "SELECT org_id, name, created_at FROM organizations ORDER BY org_id;")
+	if err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "Find", err)
+	}
+	defer rows.Close()
+
+	var orgs []dto.Organization
+	for rows.Next() {
+		org := dto.Organization{}
+		if err := rows.Scan(&org.OrgID, &org.Name, &org.CreatedAt); err != nil {
+			return nil, parseQueryError(ctx, o.log, "org", "Find", err)
+		}
+		orgs = append(orgs, org)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "Find", err)
+	}
+	return orgs, nil
+}
+
+// FindByUser organisasi tempat user menjadi anggota, diurutkan dari yang paling lama
+func (o *orgDao) FindByUser(ctx context.Context, username string) ([]dto.OrgMembership, rest_err.APIError) {
+	defer mmetric.ObserveQuery("org", "FindByUser", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, `
+	SELECT m.org_id, o.name, `+orgMemberRolesColumn+`, m.created_at
+	FROM org_members m
+	JOIN organizations o ON o.org_id = m.org_id
+	WHERE m.username = $1
+	ORDER BY m.created_at, m.org_id;
+	`, dto.UppercaseString(username))
+	if err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "FindByUser", err)
+	}
+	defer rows.Close()
+
+	var memberships []dto.OrgMembership
+	for rows.Next() {
+		membership := dto.OrgMembership{}
+		if err := rows.Scan(&membership.OrgID, &membership.Name, &membership.Roles, &membership.CreatedAt); err != nil {
+			return nil, parseQueryError(ctx, o.log, "org", "FindByUser", err)
+		}
+		memberships = append(memberships, membership)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "FindByUser", err)
+	}
+	return memberships, nil
+}
+
+// MemberRoles role user di dalam organisasi, false jika user bukan anggota
+func (o *orgDao) MemberRoles(ctx context.Context, orgID string, username string) ([]string, bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("org", "MemberRoles", time.Now())
+
+	var roles []string
+	err := db.Conn(ctx).QueryRow(ctx, `
+	SELECT `+orgMemberRolesColumn+`
+	FROM org_members m
+	WHERE m.org_id = $1 AND m.username = $2;
+	`, orgID, dto.UppercaseString(username)).Scan(&roles)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, parseQueryError(ctx, o.log, "org", "MemberRoles", err)
+	}
+	return roles, true, nil
+}
+
+// DefaultOrg organisasi pertama user yang digunakan ketika login, string kosong jika bukan anggota organisasi manapun
+func (o *orgDao) DefaultOrg(ctx context.Context, username string) (string, rest_err.APIError) {
+	defer mmetric.ObserveQuery("org", "DefaultOrg", time.Now())
+
+	var orgID string
+	err := db.Conn(ctx).QueryRow(ctx, `
+	SELECT org_id FROM org_members
+	WHERE username = $1
+	ORDER BY created_at, org_id
+	LIMIT 1;
+	`, dto.UppercaseString(username)).Scan(&orgID)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", parseQueryError(ctx, o.log, "org", "DefaultOrg", err)
+	}
+	return orgID, nil
+}
+
+func (o *orgDao) FindMembers(ctx context.Context, orgID string) ([]dto.OrgMember, rest_err.APIError) {
+	defer mmetric.ObserveQuery("org", "FindMembers", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, `
+	SELECT m.username, u.name, u.email, `+orgMemberRolesColumn+`, m.created_at
+	FROM org_members m
+	JOIN users u ON u.username = m.username
+	WHERE m.org_id = $1
+	ORDER BY m.username;
+	`, orgID)
+	if err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "FindMembers", err)
+	}
+	defer rows.Close()
+
+	var members []dto.OrgMember
+	for rows.Next() {
+		member := dto.OrgMember{}
+		if err := rows.Scan(&member.Username, &member.Name, &member.Email, &member.Roles, &member.CreatedAt); err != nil {
+			return nil, parseQueryError(ctx, o.log, "org", "FindMembers", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "FindMembers", err)
+	}
+	return members, nil
+}
+
+// SetMember mengganti seluruh role anggota organisasi, user yang belum menjadi anggota harus diundang (lihat Invite)
+func (o *orgDao) SetMember(ctx context.Context, orgID string, username string, roles []string) rest_err.APIError {
+	defer mmetric.ObserveQuery("org", "SetMember", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "org", "SetMember", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var member int
+	err = tx.QueryRow(ctx, `
+	SELECT 1 FROM org_members
+	WHERE org_id = $1 AND username = $2
+	FOR UPDATE;
+	`, orgID, dto.UppercaseString(username)).Scan(&member)
+	if err == pgx.ErrNoRows {
+		return rest_err.NewNotFoundError("org.user_not_member", username, orgID)
+	}
+	if err != nil {
+		return parseQueryError(ctx, o.log, "org", "SetMember", err)
+	}
+	if err := replaceOrgMemberRoles(ctx, tx, orgID, dto.UppercaseString(username), roles); err != nil {
+		return parseQueryError(ctx, o.log, "org", "SetMember", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, o.log, "org", "SetMember", err)
+	}
+	return nil
+}
+
+func (o *orgDao) RemoveMember(ctx context.Context, orgID string, username string) rest_err.APIError {
+	defer mmetric.ObserveQuery("org", "RemoveMember", time.Now())
+
+	res, err := db.Conn(ctx).Exec(ctx, "DELETE FROM org_members WHERE org_id = $1 AND username = $2;", orgID, dto.UppercaseString(username))
+	if err != nil {
+		return parseQueryError(ctx, o.log, "org", "RemoveMember", err)
+	}
+	if res.RowsAffected() != 1 {
+		return rest_err.NewNotFoundError("org.user_not_member", username, orgID)
+	}
+	return nil
+}
+
+// Invite menyimpan undangan baru, undangan sebelumnya yang belum diterima untuk email yang sama dibatalkan
+func (o *orgDao) Invite(ctx context.Context, invitation dto.OrgInvitation) rest_err.APIError {
+	defer mmetric.ObserveQuery("org", "Invite", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "org", "Invite", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, `
+	DELETE FROM org_invitations
+	WHERE org_id = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL;
+	`, invitation.OrgID, invitation.Email); err != nil {
+		return parseQueryError(ctx, o.log, "org", "Invite", err)
+	}
+	if _, err := tx.Exec(ctx, `
+	INSERT INTO org_invitations (token_hash, org_id, email, roles, invited_by, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`, invitation.TokenHash, invitation.OrgID, invitation.Email, invitation.Roles, dto.UppercaseString(invitation.InvitedBy), invitation.CreatedAt, invitation.ExpiresAt); err != nil {
+		return parseQueryError(ctx, o.log, "org", "Invite", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, o.log, "org", "Invite", err)
+	}
+	return nil
+}
+
+// FindInvitations undangan organisasi yang belum diterima dan belum kadaluarsa
+func (o *orgDao) FindInvitations(ctx context.Context, orgID string, now int64) ([]dto.OrgInvitation, rest_err.APIError) {
+	defer mmetric.ObserveQuery("org", "FindInvitations", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, `
+	SELECT org_id, email, roles, COALESCE(invited_by, ''), created_at, expires_at
+	FROM org_invitations
+	WHERE org_id = $1 AND accepted_at IS NULL AND expires_at > $2
+	ORDER BY created_at DESC;
+	`, orgID, now)
+	if err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "FindInvitations", err)
+	}
+	defer rows.Close()
+
+	var invitations []dto.OrgInvitation
+	for rows.Next() {
+		invitation := dto.OrgInvitation{}
+		if err := rows.Scan(&invitation.OrgID, &invitation.Email, &invitation.Roles, &invitation.InvitedBy, &invitation.CreatedAt, &invitation.ExpiresAt); err != nil {
+			return nil, parseQueryError(ctx, o.log, "org", "FindInvitations", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, o.log, "org", "FindInvitations", err)
+	}
+	return invitations, nil
+}
+
+// CancelInvitation membatalkan undangan yang belum diterima untuk email
+func (o *orgDao) CancelInvitation(ctx context.Context, orgID string, email string) rest_err.APIError {
+	defer mmetric.ObserveQuery("org", "CancelInvitation", time.Now())
+
+	res, err := db.Conn(ctx).Exec(ctx, `
+	DELETE FROM org_invitations
+	WHERE org_id = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL;
+	`, orgID, email)
+	if err != nil {
+		return parseQueryError(ctx, o.log, "org", "CancelInvitation", err)
+	}
+	if res.RowsAffected() == 0 {
+		return rest_err.NewNotFoundError("org.invitation_not_found", email, orgID)
+	}
+	return nil
+}
+
+// AcceptInvitation menandai undangan diterima secara atomik lalu menjadikan user anggota dengan role dari undangan.
+// undangan hanya dapat diterima oleh user dengan email tujuan undangan, string kosong jika token tidak dikenal,
+// sudah dipakai, kadaluarsa atau email tidak cocok
+func (o *orgDao) AcceptInvitation(ctx context.Context, tokenHash string, username string, email string, now int64) (string, rest_err.APIError) {
+	defer mmetric.ObserveQuery("org", "AcceptInvitation", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return "", parseQueryError(ctx, o.log, "org", "AcceptInvitation", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var orgID string
+	var roles []string
+	err = tx.QueryRow(ctx, `
+	UPDATE org_invitations
+	SET accepted_by = $3, accepted_at = $4
+	WHERE token_hash = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL AND expires_at > $4
+	RETURNING org_id, roles;
+	`, tokenHash, email, dto.UppercaseString(username), now).Scan(&orgID, &roles)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", parseQueryError(ctx, o.log, "org", "AcceptInvitation", err)
+	}
+	if err := replaceOrgMember(ctx, tx, orgID, dto.UppercaseString(username), roles, now); err != nil {
+		return "", parseQueryError(ctx, o.log, "org", "AcceptInvitation", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", parseQueryError(ctx, o.log, "org", "AcceptInvitation", err)
+	}
+	return orgID, nil
+}
+
+func replaceOrgMember(ctx context.Context, tx pgx.Tx, orgID string, username dto.UppercaseString, roles []string, now int64) error {
+	if _, err := tx.Exec(ctx, `
+	INSERT INTO org_members (org_id, username, created_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (org_id, username) DO NOTHING;
+	`, orgID, username, now); err != nil {
+		return err
+	}
+	return replaceOrgMemberRoles(ctx, tx, orgID, username, roles)
+}
+
+func replaceOrgMemberRoles(ctx context.Context, tx pgx.Tx, orgID string, username dto.UppercaseString, roles []string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM org_member_roles WHERE org_id = $1 AND username = $2;", orgID, username); err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+	INSERT INTO org_member_roles (org_id, username, role)
+	SELECT $1, $2, unnest($3::VARCHAR[])
+	ON CONFLICT DO NOTHING;
+	`, orgID, username, roles)
+	return err
+}
@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewPasswordResetDao(log mlog.LoggerAssumer) PasswordResetDaoAssumer {
+	return &passwordResetDao{
+		log: log,
+	}
+}
+
+type PasswordResetDaoAssumer interface {
+	InsertForEmail(ctx context.Context, email string, token dto.PasswordResetToken) (*dto.User, rest_err.APIError)
+	Peek(ctx context.Context, tokenHash string, now int64) (string, rest_err.APIError)
+	Reset(ctx context.Context, tokenHash string, passwordHash string, now int64) (string, rest_err.APIError)
+}
+
+type passwordResetDao struct {
+	log mlog.LoggerAssumer
+}
+
+// InsertForEmail menyimpan token untuk user pemilik email dalam satu query dan mengembalikan username, email dan nama user tersebut.
+// query yang sama dijalankan baik email terdaftar maupun tidak, nil jika email tidak terdaftar
+func (p *passwordResetDao) InsertForEmail(ctx context.Context, email string, token dto.PasswordResetToken) (*dto.User, rest_err.APIError) {
+	defer mmetric.ObserveQuery("password_reset", "InsertForEmail", time.Now())
+
+	sqlStatement := `
+	WITH target AS (
+		SELECT username, email, name FROM users WHERE LOWER(email) = LOWER($1)
+	), inserted AS (
+		INSERT INTO password_reset_tokens (token_hash, username, created_at, expires_at) 
+		SELECT $2, username, $3, $4 FROM target
+	)
+	SELECT username, email, name FROM target;
+	`
+	var user dto.User
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, email, token.TokenHash, token.CreatedAt, token.ExpiresAt).
+		Scan(&user.Username, &user.Email, &user.Name)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, parseQueryError(ctx, p.log, "password_reset", "InsertForEmail", err)
+	}
+	return &user, nil
+}
+
+// Peek mengembalikan username pemilik token yang masih berlaku tanpa menandainya terpakai,
+// string kosong jika token tidak dikenal, sudah dipakai atau kadaluarsa
+func (p *passwordResetDao) Peek(ctx context.Context, tokenHash string, now int64) (string, rest_err.APIError) {
+	defer mmetric.ObserveQuery("password_reset", "Peek", time.Now())
+
+	sqlStatement := `
+	SELECT username 
+	FROM password_reset_tokens 
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2;
+	`
+	var username string
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, tokenHash, now).Scan(&username)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", parseQueryError(ctx, p.log, "password_reset", "Peek", err)
+	}
+	return username, nil
+}
+
+// Reset menandai token terpakai dan mengganti password pemiliknya dalam satu transaksi, sehingga token tidak hangus
+// jika password gagal disimpan. token lain milik user ikut dibatalkan dan token yang sudah kadaluarsa dihapus.
+// mengembalikan username pemilik token, string kosong jika token tidak dikenal, sudah dipakai atau kadaluarsa
+func (p *passwordResetDao) Reset(ctx context.Context, tokenHash string, passwordHash string, now int64) (string, rest_err.APIError) {
+	defer mmetric.ObserveQuery("password_reset", "Reset", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return "", parseQueryError(ctx, p.log, "password_reset", "Reset", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var username string
+	err = tx.QueryRow(ctx, `
+	UPDATE password_reset_tokens 
+	SET used_at = $2 
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2 
+	RETURNING username;
+	`, tokenHash, now).Scan(&username)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", parseQueryError(ctx, p.log, "password_reset", "Reset", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE users 
+	SET password = $2, must_change_password = FALSE, updated_at = $3 
+	WHERE username = $1;
+	`, username, passwordHash, now)
+	if err != nil {
+		return "", parseQueryError(ctx, p.log, "password_reset", "Reset", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE password_reset_tokens 
+	SET used_at = $2 
+	WHERE username = $1 AND used_at IS NULL;
+	`, username, now)
+	if err != nil {
+		return "", parseQueryError(ctx, p.log, "password_reset", "Reset", err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM password_reset_tokens WHERE expires_at <= $1;", now)
+	if err != nil {
+		return "", parseQueryError(ctx, p.log, "password_reset", "Reset", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", parseQueryError(ctx, p.log, "password_reset", "Reset", err)
+	}
+	return username, nil
+}
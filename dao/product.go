@@ -2,141 +2,197 @@ package dao
 
 import (
 	"context"
-	"fmt"
+	"github.com/jackc/pgx/v4"
 	"github.com/muchlist/sagasql/db"
 	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
 	"github.com/muchlist/sagasql/utils/rest_err"
-	"github.com/muchlist/sagasql/utils/sql_err"
+	"time"
 )
 
-func NewProductDao() ProductDaoAssumer {
-	return &productDao{}
+func NewProductDao(log mlog.LoggerAssumer) ProductDaoAssumer {
+	return &productDao{
+		log: log,
+	}
 }
 
 type ProductDaoAssumer interface {
-	Insert(product dto.Product) (*int64, rest_err.APIError)
-	Edit(productInput dto.Product) (*dto.Product, rest_err.APIError)
-	Delete(productID int64) rest_err.APIError
-	UploadImage(productID int64, imagePath string) (*dto.Product, rest_err.APIError)
-	Get(productID int64) (*dto.Product, rest_err.APIError)
-	Find() ([]dto.Product, rest_err.APIError)
-	Search(productName dto.UppercaseString) ([]dto.Product, rest_err.APIError)
+	Insert(ctx context.Context, product dto.Product) (*int64, rest_err.APIError)
+	Edit(ctx context.Context, productInput dto.Product) (*dto.Product, rest_err.APIError)
+	Delete(ctx context.Context, productID int64) rest_err.APIError
+	UploadImage(ctx context.Context, productID int64, imagePath string) (*dto.Product, rest_err.APIError)
+	Get(ctx context.Context, productID int64) (*dto.Product, rest_err.APIError)
+	Find(ctx context.Context) ([]dto.Product, rest_err.APIError)
+	Search(ctx context.Context, productName dto.UppercaseString) ([]dto.Product, rest_err.APIError)
 }
 
+// productDao semua query dibatasi pada organisasi aktif di ctx (lihat activeOrg),
+// product organisasi lain diperlakukan seperti tidak ada
 type productDao struct {
+	log mlog.LoggerAssumer
+}
+
+const productColumns = "product_id, org_id, name, price, image, created_by, created_at"
+
+func scanProduct(row pgx.Row, product *dto.Product) error {
+	return row.Scan(&product.ProductID, &product.OrgID, &product.Name, &product.Price, &product.Image, &product.CreatedBy, &product.CreatedAt)
 }
 
-func (u *productDao) Insert(product dto.Product) (*int64, rest_err.APIError) {
+func (u *productDao) Insert(ctx context.Context, product dto.Product) (*int64, rest_err.APIError) {
+	defer mmetric.ObserveQuery("product", "Insert", time.Now())
+	orgID, apiErr := activeOrg(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
 	sqlStatement := `
-	INSERT INTO products (name, price, created_by, created_at) 
-	VALUES ($1, $2, $3, $4) RETURNING product_id;
+	INSERT INTO products (org_id, name, price, created_by, created_at) 
+	VALUES ($1, $2, $3, $4, $5) RETURNING product_id;
 	`
 	var productID int64
-	err := db.DB.QueryRow(context.Background(), sqlStatement, product.Name, product.Price, product.CreatedBy, product.CreatedAt).Scan(&productID)
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, orgID, product.Name, product.Price, product.CreatedBy, product.CreatedAt).Scan(&productID)
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return nil, parseQueryError(ctx, u.log, "product", "Insert", err)
 	}
 	return &productID, nil
 }
 
-func (u *productDao) Edit(input dto.Product) (*dto.Product, rest_err.APIError) {
+func (u *productDao) Edit(ctx context.Context, input dto.Product) (*dto.Product, rest_err.APIError) {
+	defer mmetric.ObserveQuery("product", "Edit", time.Now())
+	orgID, apiErr := activeOrg(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
 	sqlStatement := `
 	UPDATE products 
-	SET name = $2, price = $3
-	WHERE product_id = $1 
-	RETURNING product_id, name, price, image, created_by, created_at;
+	SET name = $3, price = $4
+	WHERE product_id = $1 AND org_id = $2 
+	RETURNING ` + productColumns + `;
 	`
 
 	var product dto.Product
-	err := db.DB.QueryRow(
-		context.Background(),
-		sqlStatement, input.ProductID, input.Name, input.Price,
-	).Scan(&product.ProductID, &product.Name, &product.Price, &product.Image, &product.CreatedBy, &product.CreatedAt)
+	err := scanProduct(db.Conn(ctx).QueryRow(
+		ctx,
+		sqlStatement, input.ProductID, orgID, input.Name, input.Price,
+	), &product)
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return nil, parseQueryError(ctx, u.log, "product", "Edit", err)
 	}
 	return &product, nil
 }
 
-func (u *productDao) Delete(productID int64) rest_err.APIError {
+func (u *productDao) Delete(ctx context.Context, productID int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("product", "Delete", time.Now())
+	orgID, apiErr := activeOrg(ctx)
+	if apiErr != nil {
+		return apiErr
+	}
+
 	sqlStatement := `
 	DELETE FROM products 
-	WHERE product_id = $1;
+	WHERE product_id = $1 AND org_id = $2;
 	`
-	res, err := db.DB.Exec(context.Background(), sqlStatement, productID)
+	res, err := db.Conn(ctx).Exec(ctx, sqlStatement, productID, orgID)
 	if err != nil {
-		return sql_err.ParseError(err)
+		return parseQueryError(ctx, u.log, "product", "Delete", err)
 	}
 	if res.RowsAffected() != 1 {
-		return rest_err.NewBadRequestError(fmt.Sprintf("Product dengan product_id %d tidak ditemukan", productID))
+		return rest_err.NewNotFoundError("product.not_found", productID)
 	}
 
 	return nil
 }
 
-func (u *productDao) UploadImage(productID int64, imagePath string) (*dto.Product, rest_err.APIError) {
+func (u *productDao) UploadImage(ctx context.Context, productID int64, imagePath string) (*dto.Product, rest_err.APIError) {
+	defer mmetric.ObserveQuery("product", "UploadImage", time.Now())
+	orgID, apiErr := activeOrg(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
 	sqlStatement := `
 	UPDATE products 
-	SET image = $2
-	WHERE product_id = $1 
-	RETURNING product_id, name, price, image, created_by, created_at;
+	SET image = $3
+	WHERE product_id = $1 AND org_id = $2 
+	RETURNING ` + productColumns + `;
 	`
 
 	var product dto.Product
-	err := db.DB.QueryRow(
-		context.Background(),
-		sqlStatement, productID, imagePath,
-	).Scan(&product.ProductID, &product.Name, &product.Price, &product.Image, &product.CreatedBy, &product.CreatedAt)
+	err := scanProduct(db.Conn(ctx).QueryRow(
+		ctx,
+		sqlStatement, productID, orgID, imagePath,
+	), &product)
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return nil, parseQueryError(ctx, u.log, "product", "UploadImage", err)
 	}
 	return &product, nil
 }
 
-func (u *productDao) Get(productID int64) (*dto.Product, rest_err.APIError) {
+func (u *productDao) Get(ctx context.Context, productID int64) (*dto.Product, rest_err.APIError) {
+	defer mmetric.ObserveQuery("product", "Get", time.Now())
+	orgID, apiErr := activeOrg(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
 
 	sqlStatement := `
-	SELECT product_id, name, price, image, created_by, created_at 
+	SELECT ` + productColumns + ` 
 	FROM products 
-	WHERE product_id = $1;
+	WHERE product_id = $1 AND org_id = $2;
 	`
-	row := db.DB.QueryRow(context.Background(), sqlStatement, productID)
+	row := db.Conn(ctx).QueryRow(ctx, sqlStatement, productID, orgID)
 
 	var product dto.Product
-	err := row.Scan(&product.ProductID, &product.Name, &product.Price, &product.Image, &product.CreatedBy, &product.CreatedAt)
+	err := scanProduct(row, &product)
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return nil, parseQueryError(ctx, u.log, "product", "Get", err)
 	}
 	return &product, nil
 }
 
-func (u *productDao) Find() ([]dto.Product, rest_err.APIError) {
-	rows, err := db.DB.Query(context.Background(),
-		`	SELECT product_id, name, price, image, created_by, created_at 
+func (u *productDao) Find(ctx context.Context) ([]dto.Product, rest_err.APIError) {
+	defer mmetric.ObserveQuery("product", "Find", time.Now())
+	orgID, apiErr := activeOrg(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	rows, err := db.Conn(ctx).Query(ctx,
+		`	SELECT `+productColumns+` 
 				FROM products 
-				ORDER BY name ASC;`)
+				WHERE org_id = $1 
+				ORDER BY name ASC;`, orgID)
 	if err != nil {
-		return nil, rest_err.NewInternalServerError("gagal mendapatkan daftar product", err)
+		logQueryError(ctx, u.log, "product", "Find", err)
+		return nil, rest_err.NewInternalServerError("product.find_failed", err)
 	}
 	defer rows.Close()
 	var products []dto.Product
 	for rows.Next() {
 		product := dto.Product{}
-		err := rows.Scan(&product.ProductID, &product.Name, &product.Price, &product.Image, &product.CreatedBy, &product.CreatedAt)
+		err := scanProduct(rows, &product)
 		if err != nil {
-			return nil, sql_err.ParseError(err)
+			return nil, parseQueryError(ctx, u.log, "product", "Find", err)
 		}
 		products = append(products, product)
 	}
 	return products, nil
 }
 
-func (u *productDao) Search(productName dto.UppercaseString) ([]dto.Product, rest_err.APIError) {
+func (u *productDao) Search(ctx context.Context, productName dto.UppercaseString) ([]dto.Product, rest_err.APIError) {
+	defer mmetric.ObserveQuery("product", "Search", time.Now())
+	orgID, apiErr := activeOrg(ctx)
+	if apiErr != nil {
+		return nil, apiErr
+	}
 
-	rows, err := db.DB.Query(context.Background(),
-		`SELECT product_id, name, price, image, created_by, created_at FROM products WHERE name LIKE '%'|| $1 || '%' ORDER BY name ASC ;`, productName)
+	rows, err := db.Conn(ctx).Query(ctx,
+		`SELECT `+productColumns+` FROM products WHERE org_id = $1 AND name LIKE '%'|| $2 || '%' ORDER BY name ASC ;`, orgID, productName)
 	if err != nil {
-		return nil, rest_err.NewInternalServerError("gagal mendapatkan daftar product", err)
+		logQueryError(ctx, u.log, "product", "Search", err)
+		return nil, rest_err.NewInternalServerError("product.find_failed", err)
 	}
 
 	defer rows.Close()
@@ -144,9 +200,9 @@ func (u *productDao) Search(productName dto.UppercaseString) ([]dto.Product, res
 	var products []dto.Product
 	for rows.Next() {
 		product := dto.Product{}
-		err := rows.Scan(&product.ProductID, &product.Name, &product.Price, &product.Image, &product.CreatedBy, &product.CreatedAt)
+		err := scanProduct(rows, &product)
 		if err != nil {
-			return nil, sql_err.ParseError(err)
+			return nil, parseQueryError(ctx, u.log, "product", "Search", err)
 		}
 		products = append(products, product)
 	}
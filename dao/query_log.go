@@ -0,0 +1,27 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"github.com/muchlist/sagasql/utils/sql_err"
+)
+
+// logQueryError mencatat error query ke log, data tidak ditemukan tidak dianggap error
+func logQueryError(ctx context.Context, log mlog.LoggerAssumer, dao string, method string, err error) {
+	if err == pgx.ErrNoRows {
+		return
+	}
+	log.Error(ctx, "query database gagal",
+		mlog.String("dao", dao),
+		mlog.String("method", method),
+		mlog.Err(err),
+	)
+}
+
+// parseQueryError mencatat error query ke log lalu menerjemahkannya menjadi APIError
+func parseQueryError(ctx context.Context, log mlog.LoggerAssumer, dao string, method string, err error) rest_err.APIError {
+	logQueryError(ctx, log, dao, method, err)
+	return sql_err.ParseError(err)
+}
@@ -0,0 +1,242 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewRBACDao(log mlog.LoggerAssumer) RBACDaoAssumer {
+	return &rbacDao{
+		log: log,
+	}
+}
+
+type RBACDaoAssumer interface {
+	FindRoles(ctx context.Context) ([]dto.Role, rest_err.APIError)
+	GetRole(ctx context.Context, name string) (*dto.Role, rest_err.APIError)
+	InsertRole(ctx context.Context, role dto.Role) rest_err.APIError
+	EditRole(ctx context.Context, role dto.Role) rest_err.APIError
+	DeleteRole(ctx context.Context, name string) rest_err.APIError
+	FindPermissions(ctx context.Context) ([]dto.Permission, rest_err.APIError)
+	SetUserRoles(ctx context.Context, username string, roles []string) rest_err.APIError
+	SetRoleMFARequired(ctx context.Context, name string, required bool) rest_err.APIError
+}
+
+type rbacDao struct {
+	log mlog.LoggerAssumer
+}
+
+const roleSelect = `
+	SELECT r.name, r.description, r.mfa_required, r.org_scoped, r.created_at,
+	COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role = r.name
+	`
+
+// FindRoles mengembalikan semua role beserta permission nya
+func (r *rbacDao) FindRoles(ctx context.Context) ([]dto.Role, rest_err.APIError) {
+	defer mmetric.ObserveQuery("rbac", "FindRoles", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, roleSelect+"GROUP BY r.name ORDER BY r.name;")
+	if err != nil {
+		return nil, parseQueryError(ctx, r.log, "rbac", "FindRoles", err)
+	}
+	defer rows.Close()
+
+	var roles []dto.Role
+	for rows.Next() {
+		role := dto.Role{}
+		err := rows.Scan(&role.Name, &role.Description, &role.MFARequired, &role.OrgScoped, &role.CreatedAt, &role.Permissions)
+		if err != nil {
+			return nil, parseQueryError(ctx, r.log, "rbac", "FindRoles", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, r.log, "rbac", "FindRoles", err)
+	}
+	return roles, nil
+}
+
+func (r *rbacDao) GetRole(ctx context.Context, name string) (*dto.Role, rest_err.APIError) {
+	defer mmetric.ObserveQuery("rbac", "GetRole", time.Now())
+
+	var role dto.Role
+	err := db.Conn(ctx).QueryRow(ctx, roleSelect+"WHERE r.name = $1 GROUP BY r.name;", name).
+		Scan(&role.Name, &role.Description, &role.MFARequired, &role.OrgScoped, &role.CreatedAt, &role.Permissions)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, rest_err.NewNotFoundError("rbac.role_not_found", name)
+		}
+		return nil, parseQueryError(ctx, r.log, "rbac", "GetRole", err)
+	}
+	return &role, nil
+}
+
+// InsertRole menyimpan role beserta permission nya dalam satu transaksi
+func (r *rbacDao) InsertRole(ctx context.Context, role dto.Role) rest_err.APIError {
+	defer mmetric.ObserveQuery("rbac", "InsertRole", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "InsertRole", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO roles (name, description, org_scoped, created_at)
+	VALUES ($1, $2, $3, $4);
+	`, role.Name, role.Description, role.OrgScoped, role.CreatedAt)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "InsertRole", err)
+	}
+	if err := insertRolePermissions(ctx, tx, role.Name, role.Permissions); err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "InsertRole", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "InsertRole", err)
+	}
+	return nil
+}
+
+// EditRole mengganti deskripsi dan seluruh permission role
+func (r *rbacDao) EditRole(ctx context.Context, role dto.Role) rest_err.APIError {
+	defer mmetric.ObserveQuery("rbac", "EditRole", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "EditRole", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	res, err := tx.Exec(ctx, "UPDATE roles SET description = $2 WHERE name = $1;", role.Name, role.Description)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "EditRole", err)
+	}
+	if res.RowsAffected() != 1 {
+		return rest_err.NewNotFoundError("rbac.role_not_found", role.Name)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM role_permissions WHERE role = $1;", role.Name); err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "EditRole", err)
+	}
+	if err := insertRolePermissions(ctx, tx, role.Name, role.Permissions); err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "EditRole", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "EditRole", err)
+	}
+	return nil
+}
+
+func (r *rbacDao) DeleteRole(ctx context.Context, name string) rest_err.APIError {
+	defer mmetric.ObserveQuery("rbac", "DeleteRole", time.Now())
+
+	res, err := db.Conn(ctx).Exec(ctx, "DELETE FROM roles WHERE name = $1;", name)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "DeleteRole", err)
+	}
+	if res.RowsAffected() != 1 {
+		return rest_err.NewNotFoundError("rbac.role_not_found", name)
+	}
+	return nil
+}
+
+func (r *rbacDao) FindPermissions(ctx context.Context) ([]dto.Permission, rest_err.APIError) {
+	defer mmetric.ObserveQuery("rbac", "FindPermissions", time.Now())
+
+	rows, err := db.Conn(ctx).Query(ctx, "SELECT name, description FROM permissions ORDER BY name;")
+	if err != nil {
+		return nil, parseQueryError(ctx, r.log, "rbac", "FindPermissions", err)
+	}
+	defer rows.Close()
+
+	var permissions []dto.Permission
+	for rows.Next() {
+		permission := dto.Permission{}
+		if err := rows.Scan(&permission.Name, &permission.Description); err != nil {
+			return nil, parseQueryError(ctx, r.log, "rbac", "FindPermissions", err)
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, r.log, "rbac", "FindPermissions", err)
+	}
+	return permissions, nil
+}
+
+// SetUserRoles mengganti seluruh role milik user dalam satu transaksi
+func (r *rbacDao) SetUserRoles(ctx context.Context, username string, roles []string) rest_err.APIError {
+	defer mmetric.ObserveQuery("rbac", "SetUserRoles", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "SetUserRoles", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := replaceUserRoles(ctx, tx, dto.UppercaseString(username), roles); err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "SetUserRoles", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "SetUserRoles", err)
+	}
+	return nil
+}
+
+// SetRoleMFARequired mengatur kewajiban 2FA untuk role
+func (r *rbacDao) SetRoleMFARequired(ctx context.Context, name string, required bool) rest_err.APIError {
+	defer mmetric.ObserveQuery("rbac", "SetRoleMFARequired", time.Now())
+
+	res, err := db.Conn(ctx).Exec(ctx, "UPDATE roles SET mfa_required = $2 WHERE name = $1;", name, required)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "rbac", "SetRoleMFARequired", err)
+	}
+	if res.RowsAffected() != 1 {
+		return rest_err.NewNotFoundError("rbac.role_not_found", name)
+	}
+	return nil
+}
+
+func insertRolePermissions(ctx context.Context, tx pgx.Tx, role string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+	INSERT INTO role_permissions (role, permission)
+	SELECT $1, unnest($2::VARCHAR[])
+	ON CONFLICT DO NOTHING;
+	`, role, permissions)
+	return err
+}
+
+// replaceUserRoles digunakan juga oleh userDao ketika insert user
+func replaceUserRoles(ctx context.Context, tx pgx.Tx, username dto.UppercaseString, roles []string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM user_roles WHERE username = $1;", username); err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+	INSERT INTO user_roles (username, role)
+	SELECT $1, unnest($2::VARCHAR[])
+	ON CONFLICT DO NOTHING;
+	`, username, roles)
+	return err
+}
@@ -0,0 +1,178 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewRefreshTokenDao(log mlog.LoggerAssumer) RefreshTokenDaoAssumer {
+	return &refreshTokenDao{
+		log: log,
+	}
+}
+
+type RefreshTokenDaoAssumer interface {
+	CreateFamily(ctx context.Context, familyID string, username string, createdAt int64) rest_err.APIError
+	GetFamily(ctx context.Context, familyID string) (*dto.RefreshTokenFamily, rest_err.APIError)
+	RevokeFamily(ctx context.Context, familyID string, revokedAt int64) rest_err.APIError
+	RevokeUserFamilies(ctx context.Context, username string, exceptFamilyID string, revokedAt int64) ([]string, rest_err.APIError)
+	InsertToken(ctx context.Context, token dto.RefreshToken) rest_err.APIError
+	GetToken(ctx context.Context, jti string) (*dto.RefreshToken, rest_err.APIError)
+	MarkUsed(ctx context.Context, jti string, usedAt int64) (bool, rest_err.APIError)
+	MarkFamilyUsed(ctx context.Context, familyID string, usedAt int64) rest_err.APIError
+}
+
+type refreshTokenDao struct {
+	log mlog.LoggerAssumer
+}
+
+func (r *refreshTokenDao) CreateFamily(ctx context.Context, familyID string, username string, createdAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("refresh_token", "CreateFamily", time.Now())
+
+	sqlStatement := `
+	INSERT INTO refresh_token_families (family_id, username, created_at) 
+	VALUES ($1, $2, $3);
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, familyID, dto.UppercaseString(username), createdAt)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "refresh_token", "CreateFamily", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenDao) GetFamily(ctx context.Context, familyID string) (*dto.RefreshTokenFamily, rest_err.APIError) {
+	defer mmetric.ObserveQuery("refresh_token", "GetFamily", time.Now())
+
+	sqlStatement := `
+	SELECT family_id, username, created_at, revoked_at 
+	FROM refresh_token_families 
+	WHERE family_id = $1;
+	`
+	var family dto.RefreshTokenFamily
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, familyID).Scan(&family.FamilyID, &family.Username, &family.CreatedAt, &family.RevokedAt)
+	if err != nil {
+		return nil, parseQueryError(ctx, r.log, "refresh_token", "GetFamily", err)
+	}
+	return &family, nil
+}
+
+// RevokeFamily menandai family dicabut, semua refresh token di dalamnya tidak dapat digunakan lagi
+func (r *refreshTokenDao) RevokeFamily(ctx context.Context, familyID string, revokedAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("refresh_token", "RevokeFamily", time.Now())
+
+	sqlStatement := `
+	UPDATE refresh_token_families 
+	SET revoked_at = $2 
+	WHERE family_id = $1 AND revoked_at IS NULL;
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, familyID, revokedAt)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "refresh_token", "RevokeFamily", err)
+	}
+	return nil
+}
+
+// RevokeUserFamilies mencabut semua family milik username kecuali exceptFamilyID ("" untuk mencabut semua),
+// mengembalikan family_id yang dicabut
+func (r *refreshTokenDao) RevokeUserFamilies(ctx context.Context, username string, exceptFamilyID string, revokedAt int64) ([]string, rest_err.APIError) {
+	defer mmetric.ObserveQuery("refresh_token", "RevokeUserFamilies", time.Now())
+
+	sqlStatement := `
+	UPDATE refresh_token_families 
+	SET revoked_at = $3 
+	WHERE username = $1 AND family_id <> $2 AND revoked_at IS NULL 
+	RETURNING family_id;
+	`
+	rows, err := db.Conn(ctx).Query(ctx, sqlStatement, dto.UppercaseString(username), exceptFamilyID, revokedAt)
+	if err != nil {
+		return nil, parseQueryError(ctx, r.log, "refresh_token", "RevokeUserFamilies", err)
+	}
+	defer rows.Close()
+
+	var familyIDs []string
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, parseQueryError(ctx, r.log, "refresh_token", "RevokeUserFamilies", err)
+		}
+		familyIDs = append(familyIDs, familyID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, parseQueryError(ctx, r.log, "refresh_token", "RevokeUserFamilies", err)
+	}
+	return familyIDs, nil
+}
+
+func (r *refreshTokenDao) InsertToken(ctx context.Context, token dto.RefreshToken) rest_err.APIError {
+	defer mmetric.ObserveQuery("refresh_token", "InsertToken", time.Now())
+
+	sqlStatement := `
+	INSERT INTO refresh_tokens (jti, family_id, expires_at) 
+	VALUES ($1, $2, $3);
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, token.JTI, token.FamilyID, token.ExpiresAt)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "refresh_token", "InsertToken", err)
+	}
+	return nil
+}
+
+// GetToken mengembalikan refresh token, nil jika jti tidak dikenal
+func (r *refreshTokenDao) GetToken(ctx context.Context, jti string) (*dto.RefreshToken, rest_err.APIError) {
+	defer mmetric.ObserveQuery("refresh_token", "GetToken", time.Now())
+
+	sqlStatement := `
+	SELECT jti, family_id, expires_at, used_at 
+	FROM refresh_tokens 
+	WHERE jti = $1;
+	`
+	var token dto.RefreshToken
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, jti).Scan(&token.JTI, &token.FamilyID, &token.ExpiresAt, &token.UsedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, parseQueryError(ctx, r.log, "refresh_token", "GetToken", err)
+	}
+	return &token, nil
+}
+
+// MarkUsed menandai refresh token sudah dipakai secara atomik.
+// mengembalikan false jika token sudah pernah dipakai sebelumnya (reuse)
+func (r *refreshTokenDao) MarkUsed(ctx context.Context, jti string, usedAt int64) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("refresh_token", "MarkUsed", time.Now())
+
+	sqlStatement := `
+	UPDATE refresh_tokens 
+	SET used_at = $2 
+	WHERE jti = $1 AND used_at IS NULL;
+	`
+	res, err := db.Conn(ctx).Exec(ctx, sqlStatement, jti, usedAt)
+	if err != nil {
+		return false, parseQueryError(ctx, r.log, "refresh_token", "MarkUsed", err)
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// MarkFamilyUsed menandai refresh token family yang belum dipakai sebagai terpakai,
+// token tersebut dianggap dipakai ulang (reuse) jika dikirim kembali ke /refresh
+func (r *refreshTokenDao) MarkFamilyUsed(ctx context.Context, familyID string, usedAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("refresh_token", "MarkFamilyUsed", time.Now())
+
+	sqlStatement := `
+	UPDATE refresh_tokens 
+	SET used_at = $2 
+	WHERE family_id = $1 AND used_at IS NULL;
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, familyID, usedAt)
+	if err != nil {
+		return parseQueryError(ctx, r.log, "refresh_token", "MarkFamilyUsed", err)
+	}
+	return nil
+}
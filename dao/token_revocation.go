@@ -0,0 +1,109 @@
+package dao
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewTokenRevocationDao(log mlog.LoggerAssumer) TokenRevocationDaoAssumer {
+	return &tokenRevocationDao{
+		log: log,
+	}
+}
+
+type TokenRevocationDaoAssumer interface {
+	Revoke(ctx context.Context, jti string, username string, expiresAt int64) (bool, rest_err.APIError)
+	IsRevoked(ctx context.Context, jti string) (bool, rest_err.APIError)
+	RevokeAllBefore(ctx context.Context, username string, revokedBeforeMs int64) rest_err.APIError
+	GetRevokedBefore(ctx context.Context, username string) (int64, rest_err.APIError)
+	PurgeExpired(ctx context.Context, now int64) rest_err.APIError
+}
+
+type tokenRevocationDao struct {
+	log mlog.LoggerAssumer
+}
+
+// Revoke mencatat jti sebagai token yang dicabut sampai masa berlakunya habis,
+// mengembalikan false jika jti sudah dicabut sebelumnya
+func (t *tokenRevocationDao) Revoke(ctx context.Context, jti string, username string, expiresAt int64) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("token_revocation", "Revoke", time.Now())
+
+	sqlStatement := `
+	INSERT INTO revoked_tokens (jti, username, expires_at, revoked_at) 
+	VALUES ($1, $2, $3, $4) 
+	ON CONFLICT (jti) DO NOTHING;
+	`
+	res, err := db.Conn(ctx).Exec(ctx, sqlStatement, jti, dto.UppercaseString(username), expiresAt, time.Now().Unix())
+	if err != nil {
+		return false, parseQueryError(ctx, t.log, "token_revocation", "Revoke", err)
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+func (t *tokenRevocationDao) IsRevoked(ctx context.Context, jti string) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("token_revocation", "IsRevoked", time.Now())
+
+	sqlStatement := `
+	SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1);
+	`
+	var revoked bool
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, jti).Scan(&revoked)
+	if err != nil {
+		return false, parseQueryError(ctx, t.log, "token_revocation", "IsRevoked", err)
+	}
+	return revoked, nil
+}
+
+// RevokeAllBefore mencabut semua token username yang diterbitkan sebelum revokedBeforeMs (milidetik)
+func (t *tokenRevocationDao) RevokeAllBefore(ctx context.Context, username string, revokedBeforeMs int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("token_revocation", "RevokeAllBefore", time.Now())
+
+	sqlStatement := `
+	INSERT INTO user_token_revocations (username, revoked_before_ms) 
+	VALUES ($1, $2) 
+	ON CONFLICT (username) DO UPDATE SET revoked_before_ms = GREATEST(user_token_revocations.revoked_before_ms, $2);
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, dto.UppercaseString(username), revokedBeforeMs)
+	if err != nil {
+		return parseQueryError(ctx, t.log, "token_revocation", "RevokeAllBefore", err)
+	}
+	return nil
+}
+
+// GetRevokedBefore mengembalikan batas waktu terbit (milidetik) token username, 0 jika belum pernah dicabut
+func (t *tokenRevocationDao) GetRevokedBefore(ctx context.Context, username string) (int64, rest_err.APIError) {
+	defer mmetric.ObserveQuery("token_revocation", "GetRevokedBefore", time.Now())
+
+	sqlStatement := `
+	SELECT revoked_before_ms FROM user_token_revocations WHERE username = $1;
+	`
+	var revokedBefore int64
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, dto.UppercaseString(username)).Scan(&revokedBefore)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, parseQueryError(ctx, t.log, "token_revocation", "GetRevokedBefore", err)
+	}
+	return revokedBefore, nil
+}
+
+// PurgeExpired menghapus catatan token yang sudah kadaluarsa karena token tersebut sudah pasti ditolak
+func (t *tokenRevocationDao) PurgeExpired(ctx context.Context, now int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("token_revocation", "PurgeExpired", time.Now())
+
+	sqlStatement := `
+	DELETE FROM revoked_tokens WHERE expires_at < $1;
+	`
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, now)
+	if err != nil {
+		return parseQueryError(ctx, t.log, "token_revocation", "PurgeExpired", err)
+	}
+	return nil
+}
@@ -2,125 +2,303 @@ package dao
 
 import (
 	"context"
-	"fmt"
+	"github.com/jackc/pgx/v4"
 	"github.com/muchlist/sagasql/db"
 	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
 	"github.com/muchlist/sagasql/utils/rest_err"
-	"github.com/muchlist/sagasql/utils/sql_err"
+	"time"
 )
 
-func NewUserDao() UserDaoAssumer {
-	return &userDao{}
+func NewUserDao(log mlog.LoggerAssumer) UserDaoAssumer {
+	return &userDao{
+		log: log,
+	}
 }
 
 type UserDaoAssumer interface {
-	Insert(user dto.User) (*string, rest_err.APIError)
-	Edit(userInput dto.User) (*dto.User, rest_err.APIError)
-	Delete(userName string) rest_err.APIError
-	ChangePassword(input dto.User) (*dto.User, rest_err.APIError)
-	Get(userName string) (*dto.User, rest_err.APIError)
-	Find() ([]dto.User, rest_err.APIError)
+	Insert(ctx context.Context, user dto.User) (*string, rest_err.APIError)
+	InsertFirst(ctx context.Context, user dto.User) (*string, rest_err.APIError)
+	Edit(ctx context.Context, userInput dto.User) (*dto.User, rest_err.APIError)
+	Delete(ctx context.Context, userName string) rest_err.APIError
+	ChangePassword(ctx context.Context, input dto.User) (*dto.User, rest_err.APIError)
+	SetLanguage(ctx context.Context, userName string, language string, updatedAt int64) rest_err.APIError
+	ForcePasswordChange(ctx context.Context, userName string, hashPassword *string, updatedAt int64) rest_err.APIError
+	RehashPassword(ctx context.Context, userName string, oldHash string, newHash string) rest_err.APIError
+	MarkEmailVerified(ctx context.Context, userName string, email string, verifiedAt int64) (bool, rest_err.APIError)
+	Get(ctx context.Context, userName string) (*dto.User, rest_err.APIError)
+	GetByEmail(ctx context.Context, email string) (*dto.User, rest_err.APIError)
+	Find(ctx context.Context) ([]dto.User, rest_err.APIError)
 }
 
 type userDao struct {
+	log mlog.LoggerAssumer
+}
+
+// userRolesColumn subquery role user, role tersimpan pada table user_roles
+const userRolesColumn = `COALESCE((SELECT array_agg(ur.role ORDER BY ur.role) FROM user_roles ur WHERE ur.username = users.username), '{}')`
+
+// Insert menyimpan user beserta role nya dalam satu transaksi
+func (u *userDao) Insert(ctx context.Context, user dto.User) (*string, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "Insert", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "Insert", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	userName, err := insertUser(ctx, tx, user)
+	if err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "Insert", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "Insert", err)
+	}
+	usernameString := string(userName)
+	return &usernameString, nil
+}
+
+// InsertFirst menyimpan user hanya jika belum ada user sama sekali (user pertama instalasi baru),
+// mengembalikan nil jika sudah ada user. table dikunci agar dua request bersamaan tidak sama-sama menjadi user pertama
+func (u *userDao) InsertFirst(ctx context.Context, user dto.User) (*string, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "InsertFirst", time.Now())
+
+	tx, err := db.Conn(ctx).Begin(ctx)
+	if err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "InsertFirst", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, "LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE;"); err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "InsertFirst", err)
+	}
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users);").Scan(&exists); err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "InsertFirst", err)
+	}
+	if exists {
+		return nil, nil
+	}
+
+	userName, err := insertUser(ctx, tx, user)
+	if err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "InsertFirst", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "InsertFirst", err)
+	}
+	usernameString := string(userName)
+	return &usernameString, nil
 }
 
-func (u *userDao) Insert(user dto.User) (*string, rest_err.APIError) {
+// insertUser menyimpan user beserta role nya di dalam transaksi tx
+func insertUser(ctx context.Context, tx pgx.Tx, user dto.User) (dto.UppercaseString, error) {
 	sqlStatement := `
-	INSERT INTO users (username, email, name, password, role, created_at, updated_at) 
+	INSERT INTO users (username, email, name, password, email_verified_at, created_at, updated_at) 
 	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING username;
 	`
 	var userName dto.UppercaseString
-	err := db.DB.QueryRow(context.Background(), sqlStatement, user.Username, user.Email, user.Name, user.Password, user.Role, user.CreatedAt, user.UpdatedAt).Scan(&userName)
+	err := tx.QueryRow(ctx, sqlStatement, user.Username, user.Email, user.Name, user.Password, user.EmailVerifiedAt, user.CreatedAt, user.UpdatedAt).Scan(&userName)
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return "", err
 	}
-	usernameString := string(userName)
-	return &usernameString, nil
+	if err := replaceUserRoles(ctx, tx, userName, user.Roles); err != nil {
+		return "", err
+	}
+	return userName, nil
 }
 
-func (u *userDao) Edit(input dto.User) (*dto.User, rest_err.APIError) {
+func (u *userDao) Edit(ctx context.Context, input dto.User) (*dto.User, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "Edit", time.Now())
 	sqlStatement := `
 	UPDATE users 
-	SET email = $2, name = $3, role = $4, updated_at = $5
+	SET email = $2, name = $3, updated_at = $4, 
+	email_verified_at = CASE WHEN LOWER(email) = LOWER($2) THEN email_verified_at ELSE NULL END
 	WHERE username = $1 
-	RETURNING username, email, name, role, created_at, updated_at;
+	RETURNING username, email, name, ` + userRolesColumn + `, must_change_password, email_verified_at, language, created_at, updated_at;
 	`
 
 	var user dto.User
-	err := db.DB.QueryRow(
-		context.Background(),
-		sqlStatement, input.Username, input.Email, input.Name, input.Role, input.UpdatedAt,
-	).Scan(&user.Username, &user.Email, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	err := db.Conn(ctx).QueryRow(
+		ctx,
+		sqlStatement, input.Username, input.Email, input.Name, input.UpdatedAt,
+	).Scan(&user.Username, &user.Email, &user.Name, &user.Roles, &user.MustChangePassword, &user.EmailVerifiedAt, &user.Language, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return nil, parseQueryError(ctx, u.log, "user", "Edit", err)
 	}
 	return &user, nil
 }
 
-func (u *userDao) ChangePassword(input dto.User) (*dto.User, rest_err.APIError) {
+// ChangePassword mengganti password (hash) dan menghapus tanda wajib ganti password
+func (u *userDao) ChangePassword(ctx context.Context, input dto.User) (*dto.User, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "ChangePassword", time.Now())
 	sqlStatement := `
 	UPDATE users 
-	SET hash_pw = $2, updated_at = $3 
+	SET password = $2, must_change_password = FALSE, updated_at = $3 
 	WHERE username = $1 
-	RETURNING username, email, name, role, created_at, updated_at;
+	RETURNING username, email, name, ` + userRolesColumn + `, must_change_password, email_verified_at, language, created_at, updated_at;
 	`
 
 	var user dto.User
-	err := db.DB.QueryRow(context.Background(), sqlStatement, input.Username, input.Password, input.UpdatedAt).Scan(&user.Username, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	err := db.Conn(ctx).QueryRow(ctx, sqlStatement, input.Username, input.Password, input.UpdatedAt).
+		Scan(&user.Username, &user.Email, &user.Name, &user.Roles, &user.MustChangePassword, &user.EmailVerifiedAt, &user.Language, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return nil, parseQueryError(ctx, u.log, "user", "ChangePassword", err)
 	}
 	return &user, nil
 }
 
-func (u *userDao) Delete(userName string) rest_err.APIError {
+// SetLanguage mengganti preferensi bahasa user
+func (u *userDao) SetLanguage(ctx context.Context, userName string, language string, updatedAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("user", "SetLanguage", time.Now())
+	sqlStatement := `
+	UPDATE users 
+	SET language = $2, updated_at = $3 
+	WHERE username = $1;
+	`
+	res, err := db.Conn(ctx).Exec(ctx, sqlStatement, dto.UppercaseString(userName), language, updatedAt)
+	if err != nil {
+		return parseQueryError(ctx, u.log, "user", "SetLanguage", err)
+	}
+	if res.RowsAffected() != 1 {
+		return rest_err.NewNotFoundError("user.not_found", userName)
+	}
+	return nil
+}
+
+// RehashPassword mengganti hash password yang dibuat dengan parameter lama tanpa mengubah password nya.
+// hanya diganti jika hash belum berubah sejak dibaca, sehingga tidak menimpa password baru dari request lain
+func (u *userDao) RehashPassword(ctx context.Context, userName string, oldHash string, newHash string) rest_err.APIError {
+	defer mmetric.ObserveQuery("user", "RehashPassword", time.Now())
+	sqlStatement := `
+	UPDATE users 
+	SET password = $3 
+	WHERE username = $1 AND password = $2;
+	`
+
+	_, err := db.Conn(ctx).Exec(ctx, sqlStatement, userName, oldHash, newHash)
+	if err != nil {
+		return parseQueryError(ctx, u.log, "user", "RehashPassword", err)
+	}
+	return nil
+}
+
+// ForcePasswordChange menandai user wajib mengganti password, jika hashPassword tidak nil password juga diganti
+func (u *userDao) ForcePasswordChange(ctx context.Context, userName string, hashPassword *string, updatedAt int64) rest_err.APIError {
+	defer mmetric.ObserveQuery("user", "ForcePasswordChange", time.Now())
+	sqlStatement := `
+	UPDATE users 
+	SET must_change_password = TRUE, password = COALESCE($2, password), updated_at = $3 
+	WHERE username = $1;
+	`
+	res, err := db.Conn(ctx).Exec(ctx, sqlStatement, dto.UppercaseString(userName), hashPassword, updatedAt)
+	if err != nil {
+		return parseQueryError(ctx, u.log, "user", "ForcePasswordChange", err)
+	}
+	if res.RowsAffected() != 1 {
+		return rest_err.NewNotFoundError("user.not_found", userName)
+	}
+	return nil
+}
+
+// MarkEmailVerified menandai email terverifikasi selama email user masih sama dengan email pada tautan.
+// mengembalikan false jika email sudah diganti atau sudah terverifikasi sebelumnya
+func (u *userDao) MarkEmailVerified(ctx context.Context, userName string, email string, verifiedAt int64) (bool, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "MarkEmailVerified", time.Now())
+	sqlStatement := `
+	UPDATE users 
+	SET email_verified_at = $3 
+	WHERE username = $1 AND LOWER(email) = LOWER($2) AND email_verified_at IS NULL;
+	`
+	res, err := db.Conn(ctx).Exec(ctx, sqlStatement, dto.UppercaseString(userName), email, verifiedAt)
+	if err != nil {
+		return false, parseQueryError(ctx, u.log, "user", "MarkEmailVerified", err)
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+func (u *userDao) Delete(ctx context.Context, userName string) rest_err.APIError {
+	defer mmetric.ObserveQuery("user", "Delete", time.Now())
 	sqlStatement := `
 	DELETE FROM users 
 	WHERE username = $1;
 	`
-	res, err := db.DB.Exec(context.Background(), sqlStatement, dto.UppercaseString(userName))
+	res, err := db.Conn(ctx).Exec(ctx, sqlStatement, dto.UppercaseString(userName))
 	if err != nil {
-		return rest_err.NewInternalServerError("gagal saat penghapusan user", err)
+		// user yang masih memiliki product ditolak oleh foreign key (409)
+		return parseQueryError(ctx, u.log, "user", "Delete", err)
 	}
 	if res.RowsAffected() != 1 {
-		return rest_err.NewBadRequestError(fmt.Sprintf("User dengan username %s tidak ditemukan", userName))
+		return rest_err.NewNotFoundError("user.not_found", userName)
 	}
 
 	return nil
 }
 
-func (u *userDao) Get(userName string) (*dto.User, rest_err.APIError) {
+func (u *userDao) Get(ctx context.Context, userName string) (*dto.User, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "Get", time.Now())
 
 	sqlStatement := `
-	SELECT username, email, name, password, role, created_at, updated_at 
+	SELECT username, email, name, password, ` + userRolesColumn + `, must_change_password, email_verified_at, language, created_at, updated_at 
 	FROM users 
 	WHERE username = $1;
 	`
-	row := db.DB.QueryRow(context.Background(), sqlStatement, dto.UppercaseString(userName))
+	row := db.Conn(ctx).QueryRow(ctx, sqlStatement, dto.UppercaseString(userName))
+
+	var user dto.User
+	err := row.Scan(&user.Username, &user.Email, &user.Name, &user.Password, &user.Roles, &user.MustChangePassword, &user.EmailVerifiedAt, &user.Language, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, parseQueryError(ctx, u.log, "user", "Get", err)
+	}
+	return &user, nil
+}
+
+// GetByEmail mengembalikan user berdasarkan email (tidak case sensitive), nil jika tidak ditemukan
+func (u *userDao) GetByEmail(ctx context.Context, email string) (*dto.User, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "GetByEmail", time.Now())
+
+	sqlStatement := `
+	SELECT username, email, name, password, ` + userRolesColumn + `, must_change_password, email_verified_at, language, created_at, updated_at 
+	FROM users 
+	WHERE LOWER(email) = LOWER($1);
+	`
+	row := db.Conn(ctx).QueryRow(ctx, sqlStatement, email)
 
 	var user dto.User
-	err := row.Scan(&user.Username, &user.Email, &user.Name, &user.Password, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	err := row.Scan(&user.Username, &user.Email, &user.Name, &user.Password, &user.Roles, &user.MustChangePassword, &user.EmailVerifiedAt, &user.Language, &user.CreatedAt, &user.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, sql_err.ParseError(err)
+		return nil, parseQueryError(ctx, u.log, "user", "GetByEmail", err)
 	}
 	return &user, nil
 }
 
-func (u *userDao) Find() ([]dto.User, rest_err.APIError) {
-	rows, err := db.DB.Query(context.Background(),
-		"SELECT username, email, name, role, created_at, updated_at  FROM users;")
+func (u *userDao) Find(ctx context.Context) ([]dto.User, rest_err.APIError) {
+	defer mmetric.ObserveQuery("user", "Find", time.Now())
+	rows, err := db.Conn(ctx).Query(ctx,
+		"SELECT username, email, name, "+userRolesColumn+", must_change_password, email_verified_at, language, created_at, updated_at FROM users;")
 	if err != nil {
-		return nil, rest_err.NewInternalServerError("gagal mendapatkan daftar user", err)
+		logQueryError(ctx, u.log, "user", "Find", err)
+		return nil, rest_err.NewInternalServerError("user.find_failed", err)
 	}
 
 	defer rows.Close()
 	var users []dto.User
 	for rows.Next() {
 		user := dto.User{}
-		err := rows.Scan(&user.Username, &user.Email, &user.Name, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.Username, &user.Email, &user.Name, &user.Roles, &user.MustChangePassword, &user.EmailVerifiedAt, &user.Language, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
-			return nil, sql_err.ParseError(err)
+			return nil, parseQueryError(ctx, u.log, "user", "Find", err)
 		}
 		users = append(users, user)
 	}
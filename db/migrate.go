@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+const migrationTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version VARCHAR(100) PRIMARY KEY,
+    applied_at BIGINT NOT NULL
+);
+`
+
+// migrationVersions mengembalikan daftar nama file migrasi yang terurut
+// nama file (tanpa .sql) digunakan sebagai versi
+func migrationVersions() ([]string, error) {
+	files, err := fs.Glob(migrationFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(files))
+	for _, file := range files {
+		versions = append(versions, strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql"))
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Migrate menjalankan semua file migrasi yang belum pernah dijalankan
+// setiap file dijalankan di dalam transaksi masing-masing
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	if _, err := pool.Exec(ctx, migrationTable); err != nil {
+		return fmt.Errorf("gagal membuat table schema_migrations: %w", err)
+	}
+
+	pending, err := PendingMigrations(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range pending {
+		content, err := migrationFS.ReadFile("migrations/" + version + ".sql")
+		if err != nil {
+			return err
+		}
+
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, string(content)); err != nil {
+			_ = tx.Rollback(ctx)
+			return fmt.Errorf("migrasi %s gagal: %w", version, err)
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2);", version, time.Now().Unix()); err != nil {
+			_ = tx.Rollback(ctx)
+			return fmt.Errorf("migrasi %s gagal dicatat: %w", version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PendingMigrations mengembalikan versi migrasi yang belum dijalankan pada database
+func PendingMigrations(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	versions, err := migrationVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, "SELECT version FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, version := range versions {
+		if !applied[version] {
+			pending = append(pending, version)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS users (
+    username VARCHAR ( 50 ) PRIMARY KEY,
+    name VARCHAR (100) NOT NULL,
+    email VARCHAR ( 255 ) UNIQUE NOT NULL,
+    password VARCHAR (100) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    product_id SERIAL PRIMARY KEY,
+    name VARCHAR (100) UNIQUE NOT NULL,
+    price BIGINT,
+    image VARCHAR(50),
+    created_by VARCHAR REFERENCES users(username),
+    created_at BIGINT NOT NULL
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    expires_at BIGINT NOT NULL,
+    revoked_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+-- token milik username dengan iat <= revoked_before dianggap dicabut
+CREATE TABLE IF NOT EXISTS user_token_revocations (
+    username VARCHAR(50) PRIMARY KEY REFERENCES users(username) ON DELETE CASCADE,
+    revoked_before BIGINT NOT NULL
+);
@@ -0,0 +1,9 @@
+-- kunci asimetris untuk JWT_ALG RS256/EdDSA, private_key dienkripsi dengan turunan SECRET_KEY
+CREATE TABLE IF NOT EXISTS jwt_keys (
+    kid VARCHAR(64) PRIMARY KEY,
+    alg VARCHAR(10) NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    activates_at BIGINT NOT NULL,
+    retires_at BIGINT
+);
@@ -0,0 +1,66 @@
+-- role dan permission disimpan di database agar role baru tidak memerlukan deploy ulang
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+-- satu user dapat memiliki banyak role
+CREATE TABLE IF NOT EXISTS user_roles (
+    username VARCHAR(50) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    PRIMARY KEY (username, role)
+);
+
+CREATE INDEX IF NOT EXISTS user_roles_role_idx ON user_roles (role);
+
+INSERT INTO permissions (name, description) VALUES
+    ('product:read', 'melihat produk'),
+    ('product:write', 'menambah, mengedit dan mengupload gambar produk'),
+    ('product:delete', 'menghapus produk'),
+    ('user:write', 'meregistrasi dan mengedit user'),
+    ('user:delete', 'menghapus user'),
+    ('user:revoke', 'mencabut semua token user'),
+    ('login:unlock', 'membuka kunci login'),
+    ('role:manage', 'mengelola role, permission role dan role user'),
+    ('metrics:read', 'membaca metric prometheus'),
+    ('order:manage', 'mengelola order')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO roles (name, description, created_at) VALUES
+    ('ADMIN', 'akses penuh', EXTRACT(EPOCH FROM NOW())::BIGINT),
+    ('NORMAL', 'user biasa', EXTRACT(EPOCH FROM NOW())::BIGINT)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'ADMIN', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+-- hak akses NORMAL sama dengan sebelum adanya permission
+INSERT INTO role_permissions (role, permission) VALUES
+    ('NORMAL', 'product:read'),
+    ('NORMAL', 'product:write'),
+    ('NORMAL', 'product:delete')
+ON CONFLICT DO NOTHING;
+
+-- memindahkan role tunggal pada users ke user_roles
+INSERT INTO roles (name, created_at)
+SELECT DISTINCT role, EXTRACT(EPOCH FROM NOW())::BIGINT FROM users
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_roles (username, role)
+SELECT username, role FROM users
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
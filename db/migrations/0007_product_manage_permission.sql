@@ -0,0 +1,9 @@
+-- pemilik role dengan product:manage dapat mengubah dan menghapus produk milik user lain,
+-- tanpa permission ini user hanya dapat mengubah produk yang dibuatnya sendiri
+INSERT INTO permissions (name, description) VALUES
+    ('product:manage', 'mengubah dan menghapus semua produk termasuk milik user lain')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('ADMIN', 'product:manage')
+ON CONFLICT DO NOTHING;
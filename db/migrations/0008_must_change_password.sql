@@ -0,0 +1,2 @@
+-- diisi ketika admin memaksa reset password, user wajib mengganti password setelah login
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,10 @@
+-- token reset password sekali pakai, yang disimpan hanya hash sha256 nya
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(50) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    used_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_tokens_username_idx ON password_reset_tokens (username);
@@ -0,0 +1,4 @@
+-- NULL berarti email belum diverifikasi, akun yang sudah ada sebelum fitur ini dianggap terverifikasi
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at BIGINT;
+
+UPDATE users SET email_verified_at = updated_at WHERE email_verified_at IS NULL;
@@ -0,0 +1,22 @@
+-- TOTP 2FA per user. secret tersimpan sejak enrolment dimulai, enabled_at terisi setelah kode pertama diverifikasi.
+-- last_used_step mencegah kode yang sama digunakan dua kali
+CREATE TABLE IF NOT EXISTS user_mfa (
+    username VARCHAR(50) PRIMARY KEY REFERENCES users(username) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at BIGINT,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL
+);
+
+-- recovery code sekali pakai, yang disimpan hanya hash sha256 nya
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(50) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    used_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS mfa_recovery_codes_username_idx ON mfa_recovery_codes (username);
+
+-- role yang mewajibkan 2FA, user dengan role tersebut harus enrolment sebelum mendapatkan token
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS mfa_required BOOLEAN NOT NULL DEFAULT FALSE;
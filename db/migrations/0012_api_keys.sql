@@ -0,0 +1,24 @@
+-- api key untuk client mesin (integrasi), bertindak atas nama user pemiliknya dengan permission terbatas pada scopes.
+-- id adalah bagian publik dari key untuk pencarian, yang disimpan hanya hash sha256 dari key lengkap
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(16) PRIMARY KEY,
+    key_hash VARCHAR(64) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    username VARCHAR(50) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    scopes VARCHAR(50)[] NOT NULL DEFAULT '{}',
+    created_by VARCHAR(50),
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT,
+    last_used_at BIGINT,
+    revoked_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_username_idx ON api_keys (username);
+
+INSERT INTO permissions (name, description) VALUES
+    ('apikey:manage', 'menerbitkan, melihat dan mencabut api key')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('ADMIN', 'apikey:manage')
+ON CONFLICT DO NOTHING;
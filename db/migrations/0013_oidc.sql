@@ -0,0 +1,23 @@
+-- identitas user pada identity provider eksternal (OIDC), satu user dapat memiliki beberapa identitas
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    username VARCHAR(50) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    email VARCHAR(255),
+    created_at BIGINT NOT NULL,
+    last_login_at BIGINT,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_username_idx ON user_identities (username);
+
+-- state login OIDC yang menunggu callback, sekali pakai. yang disimpan hanya hash dari state,
+-- code verifier PKCE dan nonce tidak pernah dikirim ke browser
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    code_verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    used_at BIGINT
+);
@@ -0,0 +1,2 @@
+-- hash argon2id berformat PHC lebih panjang dari bcrypt dan panjangnya bergantung pada parameter
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR (255);
@@ -0,0 +1,75 @@
+-- organisasi (toko) pemilik data, user dapat menjadi anggota beberapa organisasi
+CREATE TABLE IF NOT EXISTS organizations (
+    org_id VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS org_members (
+    org_id VARCHAR(50) NOT NULL REFERENCES organizations(org_id) ON DELETE CASCADE,
+    username VARCHAR(50) NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    created_at BIGINT NOT NULL,
+    PRIMARY KEY (org_id, username)
+);
+
+CREATE INDEX IF NOT EXISTS org_members_username_idx ON org_members (username);
+
+-- role org_scoped hanya dapat diberikan di dalam organisasi dan sebaliknya,
+-- sehingga admin organisasi tidak dapat memberikan role global seperti ADMIN
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS org_scoped BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS org_member_roles (
+    org_id VARCHAR(50) NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    PRIMARY KEY (org_id, username, role),
+    FOREIGN KEY (org_id, username) REFERENCES org_members(org_id, username) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (name, description) VALUES
+    ('org:manage', 'membuat organisasi dan melihat semua organisasi'),
+    ('org:members', 'mengelola anggota organisasi aktif')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO roles (name, description, org_scoped, created_at) VALUES
+    ('ORG_ADMIN', 'admin organisasi', TRUE, EXTRACT(EPOCH FROM NOW())::BIGINT),
+    ('ORG_MEMBER', 'anggota organisasi', TRUE, EXTRACT(EPOCH FROM NOW())::BIGINT)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('ADMIN', 'org:manage'),
+    ('ORG_ADMIN', 'product:read'),
+    ('ORG_ADMIN', 'product:write'),
+    ('ORG_ADMIN', 'product:delete'),
+    ('ORG_ADMIN', 'product:manage'),
+    ('ORG_ADMIN', 'org:members'),
+    ('ORG_MEMBER', 'product:read'),
+    ('ORG_MEMBER', 'product:write'),
+    ('ORG_MEMBER', 'product:delete')
+ON CONFLICT DO NOTHING;
+
+-- data yang sudah ada dipindahkan ke organisasi default, user ADMIN menjadi ORG_ADMIN
+INSERT INTO organizations (org_id, name, created_at) VALUES
+    ('default', 'Default', EXTRACT(EPOCH FROM NOW())::BIGINT)
+ON CONFLICT (org_id) DO NOTHING;
+
+INSERT INTO org_members (org_id, username, created_at)
+SELECT 'default', username, EXTRACT(EPOCH FROM NOW())::BIGINT FROM users
+ON CONFLICT DO NOTHING;
+
+INSERT INTO org_member_roles (org_id, username, role)
+SELECT 'default', u.username,
+    CASE WHEN EXISTS (SELECT 1 FROM user_roles ur WHERE ur.username = u.username AND ur.role = 'ADMIN')
+        THEN 'ORG_ADMIN' ELSE 'ORG_MEMBER' END
+FROM users u
+ON CONFLICT DO NOTHING;
+
+-- nama product unik per organisasi
+ALTER TABLE products ADD COLUMN IF NOT EXISTS org_id VARCHAR(50) REFERENCES organizations(org_id) ON DELETE CASCADE;
+UPDATE products SET org_id = 'default' WHERE org_id IS NULL;
+ALTER TABLE products ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS products_org_name_idx ON products (org_id, name);
+
+-- api key dapat dibatasi pada satu organisasi
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS org_id VARCHAR(50) REFERENCES organizations(org_id) ON DELETE CASCADE;
@@ -0,0 +1,86 @@
+-- role database untuk query atas nama principal (user atau api key) yang sedang login.
+-- aplikasi tetap terhubung dengan user database biasa lalu berpindah ke role ini per request (SET LOCAL ROLE),
+-- sehingga row level security tetap berlaku walaupun user database adalah pemilik table atau superuser
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'sagasql_principal') THEN
+        CREATE ROLE sagasql_principal NOLOGIN;
+    END IF;
+    -- user aplikasi harus menjadi member agar dapat berpindah ke role ini
+    IF NOT pg_has_role(current_user, 'sagasql_principal', 'MEMBER') THEN
+        EXECUTE format('GRANT sagasql_principal TO %I', current_user);
+    END IF;
+END
+$$;
+
+GRANT USAGE ON SCHEMA public TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO sagasql_principal;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO sagasql_principal;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO sagasql_principal;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO sagasql_principal;
+
+-- principal diset oleh aplikasi di awal transaksi request (db.BeginPrincipal)
+CREATE OR REPLACE FUNCTION app_username() RETURNS TEXT
+LANGUAGE sql STABLE AS $$
+    SELECT UPPER(NULLIF(current_setting('app.username', true), ''))
+$$;
+
+CREATE OR REPLACE FUNCTION app_org_id() RETURNS TEXT
+LANGUAGE sql STABLE AS $$
+    SELECT NULLIF(current_setting('app.org_id', true), '')
+$$;
+
+-- app_has_permission true jika salah satu role principal (global maupun organisasi aktif) memiliki salah satu permission
+CREATE OR REPLACE FUNCTION app_has_permission(VARIADIC perms TEXT[]) RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM role_permissions
+        WHERE role = ANY (string_to_array(NULLIF(current_setting('app.roles', true), ''), ','))
+        AND permission = ANY (perms)
+    )
+$$;
+
+-- product hanya terlihat di organisasi aktif, perubahan hanya oleh pembuatnya atau pemilik product:manage
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS products_select ON products;
+CREATE POLICY products_select ON products FOR SELECT TO sagasql_principal
+    USING (org_id = app_org_id());
+
+DROP POLICY IF EXISTS products_insert ON products;
+CREATE POLICY products_insert ON products FOR INSERT TO sagasql_principal
+    WITH CHECK (org_id = app_org_id() AND UPPER(created_by) = app_username());
+
+DROP POLICY IF EXISTS products_update ON products;
+CREATE POLICY products_update ON products FOR UPDATE TO sagasql_principal
+    USING (org_id = app_org_id() AND (UPPER(created_by) = app_username() OR app_has_permission('product:manage')))
+    WITH CHECK (org_id = app_org_id());
+
+DROP POLICY IF EXISTS products_delete ON products;
+CREATE POLICY products_delete ON products FOR DELETE TO sagasql_principal
+    USING (org_id = app_org_id() AND (UPPER(created_by) = app_username() OR app_has_permission('product:manage')));
+
+-- user hanya dapat membaca dirinya sendiri dan anggota organisasi aktif,
+-- kecuali principal yang mengelola user, role, api key atau anggota organisasi
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS users_select ON users;
+CREATE POLICY users_select ON users FOR SELECT TO sagasql_principal
+    USING (
+        username = app_username()
+        OR EXISTS (SELECT 1 FROM org_members m WHERE m.username = users.username AND m.org_id = app_org_id())
+        OR app_has_permission('user:write', 'user:delete', 'user:revoke', 'role:manage', 'apikey:manage', 'org:manage', 'org:members')
+    );
+
+DROP POLICY IF EXISTS users_insert ON users;
+CREATE POLICY users_insert ON users FOR INSERT TO sagasql_principal
+    WITH CHECK (app_has_permission('user:write'));
+
+DROP POLICY IF EXISTS users_update ON users;
+CREATE POLICY users_update ON users FOR UPDATE TO sagasql_principal
+    USING (username = app_username() OR app_has_permission('user:write'))
+    WITH CHECK (username = app_username() OR app_has_permission('user:write'));
+
+DROP POLICY IF EXISTS users_delete ON users;
+CREATE POLICY users_delete ON users FOR DELETE TO sagasql_principal
+    USING (app_has_permission('user:delete'));
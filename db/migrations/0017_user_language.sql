@@ -0,0 +1,2 @@
+-- preferensi bahasa pesan response (id, en), kosong jika mengikuti header Accept-Language
+ALTER TABLE users ADD COLUMN language VARCHAR (10) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- batas pencabutan disimpan dalam milidetik agar token yang diterbitkan pada detik yang sama
+-- setelah "cabut semua" (login ulang, perubahan role) tidak ikut ditolak.
+-- token milik username dengan waktu terbit (milidetik) < revoked_before_ms dianggap dicabut,
+-- nilai lama (iat <= revoked_before dalam detik) dikonversi menjadi batas eksklusif yang setara
+ALTER TABLE user_token_revocations RENAME COLUMN revoked_before TO revoked_before_ms;
+UPDATE user_token_revocations SET revoked_before_ms = (revoked_before_ms + 1) * 1000;
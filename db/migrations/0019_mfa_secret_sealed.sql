@@ -0,0 +1,3 @@
+-- secret TOTP disimpan terenkripsi AES-GCM (base64) menggunakan turunan SECRET_KEY seperti kunci privat jwt,
+-- secret lama berupa base32 dienkripsi oleh aplikasi ketika dijalankan (lihat MFAService.SealLegacySecrets)
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE VARCHAR (255);
@@ -0,0 +1,24 @@
+-- pengelola anggota organisasi tidak lagi dapat membaca semua user, user ditambahkan melalui undangan email
+DROP POLICY IF EXISTS users_select ON users;
+CREATE POLICY users_select ON users FOR SELECT TO sagasql_principal
+    USING (
+        username = app_username()
+        OR EXISTS (SELECT 1 FROM org_members m WHERE m.username = users.username AND m.org_id = app_org_id())
+        OR app_has_permission('user:write', 'user:delete', 'user:revoke', 'role:manage', 'apikey:manage')
+    );
+
+-- undangan menjadi anggota organisasi, dikirim ke email dan diterima oleh user pemilik email tersebut.
+-- yang disimpan hanya hash sha256 dari token undangan
+CREATE TABLE IF NOT EXISTS org_invitations (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    org_id VARCHAR(50) NOT NULL REFERENCES organizations(org_id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    roles VARCHAR(50)[] NOT NULL DEFAULT '{}',
+    invited_by VARCHAR(50),
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    accepted_by VARCHAR(50),
+    accepted_at BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS org_invitations_org_email_idx ON org_invitations (org_id, LOWER(email));
@@ -0,0 +1,37 @@
+-- hak akses sagasql_principal dibatasi pada table dan operasi yang dipakai request ber-autentikasi.
+-- table yang hanya dipakai route publik, middleware sebelum scope principal atau proses background
+-- (jwt_keys, oidc_login_states, user_identities, password_reset_tokens, schema_migrations) tidak dapat diakses.
+-- table baru tidak lagi otomatis dapat diakses, grant ditambahkan pada migrasi yang membuat table tersebut
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM sagasql_principal;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE USAGE, SELECT ON SEQUENCES FROM sagasql_principal;
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM sagasql_principal;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM sagasql_principal;
+
+-- user dan product, baris dibatasi oleh row level security (0016_row_level_security)
+GRANT SELECT, INSERT, UPDATE, DELETE ON users TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE, DELETE ON products TO sagasql_principal;
+GRANT USAGE, SELECT ON SEQUENCE products_product_id_seq TO sagasql_principal;
+
+-- rbac, role_permissions juga dibaca oleh app_has_permission()
+GRANT SELECT, INSERT, UPDATE, DELETE ON roles TO sagasql_principal;
+GRANT SELECT ON permissions TO sagasql_principal;
+GRANT SELECT, INSERT, DELETE ON role_permissions TO sagasql_principal;
+GRANT SELECT, INSERT, DELETE ON user_roles TO sagasql_principal;
+
+-- organisasi, org_members juga dibaca oleh policy users_select
+GRANT SELECT, INSERT ON organizations TO sagasql_principal;
+GRANT SELECT, INSERT, DELETE ON org_members TO sagasql_principal;
+GRANT SELECT, INSERT, DELETE ON org_member_roles TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE, DELETE ON org_invitations TO sagasql_principal;
+
+-- api key, 2FA, pencabutan token dan sesi refresh token
+GRANT SELECT, INSERT, UPDATE ON api_keys TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE, DELETE ON user_mfa TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE, DELETE ON mfa_recovery_codes TO sagasql_principal;
+GRANT SELECT, INSERT, DELETE ON revoked_tokens TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE ON user_token_revocations TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE ON refresh_token_families TO sagasql_principal;
+GRANT SELECT, INSERT, UPDATE ON refresh_tokens TO sagasql_principal;
+
+-- membuka kunci login (/login/unlock)
+GRANT SELECT, DELETE ON login_attempts TO sagasql_principal;
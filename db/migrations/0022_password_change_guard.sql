@@ -0,0 +1,3 @@
+-- password lama yang salah pada /profile/password dihitung sebagai login gagal,
+-- sehingga request ber-autentikasi juga mencatat kegagalan dan mengunci login_attempts
+GRANT INSERT, UPDATE ON login_attempts TO sagasql_principal;
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"strings"
+)
+
+// PrincipalRole role database yang digunakan selama request milik principal (user atau api key).
+// role ini tidak memiliki BYPASSRLS sehingga policy row level security pada table products dan users selalu berlaku,
+// dibuat oleh migrasi 0016_row_level_security
+const PrincipalRole = "sagasql_principal"
+
+// Querier method yang dimiliki bersama oleh *pgxpool.Pool dan pgx.Tx,
+// Begin pada pgx.Tx membuat savepoint sehingga transaksi dao tetap berjalan di dalam transaksi request
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Principal identitas yang diteruskan ke database, dibaca oleh policy melalui app_username(), app_org_id() dan app_has_permission()
+type Principal struct {
+	Username string
+	Roles    []string
+	OrgID    string
+}
+
+type principalScopeKey struct{}
+
+// principalScope transaksi principal milik satu request. transaksi baru dimulai pada query pertama
+// sehingga koneksi tidak ditahan oleh request yang tidak menyentuh database atau sedang menunggu I/O lain.
+// seperti pgx.Tx, scope tidak aman dipakai bersamaan oleh beberapa goroutine
+type principalScope struct {
+	principal   Principal
+	tx          pgx.Tx
+	afterCommit []func()
+}
+
+// WithPrincipal mengembalikan ctx yang query nya (melalui Conn) dijalankan di dalam transaksi atas nama principal.
+// pemanggil wajib mengakhiri scope dengan Commit atau Rollback
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalScopeKey{}, &principalScope{principal: principal})
+}
+
+// Conn mengembalikan transaksi principal di dalam ctx jika ada (dimulai jika belum), selain itu pool.
+// semua query dao menggunakan Conn agar satu request hanya memakai satu koneksi dan policy principal berlaku
+func Conn(ctx context.Context) Querier {
+	scope, ok := ctx.Value(principalScopeKey{}).(*principalScope)
+	if !ok {
+		return DB
+	}
+	if scope.tx == nil {
+		tx, err := beginPrincipal(ctx, scope.principal)
+		if err != nil {
+			return failedQuerier{err: err}
+		}
+		scope.tx = tx
+	}
+	return scope.tx
+}
+
+// Commit menyimpan transaksi principal dan mengembalikan koneksinya ke pool, lalu menjalankan fungsi AfterCommit.
+// query berikutnya pada ctx yang sama memulai transaksi baru, sehingga handler dapat memanggil Commit
+// sebelum pekerjaan lambat (misal menyimpan file) agar koneksi tidak ditahan selama pekerjaan tersebut
+func Commit(ctx context.Context) error {
+	scope, ok := ctx.Value(principalScopeKey{}).(*principalScope)
+	if !ok {
+		return nil
+	}
+	if scope.tx != nil {
+		tx := scope.tx
+		scope.tx = nil
+		if err := tx.Commit(ctx); err != nil {
+			scope.afterCommit = nil
+			return err
+		}
+	}
+	hooks := scope.afterCommit
+	scope.afterCommit = nil
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
+
+// Rollback membatalkan transaksi principal yang belum di-commit beserta fungsi AfterCommit nya,
+// tidak berpengaruh jika transaksi sudah di-commit
+func Rollback(ctx context.Context) {
+	scope, ok := ctx.Value(principalScopeKey{}).(*principalScope)
+	if !ok {
+		return
+	}
+	scope.afterCommit = nil
+	if scope.tx != nil {
+		_ = scope.tx.Rollback(ctx)
+		scope.tx = nil
+	}
+}
+
+// AfterCommit menjalankan fn setelah transaksi principal berhasil di-commit, fn dibuang jika transaksi dibatalkan.
+// digunakan untuk efek di luar database (cache, email) agar tidak terjadi untuk perubahan yang tidak tersimpan.
+// tanpa transaksi principal perubahan sudah tersimpan sehingga fn langsung dijalankan
+func AfterCommit(ctx context.Context, fn func()) {
+	scope, ok := ctx.Value(principalScopeKey{}).(*principalScope)
+	if !ok {
+		fn()
+		return
+	}
+	scope.afterCommit = append(scope.afterCommit, fn)
+}
+
+// beginPrincipal mengambil satu koneksi dari pool lalu memulai transaksi atas nama principal.
+// role, username, roles dan organisasi diset dengan scope transaksi (setara SET LOCAL)
+// sehingga otomatis hilang ketika koneksi dikembalikan ke pool
+func beginPrincipal(ctx context.Context, principal Principal) (pgx.Tx, error) {
+	tx, err := DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+	SELECT set_config('role', $1, true),
+		set_config('app.username', $2, true),
+		set_config('app.roles', $3, true),
+		set_config('app.org_id', $4, true);
+	`, PrincipalRole, principal.Username, strings.Join(principal.Roles, ","), principal.OrgID)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// failedQuerier dikembalikan Conn jika transaksi principal gagal dimulai, setiap query mengembalikan error tersebut
+// sehingga ditangani dao seperti error query lainnya
+type failedQuerier struct {
+	err error
+}
+
+func (f failedQuerier) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return nil, f.err
+}
+
+func (f failedQuerier) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return nil, f.err
+}
+
+func (f failedQuerier) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return failedRow(f)
+}
+
+func (f failedQuerier) Begin(context.Context) (pgx.Tx, error) {
+	return nil, f.err
+}
+
+type failedRow failedQuerier
+
+func (f failedRow) Scan(...interface{}) error {
+	return f.err
+}
@@ -0,0 +1,35 @@
+package dto
+
+// APIKey api key client mesin, key lengkap hanya ditampilkan sekali ketika dibuat.
+// OwnerRoles role user pemilik yang dibaca bersamaan ketika autentikasi
+type APIKey struct {
+	ID         string   `json:"id"`
+	KeyHash    string   `json:"-"`
+	Name       string   `json:"name"`
+	Username   string   `json:"username"`
+	Scopes     []string `json:"scopes"`
+	OwnerRoles []string `json:"-"`
+	OrgID      string   `json:"org_id"`
+	CreatedBy  string   `json:"created_by"`
+	CreatedAt  int64    `json:"created_at"`
+	ExpiresAt  *int64   `json:"expires_at"`
+	LastUsedAt *int64   `json:"last_used_at"`
+	RevokedAt  *int64   `json:"revoked_at"`
+}
+
+// APIKeyRequest membuat api key, Username adalah user yang diwakili api key (disarankan user khusus integrasi).
+// ExpiresAt unix detik, 0 berarti tidak kadaluarsa. OrgID organisasi yang dapat diakses api key,
+// user harus menjadi anggota organisasi tersebut
+type APIKeyRequest struct {
+	Name      string   `json:"name"`
+	Username  string   `json:"username"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt int64    `json:"expires_at"`
+	OrgID     string   `json:"org_id"`
+}
+
+// APIKeyCreateResponse Key hanya ditampilkan sekali dan tidak dapat dilihat kembali
+type APIKeyCreateResponse struct {
+	Key string `json:"key"`
+	APIKey
+}
@@ -0,0 +1,19 @@
+package dto
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Validate input
+func (a APIKeyRequest) Validate() error {
+	if err := validation.ValidateStruct(&a,
+		validation.Field(&a.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&a.Username, validation.Required),
+		validation.Field(&a.Scopes, validation.Required, validation.Each(validation.Required)),
+		validation.Field(&a.ExpiresAt, validation.Min(int64(0))),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+package dto
+
+// JWTKey kunci tanda tangan jwt yang tersimpan di database.
+// PrivateKey sudah dalam bentuk terenkripsi (lihat mjwt.SealPrivateKey)
+type JWTKey struct {
+	KID         string `json:"kid"`
+	Algorithm   string `json:"alg"`
+	PrivateKey  string `json:"-"`
+	CreatedAt   int64  `json:"created_at"`
+	ActivatesAt int64  `json:"activates_at"`
+	RetiresAt   *int64 `json:"retires_at"`
+}
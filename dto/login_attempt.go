@@ -0,0 +1,28 @@
+package dto
+
+// Jenis kunci pada table login_attempts
+const (
+	LoginKeyUsername = "username"
+	LoginKeyIP       = "ip"
+	// ResetKeyEmail dan ResetKeyIP menghitung permintaan reset password
+	ResetKeyEmail = "reset_email"
+	ResetKeyIP    = "reset_ip"
+	// VerifyKeyEmail dan VerifyKeyIP menghitung permintaan kirim ulang tautan verifikasi email
+	VerifyKeyEmail = "verify_email"
+	VerifyKeyIP    = "verify_ip"
+)
+
+// LoginAttempt catatan percobaan login yang gagal berdasarkan username atau ip
+type LoginAttempt struct {
+	KeyType      string `json:"key_type"`
+	Key          string `json:"key"`
+	FailedCount  int    `json:"failed_count"`
+	LastFailedAt int64  `json:"last_failed_at"`
+	LockedUntil  int64  `json:"locked_until"`
+}
+
+// LoginUnlockRequest digunakan admin untuk membuka kunci login, salah satu field wajib diisi
+type LoginUnlockRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Validate input
+func (l LoginUnlockRequest) Validate() error {
+	if err := validation.ValidateStruct(&l,
+		validation.Field(&l.Username, validation.Required.When(l.IP == "").Error("validation.username_or_ip_required")),
+		validation.Field(&l.IP, is.IP),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
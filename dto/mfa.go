@@ -0,0 +1,43 @@
+package dto
+
+// UserMFA status TOTP user, EnabledAt nil jika enrolment belum selesai.
+// Secret terenkripsi ketika dibaca dari dao, service membukanya dengan mjwt.OpenSecret
+type UserMFA struct {
+	Username     string `json:"username"`
+	Secret       string `json:"-"`
+	EnabledAt    *int64 `json:"enabled_at"`
+	LastUsedStep int64  `json:"-"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// MFAEnrollResponse secret dan otpauth uri untuk didaftarkan pada aplikasi authenticator (uri dapat dijadikan QR code)
+type MFAEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFACodeRequest kode TOTP 6 digit atau recovery code
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// MFARecoveryCodesResponse recovery code hanya ditampilkan sekali
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFALoginRequest langkah kedua login, MFAToken didapat dari response login
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// MFAEnrollLoginRequest enrolment ketika login untuk user yang role nya mewajibkan 2FA
+type MFAEnrollLoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+// RoleMFARequest mengatur kewajiban 2FA untuk role
+type RoleMFARequest struct {
+	Required bool `json:"required"`
+}
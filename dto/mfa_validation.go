@@ -0,0 +1,39 @@
+package dto
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Validate input
+func (m MFACodeRequest) Validate() error {
+	if err := validation.ValidateStruct(&m,
+		validation.Field(&m.Code, validation.Required, validation.Length(6, 20)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (m MFALoginRequest) Validate() error {
+	if err := validation.ValidateStruct(&m,
+		validation.Field(&m.MFAToken, validation.Required),
+		validation.Field(&m.Code, validation.Required, validation.Length(6, 20)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (m MFAEnrollLoginRequest) Validate() error {
+	if err := validation.ValidateStruct(&m,
+		validation.Field(&m.MFAToken, validation.Required),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
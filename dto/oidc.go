@@ -0,0 +1,27 @@
+package dto
+
+// OIDCLoginState state authorization request yang menunggu callback
+type OIDCLoginState struct {
+	StateHash    string
+	CodeVerifier string
+	Nonce        string
+	CreatedAt    int64
+	ExpiresAt    int64
+}
+
+// UserIdentity identitas user pada identity provider eksternal
+type UserIdentity struct {
+	Issuer      string `json:"issuer"`
+	Subject     string `json:"subject"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	CreatedAt   int64  `json:"created_at"`
+	LastLoginAt *int64 `json:"last_login_at"`
+}
+
+// OIDCLoginResponse alamat halaman login identity provider.
+// State dikirim handler sebagai cookie HttpOnly untuk mengikat callback ke browser yang memulai login
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"-"`
+}
@@ -0,0 +1,64 @@
+package dto
+
+// Organization organisasi (toko) pemilik data
+type Organization struct {
+	OrgID     string `json:"org_id"`
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// OrgMembership organisasi tempat user menjadi anggota beserta role nya di organisasi tersebut
+type OrgMembership struct {
+	OrgID     string   `json:"org_id"`
+	Name      string   `json:"name"`
+	Roles     []string `json:"roles"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// OrgMember anggota organisasi
+type OrgMember struct {
+	Username  string   `json:"username"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// OrgRequest membuat organisasi, Admin adalah username yang menjadi ORG_ADMIN pertama
+type OrgRequest struct {
+	OrgID string `json:"org_id"`
+	Name  string `json:"name"`
+	Admin string `json:"admin"`
+}
+
+// OrgMemberRequest mengganti seluruh role anggota organisasi aktif
+type OrgMemberRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// OrgSwitchRequest mengganti organisasi aktif pada token
+type OrgSwitchRequest struct {
+	OrgID string `json:"org_id"`
+}
+
+// OrgInvitation undangan menjadi anggota organisasi, TokenHash adalah sha256 dari token yang dikirim via email
+type OrgInvitation struct {
+	TokenHash string   `json:"-"`
+	OrgID     string   `json:"org_id"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles"`
+	InvitedBy string   `json:"invited_by"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at"`
+}
+
+// OrgInviteRequest mengundang pemilik email ke organisasi aktif dengan role organisasi tertentu
+type OrgInviteRequest struct {
+	Email string   `json:"email"`
+	Roles []string `json:"roles"`
+}
+
+// OrgInviteAcceptRequest menerima undangan menggunakan token dari email
+type OrgInviteAcceptRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,68 @@
+package dto
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"regexp"
+)
+
+// orgIDPattern org_id digunakan di url dan token sehingga dibatasi huruf kecil, angka dan tanda hubung
+var orgIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Validate input
+func (o OrgRequest) Validate() error {
+	if err := validation.ValidateStruct(&o,
+		validation.Field(&o.OrgID, validation.Required, validation.Length(2, 50), validation.Match(orgIDPattern).Error("validation.org_id_format")),
+		validation.Field(&o.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&o.Admin, validation.Required),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (o OrgMemberRequest) Validate() error {
+	if err := validation.ValidateStruct(&o,
+		validation.Field(&o.Roles, validation.Required, validation.Each(validation.Required)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (o OrgSwitchRequest) Validate() error {
+	if err := validation.ValidateStruct(&o,
+		validation.Field(&o.OrgID, validation.Required),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (o OrgInviteRequest) Validate() error {
+	if err := validation.ValidateStruct(&o,
+		validation.Field(&o.Email, validation.Required, is.Email),
+		validation.Field(&o.Roles, validation.Required, validation.Each(validation.Required)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (o OrgInviteAcceptRequest) Validate() error {
+	if err := validation.ValidateStruct(&o,
+		validation.Field(&o.Token, validation.Required),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package dto
+
+// PasswordResetToken token reset password, TokenHash adalah sha256 dari token yang dikirim via email
+type PasswordResetToken struct {
+	TokenHash string `json:"-"`
+	Username  string `json:"username"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	UsedAt    *int64 `json:"used_at"`
+}
+
+type PasswordForgotRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
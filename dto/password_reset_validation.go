@@ -0,0 +1,29 @@
+package dto
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// Validate input
+func (p PasswordForgotRequest) Validate() error {
+	if err := validation.ValidateStruct(&p,
+		validation.Field(&p.Email, validation.Required, is.Email),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (p PasswordResetRequest) Validate() error {
+	if err := validation.ValidateStruct(&p,
+		validation.Field(&p.Token, validation.Required),
+		validation.Field(&p.NewPassword, passwordRules()...),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
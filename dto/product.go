@@ -2,6 +2,7 @@ package dto
 
 type Product struct {
 	ProductID int64           `json:"product_id"`
+	OrgID     string          `json:"org_id"`
 	Name      UppercaseString `json:"name"`
 	Price     int64           `json:"price"`
 	CreatedBy string          `json:"created_by"`
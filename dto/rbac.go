@@ -0,0 +1,32 @@
+package dto
+
+// Role kumpulan permission yang dapat diberikan ke user
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	// MFARequired user dengan role ini wajib menggunakan 2FA
+	MFARequired bool `json:"mfa_required"`
+	// OrgScoped role hanya dapat diberikan kepada anggota organisasi dan berlaku di organisasi tersebut
+	OrgScoped bool  `json:"org_scoped"`
+	CreatedAt int64 `json:"created_at"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RoleRequest membuat role baru atau mengganti permission role (Name diambil dari url ketika edit).
+// OrgScoped hanya digunakan ketika membuat role
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	OrgScoped   bool     `json:"org_scoped"`
+}
+
+// UserRolesRequest mengganti seluruh role milik user
+type UserRolesRequest struct {
+	Roles []string `json:"roles"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Validate input
+func (r RoleRequest) Validate() error {
+	if err := validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 50)),
+		validation.Field(&r.Description, validation.Length(0, 255)),
+		validation.Field(&r.Permissions, validation.Each(validation.Required)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (u UserRolesRequest) Validate() error {
+	if err := validation.ValidateStruct(&u,
+		validation.Field(&u.Roles, validation.Required, validation.Each(validation.Required)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
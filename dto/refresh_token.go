@@ -0,0 +1,17 @@
+package dto
+
+// RefreshTokenFamily kumpulan refresh token hasil rotasi dari satu kali login
+type RefreshTokenFamily struct {
+	FamilyID  string `json:"family_id"`
+	Username  string `json:"username"`
+	CreatedAt int64  `json:"created_at"`
+	RevokedAt *int64 `json:"revoked_at"`
+}
+
+// RefreshToken satu refresh token di dalam family, UsedAt terisi setelah dirotasi
+type RefreshToken struct {
+	JTI       string `json:"jti"`
+	FamilyID  string `json:"family_id"`
+	ExpiresAt int64  `json:"expires_at"`
+	UsedAt    *int64 `json:"used_at"`
+}
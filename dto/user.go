@@ -1,21 +1,29 @@
 package dto
 
 type User struct {
-	Username  UppercaseString `json:"username"`
-	Email     string          `json:"email"`
-	Name      string          `json:"name"`
-	Password  string          `json:"-"`
-	Role      string          `json:"role"`
-	CreatedAt int64           `json:"crated_at"`
-	UpdatedAt int64           `json:"updated_at"`
+	Username UppercaseString `json:"username"`
+	Email    string          `json:"email"`
+	Name     string          `json:"name"`
+	Password string          `json:"-"`
+	Roles    []string        `json:"roles"`
+	// MustChangePassword diisi ketika admin memaksa reset password
+	MustChangePassword bool `json:"must_change_password"`
+	// EmailVerifiedAt nil jika email belum diverifikasi
+	EmailVerifiedAt *int64 `json:"email_verified_at"`
+	// Language preferensi bahasa pesan response, kosong jika mengikuti header Accept-Language
+	Language  string `json:"language"`
+	CreatedAt int64  `json:"crated_at"`
+	UpdatedAt int64  `json:"updated_at"`
 }
 
 type UserRegisterReq struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Name     string   `json:"name"`
+	Password string   `json:"password"`
+	Roles    []string `json:"roles"`
+	// Role masih diterima untuk client lama yang hanya mengirim satu role
+	Role string `json:"role"`
 }
 
 // UserLoginResponse balikan user ketika sukses login dengan tambahan AccessToken
@@ -26,22 +34,66 @@ type UserLoginRequest struct {
 
 // UserLoginResponse balikan user ketika sukses login dengan tambahan AccessToken
 type UserLoginResponse struct {
-	Username     string `json:"username"`
-	Email        string `json:"email"`
-	Name         string `json:"name"`
-	Roles        string `json:"role"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	Expired      int64  `json:"expired"`
+	Username     string   `json:"username"`
+	Email        string   `json:"email"`
+	Name         string   `json:"name"`
+	Roles        []string `json:"roles"`
+	AccessToken  string   `json:"access_token"`
+	RefreshToken string   `json:"refresh_token"`
+	Expired      int64    `json:"expired"`
+	// MustChangePassword true jika user wajib mengganti password sebelum dapat mengakses endpoint lain
+	MustChangePassword bool `json:"must_change_password"`
+	// EmailVerified false jika email belum diverifikasi
+	EmailVerified bool `json:"email_verified"`
+	// MFARequired true jika login memerlukan langkah kedua, token belum diterbitkan dan
+	// MFAToken harus dikirim bersama kode TOTP ke /login/mfa
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+	// MFAEnrollmentRequired true jika role user mewajibkan 2FA namun user belum enrolment
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+	// RecoveryCodes terisi jika enrolment diselesaikan saat login, hanya ditampilkan sekali
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	// OrgID organisasi aktif pada token, kosong jika user belum menjadi anggota organisasi manapun
+	OrgID string `json:"org_id"`
 }
 
 type UserRefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// UserLogoutRequest refresh token opsional yang ikut dicabut ketika logout
+type UserLogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // UserRefreshTokenResponse mengembalikan token dengan claims yang
-// sama dengan token sebelumnya dengan expired yang baru
+// sama dengan token sebelumnya dengan expired yang baru.
+// RefreshToken adalah hasil rotasi, refresh token yang dikirim sebelumnya tidak berlaku lagi
 type UserRefreshTokenResponse struct {
-	AccessToken string `json:"access_token"`
-	Expired     int64  `json:"expired"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Expired      int64  `json:"expired"`
+	OrgID        string `json:"org_id"`
+}
+
+// UserLanguageRequest mengganti preferensi bahasa sendiri, kosong untuk kembali mengikuti Accept-Language
+type UserLanguageRequest struct {
+	Language string `json:"language"`
+}
+
+// UserChangePasswordRequest mengganti password sendiri
+type UserChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// UserForcePasswordResetRequest admin memaksa user mengganti password pada login berikutnya,
+// TemporaryPassword opsional untuk mengganti password lama yang mungkin bocor
+type UserForcePasswordResetRequest struct {
+	TemporaryPassword string `json:"temporary_password"`
+}
+
+// EmailVerificationResendRequest meminta tautan verifikasi dikirim ulang
+type EmailVerificationResendRequest struct {
+	Email string `json:"email"`
 }
@@ -1,28 +1,94 @@
 package dto
 
 import (
-	"fmt"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
-	"github.com/muchlist/sagasql/config"
-	"github.com/muchlist/sagasql/utils/sfunc"
+	"github.com/muchlist/sagasql/utils/mi18n"
+	"github.com/muchlist/sagasql/utils/mpassword"
 )
 
-// Validate input
+// passwordRules aturan password yang berlaku untuk register, reset maupun ganti password (lihat mpassword.Policy).
+// userInputs berisi username atau email yang tidak boleh menjadi bagian dari password, jika belum diketahui
+// pada saat validasi input (misal ganti password) pemeriksaan tersebut dilakukan oleh service
+func passwordRules(userInputs ...string) []validation.Rule {
+	return []validation.Rule{
+		validation.Required,
+		validation.By(func(value interface{}) error {
+			password, _ := value.(string)
+			if password == "" {
+				return nil
+			}
+			return mpassword.Validate(password, userInputs...)
+		}),
+	}
+}
+
+// Validate input, keberadaan role dicek oleh service
 func (u UserRegisterReq) Validate() error {
 	if err := validation.ValidateStruct(&u,
 		validation.Field(&u.Username, validation.Required),
 		validation.Field(&u.Email, validation.Required, is.Email),
 		validation.Field(&u.Name, validation.Required),
-		validation.Field(&u.Role, validation.Required),
-		validation.Field(&u.Password, validation.Required, validation.Length(3, 20)),
+		validation.Field(&u.Roles, validation.Required.When(u.Role == "").Error("validation.roles_required"), validation.Each(validation.Required)),
+		validation.Field(&u.Password, passwordRules(u.Username, u.Email)...),
 	); err != nil {
 		return err
 	}
 
-	// validate role
-	if !sfunc.InSlice(u.Role, config.GetRolesAvailable()) {
-		return fmt.Errorf("role yang dimasukkan tidak tersedia. gunakan %s", config.GetRolesAvailable())
+	return nil
+}
+
+// RoleList role dari request, mendukung field role lama
+func (u UserRegisterReq) RoleList() []string {
+	if len(u.Roles) == 0 && u.Role != "" {
+		return []string{u.Role}
+	}
+	return u.Roles
+}
+
+// Validate input
+func (u UserChangePasswordRequest) Validate() error {
+	if err := validation.ValidateStruct(&u,
+		validation.Field(&u.OldPassword, validation.Required),
+		validation.Field(&u.NewPassword, append(passwordRules(), validation.NotIn(u.OldPassword).Error("validation.password_reused"))...),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (u UserLanguageRequest) Validate() error {
+	if err := validation.ValidateStruct(&u,
+		validation.Field(&u.Language, validation.In(mi18n.ID, mi18n.EN)),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (u UserForcePasswordResetRequest) Validate() error {
+	if u.TemporaryPassword == "" {
+		return nil
+	}
+	if err := validation.ValidateStruct(&u,
+		validation.Field(&u.TemporaryPassword, passwordRules()...),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Validate input
+func (e EmailVerificationResendRequest) Validate() error {
+	if err := validation.ValidateStruct(&e,
+		validation.Field(&e.Email, validation.Required, is.Email),
+	); err != nil {
+		return err
 	}
 
 	return nil
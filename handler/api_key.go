@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/service"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+)
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyServiceAssumer, log mlog.LoggerAssumer) *apiKeyHandler {
+	return &apiKeyHandler{
+		service: apiKeyService,
+		log:     log,
+	}
+}
+
+type apiKeyHandler struct {
+	service service.APIKeyServiceAssumer
+	log     mlog.LoggerAssumer
+}
+
+// Create menerbitkan api key, key lengkap hanya ditampilkan pada response ini
+func (a *apiKeyHandler) Create(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.APIKeyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	response, apiErr := a.service.Create(c.UserContext(), claims.Identity, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": response})
+}
+
+// Find menampilkan list api key tanpa key nya
+func (a *apiKeyHandler) Find(c *fiber.Ctx) error {
+	keys, apiErr := a.service.Find(c.UserContext())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if keys == nil {
+		keys = []dto.APIKey{}
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": keys})
+}
+
+// Revoke mencabut api key
+func (a *apiKeyHandler) Revoke(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	apiErr := a.service.Revoke(c.UserContext(), id)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.apikey_revoked", id)})
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/service"
+	"net/http"
+)
+
+func NewHealthHandler(healthService service.HealthServiceAssumer) *healthHandler {
+	return &healthHandler{
+		service: healthService,
+	}
+}
+
+type healthHandler struct {
+	service service.HealthServiceAssumer
+}
+
+// Liveness digunakan orchestrator untuk mengetahui apakah proses masih hidup
+func (h *healthHandler) Liveness(c *fiber.Ctx) error {
+	return c.JSON(h.service.Liveness())
+}
+
+// Readiness digunakan orchestrator untuk mengetahui apakah aplikasi siap menerima trafik
+func (h *healthHandler) Readiness(c *fiber.Ctx) error {
+	report := h.service.Readiness(c.UserContext())
+	if report.Status != dto.HealthStatusUp {
+		return c.Status(http.StatusServiceUnavailable).JSON(report)
+	}
+	return c.JSON(report)
+}
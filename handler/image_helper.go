@@ -19,19 +19,19 @@ const (
 func saveImage(c *fiber.Ctx, folder string, imageName string) (string, rest_err.APIError) {
 	file, err := c.FormFile("image")
 	if err != nil {
-		apiErr := rest_err.NewAPIError("File gagal di upload", http.StatusBadRequest, "bad_request", []interface{}{err.Error()})
+		apiErr := rest_err.NewAPIError("file.upload_failed", http.StatusBadRequest, "bad_request", []interface{}{err.Error()})
 		return "", apiErr
 	}
 
 	fileName := file.Filename
 	fileExtension := strings.ToLower(filepath.Ext(fileName))
 	if !(fileExtension == jpgExtension || fileExtension == pngExtension || fileExtension == jpegExtension) {
-		apiErr := rest_err.NewBadRequestError("Ektensi file tidak di support")
+		apiErr := rest_err.NewBadRequestError("file.extension_unsupported")
 		return "", apiErr
 	}
 
 	if file.Size > 2*1024*1024 { // 1 MB
-		apiErr := rest_err.NewBadRequestError("Ukuran file tidak dapat melebihi 2MB")
+		apiErr := rest_err.NewBadRequestError("file.too_large")
 		return "", apiErr
 	}
 
@@ -43,7 +43,7 @@ func saveImage(c *fiber.Ctx, folder string, imageName string) (string, rest_err.
 
 	err = c.SaveFile(file, path)
 	if err != nil {
-		apiErr := rest_err.NewInternalServerError("File gagal di upload", err)
+		apiErr := rest_err.NewInternalServerError("file.upload_failed", err)
 		return "", apiErr
 	}
 
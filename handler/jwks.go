@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mjwt"
+)
+
+// JWKS menampilkan public key untuk memverifikasi token (RFC 7517),
+// service lain cukup membaca endpoint ini tanpa perlu mengetahui secret
+func JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(mjwt.PublicJWKS())
+}
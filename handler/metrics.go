@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+func NewMetricsHandler(gatherer prometheus.Gatherer) *metricsHandler {
+	return &metricsHandler{
+		gatherer: gatherer,
+	}
+}
+
+type metricsHandler struct {
+	gatherer prometheus.Gatherer
+}
+
+// Metrics menampilkan semua metric dalam format text exposition prometheus
+func (m *metricsHandler) Metrics(c *fiber.Ctx) error {
+	families, err := m.gatherer.Gather()
+	if err != nil {
+		return rest_err.NewInternalServerError("metrics.collect_failed", err)
+	}
+
+	c.Set(fiber.HeaderContentType, string(expfmt.FmtText))
+	encoder := expfmt.NewEncoder(c, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/service"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+)
+
+func NewMFAHandler(mfaService service.MFAServiceAssumer, log mlog.LoggerAssumer) *mfaHandler {
+	return &mfaHandler{
+		service: mfaService,
+		log:     log,
+	}
+}
+
+type mfaHandler struct {
+	service service.MFAServiceAssumer
+	log     mlog.LoggerAssumer
+}
+
+// Enroll memulai enrolment 2FA user yang sedang login, mengembalikan secret dan otpauth uri
+func (m *mfaHandler) Enroll(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	response, apiErr := m.service.Enroll(c.UserContext(), claims.Identity)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": response})
+}
+
+// Enable mengaktifkan 2FA menggunakan kode pertama dari aplikasi authenticator
+func (m *mfaHandler) Enable(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.MFACodeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	codes, apiErr := m.service.Enable(c.UserContext(), claims.Identity, request.Code)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": dto.MFARecoveryCodesResponse{RecoveryCodes: codes}})
+}
+
+// Disable menonaktifkan 2FA user yang sedang login
+func (m *mfaHandler) Disable(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.MFACodeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := m.service.Disable(c.UserContext(), claims.Identity, claims.Roles, request.Code)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.mfa_disabled")})
+}
+
+// RegenerateRecoveryCodes mengganti seluruh recovery code user yang sedang login
+func (m *mfaHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.MFACodeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	codes, apiErr := m.service.RegenerateRecoveryCodes(c.UserContext(), claims.Identity, request.Code)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": dto.MFARecoveryCodesResponse{RecoveryCodes: codes}})
+}
+
+// Reset menghapus 2FA user yang kehilangan perangkat, hanya untuk admin
+func (m *mfaHandler) Reset(c *fiber.Ctx) error {
+	username := c.Params("username")
+
+	apiErr := m.service.Reset(c.UserContext(), username)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.mfa_reset", username)})
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/service"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+func NewOIDCHandler(oidcService service.OIDCServiceAssumer, log mlog.LoggerAssumer) *oidcHandler {
+	return &oidcHandler{
+		service: oidcService,
+		log:     log,
+	}
+}
+
+type oidcHandler struct {
+	service service.OIDCServiceAssumer
+	log     mlog.LoggerAssumer
+}
+
+const (
+	// oidcStateCookie menyimpan state login oidc di browser yang memulai login
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/v1/oidc"
+)
+
+// setStateCookie SameSite Lax agar cookie tetap terkirim pada redirect GET dari provider ke callback,
+// state kosong menghapus cookie
+func (o *oidcHandler) setStateCookie(c *fiber.Ctx, state string) {
+	cookie := &fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcCookiePath,
+		MaxAge:   int(service.OIDCStateTTL.Seconds()),
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+	if state == "" {
+		cookie.MaxAge = 0
+		cookie.Expires = time.Unix(1, 0)
+	}
+	c.Cookie(cookie)
+}
+
+// Login mengarahkan browser ke halaman login identity provider,
+// dengan query redirect=false alamatnya dikembalikan sebagai json (untuk SPA)
+func (o *oidcHandler) Login(c *fiber.Ctx) error {
+	response, apiErr := o.service.Begin(c.UserContext())
+	if apiErr != nil {
+		return apiErr
+	}
+	o.setStateCookie(c, response.State)
+
+	if c.Query("redirect") == "false" {
+		return c.JSON(fiber.Map{"error": nil, "data": response})
+	}
+	return c.Redirect(response.AuthorizationURL, fiber.StatusFound)
+}
+
+// Callback menerima authorization code dari identity provider lalu mengembalikan token seperti login biasa
+func (o *oidcHandler) Callback(c *fiber.Ctx) error {
+	if providerErr := c.Query("error"); providerErr != "" {
+		o.log.Warn(c.UserContext(), "identity provider menolak login", mlog.String("error", providerErr), mlog.String("description", c.Query("error_description")))
+		return rest_err.NewUnauthorizedError("oidc.denied")
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		return rest_err.NewBadRequestError("oidc.code_state_required")
+	}
+
+	boundState := c.Cookies(oidcStateCookie)
+	// state hanya dapat dipakai sekali, cookie dihapus baik callback berhasil maupun gagal
+	o.setStateCookie(c, "")
+
+	response, apiErr := o.service.Callback(c.UserContext(), code, state, boundState)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": response})
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/service"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+)
+
+func NewOrgHandler(orgService service.OrgServiceAssumer, userService service.UserServiceAssumer, log mlog.LoggerAssumer) *orgHandler {
+	return &orgHandler{
+		service:     orgService,
+		userService: userService,
+		log:         log,
+	}
+}
+
+type orgHandler struct {
+	service     service.OrgServiceAssumer
+	userService service.UserServiceAssumer
+	log         mlog.LoggerAssumer
+}
+
+// Create membuat organisasi baru beserta admin pertamanya
+func (o *orgHandler) Create(c *fiber.Ctx) error {
+	var request dto.OrgRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	org, apiErr := o.service.Create(c.UserContext(), request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": org})
+}
+
+// Find menampilkan semua organisasi
+func (o *orgHandler) Find(c *fiber.Ctx) error {
+	orgs, apiErr := o.service.Find(c.UserContext())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if orgs == nil {
+		orgs = []dto.Organization{}
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": orgs})
+}
+
+// FindMine menampilkan organisasi tempat user yang sedang login menjadi anggota
+func (o *orgHandler) FindMine(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	orgs, apiErr := o.service.FindByUser(c.UserContext(), claims.Identity)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if orgs == nil {
+		orgs = []dto.OrgMembership{}
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": orgs})
+}
+
+// Switch mengganti organisasi aktif, mengembalikan access dan refresh token baru
+func (o *orgHandler) Switch(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.OrgSwitchRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	response, apiErr := o.userService.SwitchOrg(c.UserContext(), claims, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": response})
+}
+
+// FindMembers menampilkan anggota organisasi aktif
+func (o *orgHandler) FindMembers(c *fiber.Ctx) error {
+	orgID, apiErr := activeOrgID(c)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	members, apiErr := o.service.FindMembers(c.UserContext(), orgID)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if members == nil {
+		members = []dto.OrgMember{}
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": members})
+}
+
+// SetMember mengganti role anggota organisasi aktif
+func (o *orgHandler) SetMember(c *fiber.Ctx) error {
+	orgID, apiErr := activeOrgID(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	username := c.Params("username")
+
+	var request dto.OrgMemberRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr = o.service.SetMember(c.UserContext(), orgID, username, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.org_member_set", username, orgID)})
+}
+
+// RemoveMember mengeluarkan user dari organisasi aktif
+func (o *orgHandler) RemoveMember(c *fiber.Ctx) error {
+	orgID, apiErr := activeOrgID(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	username := c.Params("username")
+
+	apiErr = o.service.RemoveMember(c.UserContext(), orgID, username)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.org_member_removed", username, orgID)})
+}
+
+// Invite mengundang pemilik email menjadi anggota organisasi aktif
+func (o *orgHandler) Invite(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+	orgID, apiErr := activeOrgID(c)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var request dto.OrgInviteRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	invitation, apiErr := o.service.Invite(c.UserContext(), orgID, claims.Identity, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": invitation})
+}
+
+// FindInvitations menampilkan undangan organisasi aktif yang belum diterima
+func (o *orgHandler) FindInvitations(c *fiber.Ctx) error {
+	orgID, apiErr := activeOrgID(c)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	invitations, apiErr := o.service.FindInvitations(c.UserContext(), orgID)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if invitations == nil {
+		invitations = []dto.OrgInvitation{}
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": invitations})
+}
+
+// CancelInvitation membatalkan undangan organisasi aktif untuk email
+func (o *orgHandler) CancelInvitation(c *fiber.Ctx) error {
+	orgID, apiErr := activeOrgID(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	email := c.Params("email")
+
+	apiErr = o.service.CancelInvitation(c.UserContext(), orgID, email)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.org_invitation_cancelled", email, orgID)})
+}
+
+// AcceptInvitation menerima undangan organisasi menggunakan akun yang sedang login
+func (o *orgHandler) AcceptInvitation(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.OrgInviteAcceptRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	orgID, apiErr := o.service.AcceptInvitation(c.UserContext(), claims.Identity, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.org_invitation_accepted", orgID)})
+}
+
+// activeOrgID organisasi aktif pada token, keanggotaannya sudah diperiksa oleh middleware auth
+func activeOrgID(c *fiber.Ctx) (string, rest_err.APIError) {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+	if claims.OrgID == "" {
+		return "", rest_err.From(rest_err.ErrOrgRequired)
+	}
+	return claims.OrgID, nil
+}
@@ -3,22 +3,26 @@ package handler
 import (
 	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/db"
 	"github.com/muchlist/sagasql/dto"
 	"github.com/muchlist/sagasql/service"
 	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
 	"github.com/muchlist/sagasql/utils/rest_err"
 	"strconv"
 	"time"
 )
 
-func NewProductHandler(productService service.ProductServiceAssumer) *productHandler {
+func NewProductHandler(productService service.ProductServiceAssumer, log mlog.LoggerAssumer) *productHandler {
 	return &productHandler{
 		service: productService,
+		log:     log,
 	}
 }
 
 type productHandler struct {
 	service service.ProductServiceAssumer
+	log     mlog.LoggerAssumer
 }
 
 // Insert menambahkan product
@@ -27,27 +31,24 @@ func (u *productHandler) Insert(c *fiber.Ctx) error {
 
 	var product dto.ProductReq
 	if err := c.BodyParser(&product); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return invalidBodyError(err)
 	}
 
 	if err := product.Validate(); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return validationError(err)
 	}
 
-	insertProductID, apiErr := u.service.InsertProduct(dto.Product{
+	insertProductID, apiErr := u.service.InsertProduct(c.UserContext(), dto.Product{
 		Name:      dto.UppercaseString(product.Name),
 		Price:     product.Price,
 		CreatedBy: claims.Identity,
 		CreatedAt: time.Now().Unix(),
 	})
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
-	res := fmt.Sprintf("Register berhasil, ID: %d", *insertProductID)
-	return c.JSON(fiber.Map{"error": nil, "data": res})
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.product_created", *insertProductID)})
 }
 
 // Edit mengedit product
@@ -55,20 +56,18 @@ func (u *productHandler) Edit(c *fiber.Ctx) error {
 	productIDStr := c.Params("id")
 	productID, err := strconv.ParseInt(productIDStr, 10, 64)
 	if err != nil {
-		apiErr := rest_err.NewBadRequestError("ID harus dalam bentuk angka")
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return rest_err.ErrInvalidID
 	}
 
 	var product dto.Product
 	product.ProductID = productID
 	if err := c.BodyParser(&product); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return invalidBodyError(err)
 	}
 
-	productEdited, apiErr := u.service.EditProduct(product)
+	productEdited, apiErr := u.service.EditProduct(c.UserContext(), product)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": productEdited})
@@ -79,16 +78,15 @@ func (u *productHandler) Delete(c *fiber.Ctx) error {
 	productIDStr := c.Params("id")
 	productID, err := strconv.ParseInt(productIDStr, 10, 64)
 	if err != nil {
-		apiErr := rest_err.NewBadRequestError("ID harus dalam bentuk angka")
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return rest_err.ErrInvalidID
 	}
 
-	apiErr := u.service.DeleteProduct(productID)
+	apiErr := u.service.DeleteProduct(c.UserContext(), productID)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
-	return c.JSON(fiber.Map{"error": nil, "data": fmt.Sprintf("product %d berhasil dihapus", productID)})
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.product_deleted", productID)})
 }
 
 // Get menampilkan product berdasarkan productID
@@ -96,13 +94,12 @@ func (u *productHandler) Get(c *fiber.Ctx) error {
 	productIDStr := c.Params("id")
 	productID, err := strconv.ParseInt(productIDStr, 10, 64)
 	if err != nil {
-		apiErr := rest_err.NewBadRequestError("ID harus dalam bentuk angka")
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return rest_err.ErrInvalidID
 	}
 
-	product, apiErr := u.service.GetProduct(productID)
+	product, apiErr := u.service.GetProduct(c.UserContext(), productID)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": product})
@@ -112,9 +109,9 @@ func (u *productHandler) Get(c *fiber.Ctx) error {
 func (u *productHandler) Find(c *fiber.Ctx) error {
 	search := c.Query("search")
 
-	productList, apiErr := u.service.FindProducts(search)
+	productList, apiErr := u.service.FindProducts(c.UserContext(), search)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	if productList == nil {
@@ -128,21 +125,26 @@ func (u *productHandler) UploadImage(c *fiber.Ctx) error {
 	productIDStr := c.Params("id")
 	productID, err := strconv.ParseInt(productIDStr, 10, 64)
 	if err != nil {
-		apiErr := rest_err.NewBadRequestError("ID harus dalam bentuk angka")
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return rest_err.ErrInvalidID
+	}
+
+	// koneksi database dilepas selama file disimpan, query berikutnya memakai transaksi baru
+	if err := db.Commit(c.UserContext()); err != nil {
+		return rest_err.NewInternalServerError("db.commit_failed", err)
 	}
 
 	randomName := fmt.Sprintf("%d-%d", productID, time.Now().Unix())
 	// simpan image
 	pathInDB, apiErr := saveImage(c, "product", randomName)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		u.log.Warn(c.UserContext(), "upload gambar gagal", mlog.Int64("product_id", productID), mlog.Err(apiErr))
+		return apiErr
 	}
 
 	// update path image di database
-	productResult, apiErr := u.service.PutImage(productID, pathInDB)
+	productResult, apiErr := u.service.PutImage(c.UserContext(), productID, pathInDB)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": productResult})
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/service"
+	"github.com/muchlist/sagasql/utils/mlog"
+)
+
+func NewRBACHandler(rbacService service.RBACServiceAssumer, log mlog.LoggerAssumer) *rbacHandler {
+	return &rbacHandler{
+		service: rbacService,
+		log:     log,
+	}
+}
+
+type rbacHandler struct {
+	service service.RBACServiceAssumer
+	log     mlog.LoggerAssumer
+}
+
+// FindRoles menampilkan list role beserta permission nya
+func (r *rbacHandler) FindRoles(c *fiber.Ctx) error {
+	roles, apiErr := r.service.FindRoles(c.UserContext())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if roles == nil {
+		roles = []dto.Role{}
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": roles})
+}
+
+// GetRole menampilkan role berdasarkan nama
+func (r *rbacHandler) GetRole(c *fiber.Ctx) error {
+	role, apiErr := r.service.GetRole(c.UserContext(), c.Params("name"))
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": role})
+}
+
+// CreateRole menambahkan role
+func (r *rbacHandler) CreateRole(c *fiber.Ctx) error {
+	var request dto.RoleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	role, apiErr := r.service.CreateRole(c.UserContext(), request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": role})
+}
+
+// EditRole mengganti deskripsi dan seluruh permission role
+func (r *rbacHandler) EditRole(c *fiber.Ctx) error {
+	var request dto.RoleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+	request.Name = c.Params("name")
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	role, apiErr := r.service.EditRole(c.UserContext(), request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": role})
+}
+
+// DeleteRole menghapus role
+func (r *rbacHandler) DeleteRole(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	apiErr := r.service.DeleteRole(c.UserContext(), name)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.role_deleted", name)})
+}
+
+// FindPermissions menampilkan list permission yang dapat diberikan ke role
+func (r *rbacHandler) FindPermissions(c *fiber.Ctx) error {
+	permissions, apiErr := r.service.FindPermissions(c.UserContext())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if permissions == nil {
+		permissions = []dto.Permission{}
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": permissions})
+}
+
+// SetUserRoles mengganti seluruh role milik user
+func (r *rbacHandler) SetUserRoles(c *fiber.Ctx) error {
+	username := c.Params("username")
+
+	var request dto.UserRolesRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := r.service.SetUserRoles(c.UserContext(), username, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.user_roles_set", username)})
+}
+
+// SetRoleMFARequired mengatur kewajiban 2FA untuk role
+func (r *rbacHandler) SetRoleMFARequired(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	var request dto.RoleMFARequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	apiErr := r.service.SetRoleMFARequired(c.UserContext(), name, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	role, apiErr := r.service.GetRole(c.UserContext(), name)
+	if apiErr != nil {
+		return apiErr
+	}
+	return c.JSON(fiber.Map{"error": nil, "data": role})
+}
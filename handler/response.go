@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mi18n"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"net/http"
+	"sort"
+)
+
+// message pesan pada catalog mi18n dalam bahasa request, digunakan untuk response sukses berupa teks
+func message(c *fiber.Ctx, key string, args ...interface{}) string {
+	return mi18n.Translate(mi18n.LangFromContext(c.UserContext()), key, args...)
+}
+
+// invalidBodyError error 400 ketika body request tidak dapat dibaca, pesan parser (tidak diterjemahkan) disertakan pada causes
+func invalidBodyError(err error) rest_err.APIError {
+	return rest_err.NewAPIError("request.invalid_body", http.StatusBadRequest, rest_err.CodeBadRequest, []interface{}{err.Error()})
+}
+
+// validationError mengubah error ozzo-validation menjadi error 400 dengan rincian per field pada causes.
+// error field bersarang (validation.Each) diratakan menjadi field.index, contoh roles.0
+func validationError(err error) rest_err.APIError {
+	var fieldErrs validation.Errors
+	if !errors.As(err, &fieldErrs) {
+		return invalidBodyError(err)
+	}
+
+	fields := fieldErrors("", fieldErrs)
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Field < fields[j].Field
+	})
+	return rest_err.NewValidationError(rest_err.JoinFieldErrors(fields), fields)
+}
+
+func fieldErrors(prefix string, fieldErrs validation.Errors) []rest_err.FieldError {
+	fields := make([]rest_err.FieldError, 0, len(fieldErrs))
+	for field, fieldErr := range fieldErrs {
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		if nested, ok := fieldErr.(validation.Errors); ok {
+			fields = append(fields, fieldErrors(field, nested)...)
+			continue
+		}
+		fields = append(fields, rest_err.NewFieldError(field, fieldErr))
+	}
+	return fields
+}
@@ -1,74 +1,161 @@
 package handler
 
 import (
-	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/muchlist/sagasql/dto"
 	"github.com/muchlist/sagasql/service"
 	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
 	"github.com/muchlist/sagasql/utils/rest_err"
 	"time"
 )
 
-func NewUserHandler(userService service.UserServiceAssumer) *userHandler {
+func NewUserHandler(userService service.UserServiceAssumer, guardService service.LoginGuardServiceAssumer, passwordResetService service.PasswordResetServiceAssumer, verificationService service.EmailVerificationServiceAssumer, log mlog.LoggerAssumer) *userHandler {
 	return &userHandler{
-		service: userService,
+		service:       userService,
+		guard:         guardService,
+		passwordReset: passwordResetService,
+		verification:  verificationService,
+		log:           log,
 	}
 }
 
 type userHandler struct {
-	service service.UserServiceAssumer
+	service       service.UserServiceAssumer
+	guard         service.LoginGuardServiceAssumer
+	passwordReset service.PasswordResetServiceAssumer
+	verification  service.EmailVerificationServiceAssumer
+	log           mlog.LoggerAssumer
 }
 
 // Login login
 func (u *userHandler) Login(c *fiber.Ctx) error {
 	var login dto.UserLoginRequest
 	if err := c.BodyParser(&login); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return invalidBodyError(err)
 	}
 
 	if login.Username == "" || login.Password == "" {
-		apiErr := rest_err.NewBadRequestError("username atau password tidak boleh kosong")
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return rest_err.NewBadRequestError("user.login_input_required")
 	}
 
-	response, apiErr := u.service.Login(login)
+	response, apiErr := u.service.Login(c.UserContext(), login, c.IP())
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": response})
 }
 
+// LoginMFA langkah kedua login menggunakan token mfa pending dan kode 2FA atau recovery code
+func (u *userHandler) LoginMFA(c *fiber.Ctx) error {
+	var request dto.MFALoginRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	response, apiErr := u.service.LoginMFA(c.UserContext(), request, c.IP())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": response})
+}
+
+// EnrollMFALogin memulai enrolment 2FA ketika login, untuk user yang role nya mewajibkan 2FA
+func (u *userHandler) EnrollMFALogin(c *fiber.Ctx) error {
+	var request dto.MFAEnrollLoginRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	response, apiErr := u.service.EnrollMFALogin(c.UserContext(), request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": response})
+}
+
+// UnlockLogin membuka kunci login username dan/atau ip, hanya untuk admin
+func (u *userHandler) UnlockLogin(c *fiber.Ctx) error {
+	var request dto.LoginUnlockRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := u.guard.Unlock(c.UserContext(), request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.login_unlocked")})
+}
+
 // Register menambahkan user
 func (u *userHandler) Register(c *fiber.Ctx) error {
 	var user dto.UserRegisterReq
 	if err := c.BodyParser(&user); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return invalidBodyError(err)
+	}
+
+	if err := user.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	insertUsername, apiErr := u.service.InsertUser(c.UserContext(), dto.User{
+		Username:  dto.UppercaseString(user.Username),
+		Email:     user.Email,
+		Name:      user.Name,
+		Password:  user.Password,
+		Roles:     user.RoleList(),
+		CreatedAt: time.Now().Unix(),
+		UpdatedAt: time.Now().Unix(),
+	})
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.user_created", *insertUsername)})
+}
+
+// RegisterFirst meregistrasi user pertama tanpa autentikasi, ditolak jika sudah ada user
+func (u *userHandler) RegisterFirst(c *fiber.Ctx) error {
+	var user dto.UserRegisterReq
+	if err := c.BodyParser(&user); err != nil {
+		return invalidBodyError(err)
 	}
 
 	if err := user.Validate(); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return validationError(err)
 	}
 
-	insertUsername, apiErr := u.service.InsertUser(dto.User{
+	insertUsername, apiErr := u.service.InsertFirstUser(c.UserContext(), dto.User{
 		Username:  dto.UppercaseString(user.Username),
 		Email:     user.Email,
 		Name:      user.Name,
 		Password:  user.Password,
-		Role:      user.Role,
+		Roles:     user.RoleList(),
 		CreatedAt: time.Now().Unix(),
 		UpdatedAt: time.Now().Unix(),
 	})
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
-	res := fmt.Sprintf("Register berhasil, ID: %s", *insertUsername)
-	return c.JSON(fiber.Map{"error": nil, "data": res})
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.user_created", *insertUsername)})
 }
 
 // Edit mengedit user
@@ -78,13 +165,12 @@ func (u *userHandler) Edit(c *fiber.Ctx) error {
 	var user dto.User
 	user.Username = dto.UppercaseString(username)
 	if err := c.BodyParser(&user); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return invalidBodyError(err)
 	}
 
-	userEdited, apiErr := u.service.EditUser(user)
+	userEdited, apiErr := u.service.EditUser(c.UserContext(), user)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": userEdited})
@@ -94,42 +180,209 @@ func (u *userHandler) Edit(c *fiber.Ctx) error {
 func (u *userHandler) RefreshToken(c *fiber.Ctx) error {
 	var payload dto.UserRefreshTokenRequest
 	if err := c.BodyParser(&payload); err != nil {
-		apiErr := rest_err.NewBadRequestError(err.Error())
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return invalidBodyError(err)
 	}
 
-	response, apiErr := u.service.Refresh(payload)
+	response, apiErr := u.service.Refresh(c.UserContext(), payload)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": response})
 }
 
+// Logout mencabut access token saat ini dan refresh token yang dikirim pada body
+func (u *userHandler) Logout(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var payload dto.UserLogoutRequest
+	if len(c.Body()) != 0 {
+		if err := c.BodyParser(&payload); err != nil {
+			return invalidBodyError(err)
+		}
+	}
+
+	apiErr := u.service.Logout(c.UserContext(), claims, payload)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.logout")})
+}
+
+// RevokeTokens mencabut semua token milik user, hanya untuk admin
+func (u *userHandler) RevokeTokens(c *fiber.Ctx) error {
+	username := c.Params("username")
+
+	apiErr := u.service.RevokeAllTokens(c.UserContext(), username)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.tokens_revoked", username)})
+}
+
+// ChangePassword mengganti password user yang sedang login, memerlukan token fresh
+func (u *userHandler) ChangePassword(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.UserChangePasswordRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := u.service.ChangePassword(c.UserContext(), claims, request, c.IP())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.password_changed")})
+}
+
+// SetLanguage mengganti preferensi bahasa user yang sedang login
+func (u *userHandler) SetLanguage(c *fiber.Ctx) error {
+	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+
+	var request dto.UserLanguageRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := u.service.SetLanguage(c.UserContext(), claims.Identity, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.language_set")})
+}
+
+// ForgotPassword mengirim token reset password ke email, response sama walaupun email tidak terdaftar
+func (u *userHandler) ForgotPassword(c *fiber.Ctx) error {
+	var request dto.PasswordForgotRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := u.passwordReset.Forgot(c.UserContext(), request, c.IP())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.password_reset_requested")})
+}
+
+// ResetPassword mengganti password menggunakan token dari email
+func (u *userHandler) ResetPassword(c *fiber.Ctx) error {
+	var request dto.PasswordResetRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := u.passwordReset.Reset(c.UserContext(), request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.password_reset")})
+}
+
+// VerifyEmail memverifikasi email menggunakan token dari tautan yang dikirim ke email
+func (u *userHandler) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return rest_err.NewBadRequestError("user.token_required")
+	}
+
+	apiErr := u.verification.Verify(c.UserContext(), token)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.email_verified")})
+}
+
+// ResendVerification mengirim ulang tautan verifikasi, response sama walaupun email tidak terdaftar
+func (u *userHandler) ResendVerification(c *fiber.Ctx) error {
+	var request dto.EmailVerificationResendRequest
+	if err := c.BodyParser(&request); err != nil {
+		return invalidBodyError(err)
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := u.verification.Resend(c.UserContext(), request, c.IP())
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.email_verification_sent")})
+}
+
+// ForcePasswordReset memaksa user mengganti password pada login berikutnya
+func (u *userHandler) ForcePasswordReset(c *fiber.Ctx) error {
+	username := c.Params("username")
+
+	var request dto.UserForcePasswordResetRequest
+	if len(c.Body()) != 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return invalidBodyError(err)
+		}
+	}
+
+	if err := request.Validate(); err != nil {
+		return validationError(err)
+	}
+
+	apiErr := u.service.ForcePasswordReset(c.UserContext(), username, request)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.password_change_forced", username)})
+}
+
 // Delete menghapus user, idealnya melalui middleware is_admin
 func (u *userHandler) Delete(c *fiber.Ctx) error {
 	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
 	username := c.Params("username")
 
 	if claims.Identity == username {
-		apiErr := rest_err.NewBadRequestError("Tidak dapat menghapus akun terkait (diri sendiri)!")
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		u.log.Warn(c.UserContext(), "percobaan menghapus akun sendiri", mlog.String("username", username))
+		return rest_err.NewBadRequestError("user.delete_self")
 	}
 
-	apiErr := u.service.DeleteUser(username)
+	apiErr := u.service.DeleteUser(c.UserContext(), username)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
-	return c.JSON(fiber.Map{"error": nil, "data": fmt.Sprintf("user %s berhasil dihapus", username)})
+	return c.JSON(fiber.Map{"error": nil, "data": message(c, "success.user_deleted", username)})
 }
 
 // Get menampilkan user berdasarkan username
 func (u *userHandler) Get(c *fiber.Ctx) error {
 	userName := c.Params("username")
-	user, apiErr := u.service.GetUser(userName)
+	user, apiErr := u.service.GetUser(c.UserContext(), userName)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": user})
@@ -139,9 +392,9 @@ func (u *userHandler) Get(c *fiber.Ctx) error {
 func (u *userHandler) GetProfile(c *fiber.Ctx) error {
 	claims := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
 
-	user, apiErr := u.service.GetUser(claims.Identity)
+	user, apiErr := u.service.GetUser(c.UserContext(), claims.Identity)
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	return c.JSON(fiber.Map{"error": nil, "data": user})
@@ -149,9 +402,9 @@ func (u *userHandler) GetProfile(c *fiber.Ctx) error {
 
 // Find menampilkan list user
 func (u *userHandler) Find(c *fiber.Ctx) error {
-	userList, apiErr := u.service.FindUsers()
+	userList, apiErr := u.service.FindUsers(c.UserContext())
 	if apiErr != nil {
-		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+		return apiErr
 	}
 
 	if userList == nil {
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/muchlist/sagasql/app"
-	"log"
+	"os"
 )
 
 func main() {
 
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		// logger belum tersedia karena level log dibaca dari .env
+		fmt.Fprintf(os.Stderr, `{"level":"ERROR","msg":"error loading .env file","error":%q}`+"\n", err.Error())
+		os.Exit(1)
 	}
 
 	app.RunApp()
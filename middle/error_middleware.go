@@ -0,0 +1,96 @@
+package middle
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mi18n"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"runtime/debug"
+	"strconv"
+)
+
+// ErrorHandler dipasang pada fiber.Config.ErrorHandler. semua error yang dikembalikan handler dan middleware
+// (rest_err.APIError, rest_err.DomainError maupun *fiber.Error) ditulis dengan envelope error standar beserta request id.
+// jika exposeInternal false (production), causes error 5xx dicatat ke log lalu diganti reference acak pada response.
+// message diterjemahkan ke bahasa request (lihat Language)
+func ErrorHandler(log mlog.LoggerAssumer, exposeInternal bool) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		apiErr := toAPIError(err)
+		if !exposeInternal && apiErr.Status() >= fiber.StatusInternalServerError {
+			reference := mlog.NewRequestID()
+			log.Error(c.UserContext(), "error internal",
+				mlog.String("reference", reference),
+				mlog.String("method", c.Method()),
+				mlog.String("path", c.Path()),
+				mlog.String("code", apiErr.Code()),
+				mlog.Any("causes", apiErr.Causes()),
+			)
+			apiErr = rest_err.Redact(apiErr, reference)
+		}
+		lang := mi18n.LangFromContext(c.UserContext())
+		apiErr = rest_err.Localize(apiErr, lang)
+		apiErr.WithRequestID(mlog.RequestIDFromContext(c.UserContext()))
+		c.Set(fiber.HeaderContentLanguage, lang)
+		if retryErr, ok := apiErr.(rest_err.RetryAfterError); ok {
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryErr.RetryAfter(), 10))
+		}
+		return c.Status(apiErr.Status()).JSON(fiber.Map{"error": apiErr, "data": nil})
+	}
+}
+
+// Recover mengubah panic menjadi error 500 dan mencatat stack trace nya beserta request id,
+// dipasang tepat setelah middleware RequestID agar panic pada middleware lain juga tertangani
+func Recover(log mlog.LoggerAssumer) fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error(c.UserContext(), "panic",
+					mlog.String("method", c.Method()),
+					mlog.String("path", c.Path()),
+					mlog.Any("panic", fmt.Sprint(r)),
+					mlog.String("stack", string(debug.Stack())),
+				)
+				err = rest_err.NewInternalServerError("error.internal", nil)
+			}
+		}()
+		return c.Next()
+	}
+}
+
+// NotFound dipasang setelah semua route agar request yang tidak cocok dengan route manapun
+// juga dikembalikan dengan envelope error standar (route_not_found atau method_not_allowed)
+func NotFound(c *fiber.Ctx) error {
+	// router fiber mengembalikan fiber.ErrMethodNotAllowed jika path ada dengan method lain
+	if err := c.Next(); err != nil {
+		return err
+	}
+	return rest_err.ErrRouteNotFound
+}
+
+// toAPIError memetakan *fiber.Error (route tidak ditemukan, body terlalu besar dll) ke catalog error,
+// error lainnya dipetakan oleh rest_err.From
+func toAPIError(err error) rest_err.APIError {
+	var fiberErr *fiber.Error
+	if !errors.As(err, &fiberErr) {
+		return rest_err.From(err)
+	}
+
+	switch fiberErr.Code {
+	case fiber.StatusNotFound:
+		return rest_err.From(rest_err.ErrRouteNotFound)
+	case fiber.StatusMethodNotAllowed:
+		return rest_err.From(rest_err.ErrMethodNotAllowed)
+	}
+	if fiberErr.Code >= fiber.StatusInternalServerError {
+		return rest_err.NewInternalServerError("error.internal", err)
+	}
+	return rest_err.NewStatusError(fiberErr.Message, fiberErr.Code)
+}
+
+// errorStatus status response yang akan ditulis oleh ErrorHandler untuk err,
+// digunakan middleware yang berjalan sebelum ErrorHandler (log, metric dan tracing)
+func errorStatus(err error) int {
+	return toAPIError(err).Status()
+}
@@ -1,9 +1,11 @@
 package middle
 
 import (
-	"fmt"
+	"context"
 	"github.com/gofiber/fiber/v2"
 	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/morg"
+	"github.com/muchlist/sagasql/utils/mtrace"
 	"github.com/muchlist/sagasql/utils/rest_err"
 	"github.com/muchlist/sagasql/utils/sfunc"
 	"strings"
@@ -11,11 +13,65 @@ import (
 
 var (
 	jwt = mjwt.NewJwt()
+
+	// revocation diisi melalui SetRevocationChecker, jika nil token tidak dicek pencabutannya
+	revocation RevocationChecker
+
+	// permissions diisi melalui SetPermissionResolver, wajib diisi sebelum menggunakan Require
+	permissions PermissionResolver
+
+	// apiKeys diisi melalui SetAPIKeyAuthenticator, jika nil api key selalu ditolak
+	apiKeys APIKeyAuthenticator
+
+	// orgRoles diisi melalui SetOrgRoleResolver, jika nil token yang memiliki organisasi aktif ditolak
+	orgRoles OrgRoleResolver
 )
 
+// RevocationChecker memeriksa apakah token sudah dicabut (logout atau dicabut admin)
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *mjwt.CustomClaim) (bool, rest_err.APIError)
+}
+
+// SetRevocationChecker memasang pengecekan pencabutan token untuk semua middleware auth
+func SetRevocationChecker(checker RevocationChecker) {
+	revocation = checker
+}
+
+// PermissionResolver memeriksa apakah gabungan permission dari roles memiliki semua permission yang diminta
+type PermissionResolver interface {
+	HasPermissions(ctx context.Context, roles []string, permissions []string) (bool, rest_err.APIError)
+}
+
+// SetPermissionResolver memasang resolver role -> permission untuk middleware Require
+func SetPermissionResolver(resolver PermissionResolver) {
+	permissions = resolver
+}
+
+// APIKeyAuthenticator memvalidasi api key dan mengembalikan principal nya dalam bentuk CustomClaim
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*mjwt.CustomClaim, rest_err.APIError)
+}
+
+// SetAPIKeyAuthenticator memasang autentikasi api key untuk middleware Require
+func SetAPIKeyAuthenticator(authenticator APIKeyAuthenticator) {
+	apiKeys = authenticator
+}
+
+// OrgRoleResolver mengembalikan role user di dalam organisasi, false jika user bukan anggota
+type OrgRoleResolver interface {
+	OrgRoles(ctx context.Context, orgID string, username string) ([]string, bool, rest_err.APIError)
+}
+
+// SetOrgRoleResolver memasang resolver keanggotaan organisasi untuk semua middleware auth
+func SetOrgRoleResolver(resolver OrgRoleResolver) {
+	orgRoles = resolver
+}
+
 const (
-	headerKey = "Authorization"
-	bearerKey = "Bearer"
+	headerKey       = "Authorization"
+	bearerKey       = "Bearer"
+	apiKeyScheme    = "ApiKey"
+	apiKeyHeaderKey = "X-API-Key"
 )
 
 // NormalAuth memerlukan salah satu role inputan agar diloloskan ke proses berikutnya
@@ -23,63 +79,175 @@ const (
 func NormalAuth(rolesReq ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get(headerKey)
-		claims, err := authHaveRoleValidator(authHeader, false, rolesReq)
+		claims, err := authHaveRoleValidator(c.UserContext(), authHeader, false, false, rolesReq)
 		if err != nil {
-			return c.Status(err.Status()).JSON(fiber.Map{"error": err, "data": nil})
+			return err
+		}
+		useLanguage(c, claims)
+		if err := resolveOrg(c, claims); err != nil {
+			return err
 		}
 		c.Locals(mjwt.CLAIMS, claims)
-		return c.Next()
+		return principalScope(c, claims)
 	}
 }
 
 // FreshAuth memerlukan salah satu role inputan agar diloloskan ke proses berikutnya
-// token harus fresh (tidak hasil dari refresh token)
+// token harus fresh (tidak hasil dari refresh token).
+// token user yang wajib mengganti password tetap diterima agar dapat mengakses endpoint ganti password
 func FreshAuth(rolesReq ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get(headerKey)
-		claims, err := authHaveRoleValidator(authHeader, true, rolesReq)
+		claims, err := authHaveRoleValidator(c.UserContext(), authHeader, true, true, rolesReq)
 		if err != nil {
-			return c.Status(err.Status()).JSON(fiber.Map{"error": err, "data": nil})
+			return err
+		}
+		useLanguage(c, claims)
+		if err := resolveOrg(c, claims); err != nil {
+			return err
 		}
 
 		c.Locals(mjwt.CLAIMS, claims)
-		return c.Next()
+		return principalScope(c, claims)
 	}
 }
 
-func authHaveRoleValidator(authHeader string, mustFresh bool, rolesAllowed []string) (*mjwt.CustomClaim, rest_err.APIError) {
-	if !strings.Contains(authHeader, bearerKey) {
-		apiErr := rest_err.NewUnauthorizedError("Unauthorized")
+// Require memerlukan token yang memiliki semua permission inputan, contoh Require("product:delete").
+// permission dicari dari role di dalam token dan role user di organisasi aktif,
+// sehingga perubahan permission role dan keanggotaan organisasi berlaku tanpa login ulang.
+// token user yang emailnya belum diverifikasi hanya dapat mengakses dengan method GET dan HEAD.
+// selain jwt, Require menerima api key melalui header Authorization: ApiKey {key} atau X-API-Key: {key},
+// api key harus memiliki permission pada scopes nya dan role pemiliknya
+func Require(permissionsReq ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, err := requestPrincipal(c)
+		if err != nil {
+			return err
+		}
+		useLanguage(c, claims)
+
+		if claims.EmailUnverified && c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return rest_err.ErrEmailUnverified
+		}
+		if err := resolveOrg(c, claims); err != nil {
+			return err
+		}
+
+		if len(permissionsReq) != 0 {
+			if permissions == nil {
+				return rest_err.NewInternalServerError("rbac.resolver_missing", nil)
+			}
+			allowed, err := permissions.HasPermissions(c.UserContext(), claims.EffectiveRoles(), permissionsReq)
+			if err != nil {
+				return err
+			}
+			if !allowed || (claims.APIKeyID != "" && !sfunc.AllValueInSliceIsValid(permissionsReq, claims.Scopes)) {
+				return rest_err.ErrPermissionDenied.With("error.permission_required", permissionsReq)
+			}
+		}
+
+		c.Locals(mjwt.CLAIMS, claims)
+		return principalScope(c, claims)
+	}
+}
+
+// resolveOrg mengisi claims.OrgRoles dan organisasi aktif di c.UserContext() jika token memiliki organisasi aktif.
+// token ditolak jika user sudah tidak menjadi anggota organisasi tersebut
+func resolveOrg(c *fiber.Ctx, claims *mjwt.CustomClaim) error {
+	if claims.OrgID == "" {
+		return nil
+	}
+	if orgRoles == nil {
+		return rest_err.NewInternalServerError("org.resolver_missing", nil)
+	}
+
+	roles, member, err := orgRoles.OrgRoles(c.UserContext(), claims.OrgID, claims.Identity)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return rest_err.NewForbiddenError("org.not_member_refresh", claims.OrgID)
+	}
+
+	claims.OrgRoles = roles
+	c.SetUserContext(morg.ContextWithOrgID(c.UserContext(), claims.OrgID))
+	return nil
+}
+
+// requestPrincipal membaca principal dari api key jika dikirim, selain itu dari bearer token
+func requestPrincipal(c *fiber.Ctx) (*mjwt.CustomClaim, error) {
+	key := c.Get(apiKeyHeaderKey)
+	if authHeader := c.Get(headerKey); key == "" && strings.HasPrefix(authHeader, apiKeyScheme+" ") {
+		key = strings.TrimSpace(strings.TrimPrefix(authHeader, apiKeyScheme+" "))
+	}
+	if key == "" {
+		return authHaveRoleValidator(c.UserContext(), c.Get(headerKey), false, false, nil)
+	}
+
+	if apiKeys == nil {
+		return nil, rest_err.NewUnauthorizedError("apikey.unsupported")
+	}
+	_, span := mtrace.Start(c.UserContext(), "apikey.Authenticate")
+	claims, apiErr := apiKeys.Authenticate(c.UserContext(), key)
+	span.End()
+	if apiErr != nil {
 		return nil, apiErr
 	}
+	return claims, nil
+}
+
+func authHaveRoleValidator(ctx context.Context, authHeader string, mustFresh bool, allowMustChangePassword bool, rolesAllowed []string) (*mjwt.CustomClaim, error) {
+	if !strings.Contains(authHeader, bearerKey) {
+		return nil, rest_err.ErrUnauthorized
+	}
 	tokenString := strings.Split(authHeader, " ")
 	if len(tokenString) != 2 {
-		apiErr := rest_err.NewUnauthorizedError("Unauthorized")
-		return nil, apiErr
+		return nil, rest_err.ErrUnauthorized
 	}
+	_, span := mtrace.Start(ctx, "jwt.Validate")
 	token, apiErr := jwt.ValidateToken(tokenString[1])
 	if apiErr != nil {
+		mtrace.RecordError(span, apiErr)
+		span.End()
 		return nil, apiErr
 	}
 	claims, apiErr := jwt.ReadToken(token)
+	span.End()
 	if apiErr != nil {
 		return nil, apiErr
 	}
+	// refresh token dan token mfa pending tidak dapat digunakan untuk mengakses endpoint
+	if claims.Type != mjwt.Access {
+		return nil, rest_err.ErrAccessTokenRequired
+	}
+	if revocation != nil {
+		revoked, apiErr := revocation.IsRevoked(ctx, claims)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		if revoked {
+			return nil, rest_err.ErrTokenRevoked
+		}
+	}
+
+	if claims.MustChangePassword && !allowMustChangePassword {
+		return nil, rest_err.ErrPasswordChangeRequired
+	}
+
 	if mustFresh {
 		if !claims.Fresh {
-			apiErr := rest_err.NewUnauthorizedError("Memerlukan token yang baru untuk mengakses halaman ini")
-			return nil, apiErr
+			return nil, rest_err.ErrFreshTokenRequired
 		}
 	}
 
-	if len(rolesAllowed) != 0 {
-		if sfunc.InSlice(claims.Roles, rolesAllowed) {
+	if len(rolesAllowed) == 0 {
+		return claims, nil
+	}
+	for _, role := range claims.Roles {
+		if sfunc.InSlice(role, rolesAllowed) {
 			return claims, nil
 		}
-	} else {
-		return claims, nil
 	}
 
-	apiErr = rest_err.NewUnauthorizedError(fmt.Sprintf("Unauthorized, memerlukan hak akses %s", rolesAllowed))
-	return nil, apiErr
+	return nil, rest_err.ErrPermissionDenied.With("error.role_required", rolesAllowed)
 }
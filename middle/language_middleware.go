@@ -0,0 +1,27 @@
+package middle
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mi18n"
+	"github.com/muchlist/sagasql/utils/mjwt"
+)
+
+// Language memilih bahasa pesan response dari header Accept-Language dan menyimpannya di c.UserContext(),
+// bahasa yang tidak didukung diabaikan sehingga menggunakan mi18n.DefaultLang.
+// preferensi bahasa user yang login menggantikan pilihan ini (lihat useLanguage)
+func Language() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Vary(fiber.HeaderAcceptLanguage)
+		if lang := mi18n.Negotiate(c.Get(fiber.HeaderAcceptLanguage)); lang != "" {
+			c.SetUserContext(mi18n.ContextWithLang(c.UserContext(), lang))
+		}
+		return c.Next()
+	}
+}
+
+// useLanguage menggunakan preferensi bahasa user pada token jika ada
+func useLanguage(c *fiber.Ctx, claims *mjwt.CustomClaim) {
+	if lang := mi18n.Supported(claims.Language); lang != "" {
+		c.SetUserContext(mi18n.ContextWithLang(c.UserContext(), lang))
+	}
+}
@@ -0,0 +1,33 @@
+package middle
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"time"
+)
+
+// routeUnmatched label route untuk request yang tidak cocok dengan route manapun,
+// agar path mentah (yang jumlahnya tidak terbatas) tidak dijadikan label
+const routeUnmatched = "unmatched"
+
+// Metrics mencatat jumlah dan durasi request berdasarkan route template (contoh /api/v1/products/:id)
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = errorStatus(err)
+		}
+
+		route := c.Route()
+		routePath := route.Path
+		if route.Method == "USE" {
+			routePath = routeUnmatched
+		}
+
+		mmetric.ObserveHTTP(routePath, c.Method(), status, start)
+		return err
+	}
+}
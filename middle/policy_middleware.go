@@ -0,0 +1,30 @@
+package middle
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/rest_err"
+)
+
+// ResourcePolicy memutuskan apakah pemilik claims boleh mengakses resource dengan id tertentu.
+// penolakan dikembalikan sebagai error 403
+type ResourcePolicy interface {
+	Authorize(ctx context.Context, claims *mjwt.CustomClaim, resourceID string) rest_err.APIError
+}
+
+// Authorize mengevaluasi policy terhadap resource pada parameter url idParam,
+// harus dipasang setelah middleware auth (NormalAuth, FreshAuth atau Require) yang mengisi claims
+func Authorize(policy ResourcePolicy, idParam string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals(mjwt.CLAIMS).(*mjwt.CustomClaim)
+		if !ok || claims == nil {
+			return rest_err.ErrUnauthorized
+		}
+
+		if err := policy.Authorize(c.UserContext(), claims, c.Params(idParam)); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
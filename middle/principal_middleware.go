@@ -0,0 +1,40 @@
+package middle
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/rest_err"
+)
+
+// principalScope menjalankan handler berikutnya dengan query database atas nama claims (lihat db.WithPrincipal),
+// sehingga row level security membatasi data yang dapat dibaca dan diubah walaupun query dao keliru.
+// transaksi baru dimulai pada query pertama dan di-commit jika response bukan 5xx,
+// perubahan sebelum error 4xx (misal pencatatan percobaan gagal) tetap tersimpan
+func principalScope(c *fiber.Ctx, claims *mjwt.CustomClaim) error {
+	ctx := db.WithPrincipal(c.UserContext(), db.Principal{
+		Username: claims.Identity,
+		Roles:    claims.EffectiveRoles(),
+		OrgID:    claims.OrgID,
+	})
+	defer db.Rollback(ctx)
+	c.SetUserContext(ctx)
+
+	handlerErr := c.Next()
+	status := c.Response().StatusCode()
+	if handlerErr != nil {
+		status = errorStatus(handlerErr)
+	}
+	if status >= fiber.StatusInternalServerError {
+		return handlerErr
+	}
+
+	if err := db.Commit(ctx); err != nil {
+		// query yang gagal membatalkan transaksi, errornya sudah dikembalikan oleh handler
+		if status >= fiber.StatusBadRequest {
+			return handlerErr
+		}
+		return rest_err.NewInternalServerError("db.commit_failed", err)
+	}
+	return handlerErr
+}
@@ -0,0 +1,62 @@
+package middle
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"regexp"
+	"time"
+)
+
+// requestIDPattern membatasi request id dari client agar tidak bisa menyisipkan
+// karakter aneh atau string yang sangat panjang ke dalam log
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+// RequestID meneruskan X-Request-ID dari client atau membuat yang baru,
+// menyimpannya di c.UserContext() dan mengembalikannya pada response header
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(mlog.HeaderRequestID)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = mlog.NewRequestID()
+		}
+
+		c.Set(mlog.HeaderRequestID, requestID)
+		c.SetUserContext(mlog.ContextWithRequestID(c.UserContext(), requestID))
+		return c.Next()
+	}
+}
+
+// AccessLog menulis satu baris log json untuk setiap request yang selesai
+func AccessLog(log mlog.LoggerAssumer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = errorStatus(err)
+		}
+
+		fields := []mlog.Field{
+			mlog.String("method", c.Method()),
+			mlog.String("path", c.Path()),
+			mlog.String("route", c.Route().Path),
+			mlog.Int("status", status),
+			mlog.Duration("latency_ms", time.Since(start)),
+			mlog.String("ip", c.IP()),
+		}
+		if err != nil {
+			fields = append(fields, mlog.Err(err))
+		}
+
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			log.Error(c.UserContext(), "request", fields...)
+		case status >= fiber.StatusBadRequest:
+			log.Warn(c.UserContext(), "request", fields...)
+		default:
+			log.Info(c.UserContext(), "request", fields...)
+		}
+		return err
+	}
+}
@@ -0,0 +1,74 @@
+package middle
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/muchlist/sagasql/utils/mtrace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// Tracing membuat span untuk setiap request. traceparent dari client dibaca sebagai parent,
+// dan traceparent span ini dikirim kembali pada response header.
+// ctx yang berisi span disimpan di c.UserContext() agar diteruskan ke service dan dao
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		propagator := otel.GetTextMapPropagator()
+		ctx := propagator.Extract(c.UserContext(), fiberCarrier{c: c})
+
+		ctx, span := mtrace.Start(ctx, "HTTP "+c.Method(),
+			semconv.HTTPMethodKey.String(c.Method()),
+			semconv.HTTPTargetKey.String(c.OriginalURL()),
+		)
+		defer span.End()
+
+		span.SetAttributes(semconv.HTTPClientIPKey.String(c.IP()))
+		propagator.Inject(ctx, fiberCarrier{c: c})
+
+		c.SetUserContext(ctx)
+		err := c.Next()
+
+		route := c.Route()
+		if route.Method != "USE" {
+			span.SetName(c.Method() + " " + route.Path)
+			span.SetAttributes(semconv.HTTPRouteKey.String(route.Path))
+		}
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = errorStatus(err)
+		}
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		// error 4xx adalah kesalahan client, span hanya ditandai error untuk 5xx
+		if status >= fiber.StatusInternalServerError {
+			if err != nil {
+				mtrace.RecordError(span, err)
+			} else {
+				span.SetStatus(codes.Error, "")
+			}
+		}
+		return err
+	}
+}
+
+// fiberCarrier menghubungkan propagator otel dengan header fiber,
+// Get membaca request header dan Set menulis response header
+type fiberCarrier struct {
+	c *fiber.Ctx
+}
+
+func (f fiberCarrier) Get(key string) string {
+	return f.c.Get(key)
+}
+
+func (f fiberCarrier) Set(key string, value string) {
+	f.c.Set(key, value)
+}
+
+func (f fiberCarrier) Keys() []string {
+	var keys []string
+	f.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
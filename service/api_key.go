@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix awalan setiap api key agar mudah dikenali (misal oleh secret scanner).
+// format key : sgk_{id}_{secret}, id disimpan apa adanya untuk pencarian sedangkan key lengkap hanya hash nya
+const APIKeyPrefix = "sgk"
+
+func NewAPIKeyService(dao dao.APIKeyDaoAssumer, userDao dao.UserDaoAssumer, rbac RBACServiceAssumer, orgs OrgServiceAssumer, log mlog.LoggerAssumer) APIKeyServiceAssumer {
+	return &apiKeyService{
+		dao:     dao,
+		userDao: userDao,
+		rbac:    rbac,
+		orgs:    orgs,
+		log:     log,
+	}
+}
+
+type apiKeyService struct {
+	dao     dao.APIKeyDaoAssumer
+	userDao dao.UserDaoAssumer
+	rbac    RBACServiceAssumer
+	orgs    OrgServiceAssumer
+	log     mlog.LoggerAssumer
+}
+
+type APIKeyServiceAssumer interface {
+	Create(ctx context.Context, createdBy string, request dto.APIKeyRequest) (*dto.APIKeyCreateResponse, rest_err.APIError)
+	Find(ctx context.Context) ([]dto.APIKey, rest_err.APIError)
+	Revoke(ctx context.Context, id string) rest_err.APIError
+	Authenticate(ctx context.Context, key string) (*mjwt.CustomClaim, rest_err.APIError)
+}
+
+// Create menerbitkan api key atas nama user, scopes harus dimiliki oleh role user tersebut.
+// jika OrgID diisi api key dibatasi pada organisasi tersebut dan user harus menjadi anggotanya
+func (a *apiKeyService) Create(ctx context.Context, createdBy string, request dto.APIKeyRequest) (*dto.APIKeyCreateResponse, rest_err.APIError) {
+	owner, err := a.userDao.Get(ctx, request.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerRoles := owner.Roles
+	if request.OrgID != "" {
+		orgRoles, member, err := a.orgs.OrgRoles(ctx, request.OrgID, string(owner.Username))
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, rest_err.NewBadRequestError("org.user_not_member", owner.Username, request.OrgID)
+		}
+		ownerRoles = append(append([]string{}, owner.Roles...), orgRoles...)
+	}
+
+	granted, err := a.rbac.HasPermissions(ctx, ownerRoles, request.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if !granted {
+		return nil, rest_err.NewBadRequestError("apikey.scopes_not_owned", request.Scopes, owner.Username)
+	}
+
+	now := time.Now()
+	if request.ExpiresAt != 0 && request.ExpiresAt <= now.Unix() {
+		return nil, rest_err.NewBadRequestError("apikey.expiry_in_past")
+	}
+
+	id, secret, genErr := newAPIKeyParts()
+	if genErr != nil {
+		return nil, rest_err.NewInternalServerError("apikey.generate_failed", genErr)
+	}
+	key := fmt.Sprintf("%s_%s_%s", APIKeyPrefix, id, secret)
+
+	apiKey := dto.APIKey{
+		ID:        id,
+		KeyHash:   hashAPIKey(key),
+		Name:      request.Name,
+		Username:  string(owner.Username),
+		Scopes:    request.Scopes,
+		CreatedBy: createdBy,
+		CreatedAt: now.Unix(),
+		OrgID:     request.OrgID,
+	}
+	if request.ExpiresAt != 0 {
+		apiKey.ExpiresAt = &request.ExpiresAt
+	}
+	if err := a.dao.Insert(ctx, apiKey); err != nil {
+		return nil, err
+	}
+
+	a.log.Info(ctx, "api key diterbitkan", mlog.String("api_key_id", id), mlog.String("username", apiKey.Username), mlog.Any("scopes", apiKey.Scopes))
+	return &dto.APIKeyCreateResponse{
+		Key:    key,
+		APIKey: apiKey,
+	}, nil
+}
+
+func (a *apiKeyService) Find(ctx context.Context) ([]dto.APIKey, rest_err.APIError) {
+	return a.dao.Find(ctx)
+}
+
+func (a *apiKeyService) Revoke(ctx context.Context, id string) rest_err.APIError {
+	if err := a.dao.Revoke(ctx, id, time.Now().Unix()); err != nil {
+		return err
+	}
+	a.log.Info(ctx, "api key dicabut", mlog.String("api_key_id", id))
+	return nil
+}
+
+// Authenticate memvalidasi api key dan mengembalikan principal berbentuk CustomClaim
+// sehingga handler yang membaca claims dapat digunakan tanpa perubahan
+func (a *apiKeyService) Authenticate(ctx context.Context, key string) (*mjwt.CustomClaim, rest_err.APIError) {
+	unauthorized := rest_err.NewUnauthorizedError("apikey.invalid")
+
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != APIKeyPrefix {
+		return nil, unauthorized
+	}
+
+	apiKey, err := a.dao.Get(ctx, parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil || subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashAPIKey(key))) != 1 {
+		a.log.Warn(ctx, "autentikasi menggunakan api key tidak valid", mlog.String("api_key_id", parts[1]))
+		return nil, unauthorized
+	}
+
+	now := time.Now().Unix()
+	if apiKey.RevokedAt != nil {
+		return nil, rest_err.NewUnauthorizedError("apikey.revoked")
+	}
+	if apiKey.ExpiresAt != nil && *apiKey.ExpiresAt <= now {
+		return nil, rest_err.NewUnauthorizedError("apikey.expired")
+	}
+
+	if err := a.dao.TouchLastUsed(ctx, apiKey.ID, now); err != nil {
+		return nil, err
+	}
+
+	claims := mjwt.CustomClaim{
+		Identity: apiKey.Username,
+		Name:     apiKey.Name,
+		Roles:    apiKey.OwnerRoles,
+		Type:     mjwt.Access,
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+		OrgID:    apiKey.OrgID,
+	}
+	if apiKey.ExpiresAt != nil {
+		claims.Exp = *apiKey.ExpiresAt
+	}
+	return &claims, nil
+}
+
+// newAPIKeyParts membuat id 8 byte dan secret 32 byte dalam bentuk hex
+func newAPIKeyParts() (string, string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(id), hex.EncodeToString(secret), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeAPIKeyDao menyimpan api key di memory berdasarkan id, hanya Get dan TouchLastUsed yang digunakan Authenticate
+type fakeAPIKeyDao struct {
+	t       *testing.T
+	keys    map[string]*dto.APIKey
+	touched []string
+}
+
+var _ dao.APIKeyDaoAssumer = (*fakeAPIKeyDao)(nil)
+
+func (f *fakeAPIKeyDao) Get(_ context.Context, id string) (*dto.APIKey, rest_err.APIError) {
+	key, ok := f.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (f *fakeAPIKeyDao) TouchLastUsed(_ context.Context, id string, _ int64) rest_err.APIError {
+	f.touched = append(f.touched, id)
+	return nil
+}
+
+func (f *fakeAPIKeyDao) Insert(_ context.Context, _ dto.APIKey) rest_err.APIError {
+	return unexpectedCall(f.t, "Insert")
+}
+
+func (f *fakeAPIKeyDao) Find(_ context.Context) ([]dto.APIKey, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "Find")
+}
+
+func (f *fakeAPIKeyDao) Revoke(_ context.Context, _ string, _ int64) rest_err.APIError {
+	return unexpectedCall(f.t, "Revoke")
+}
+
+// newTestAPIKey membuat api key seperti Create dan menyimpannya pada fake dao, mengembalikan key lengkap
+func newTestAPIKey(t *testing.T, fake *fakeAPIKeyDao, change func(*dto.APIKey)) string {
+	t.Helper()
+	id, secret, err := newAPIKeyParts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := APIKeyPrefix + "_" + id + "_" + secret
+	apiKey := &dto.APIKey{
+		ID:         id,
+		KeyHash:    hashAPIKey(key),
+		Name:       "sinkronisasi pos",
+		Username:   "POS",
+		Scopes:     []string{"product:read"},
+		OwnerRoles: []string{"ADMIN"},
+		OrgID:      "org-1",
+	}
+	if change != nil {
+		change(apiKey)
+	}
+	fake.keys[id] = apiKey
+	return key
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	fake := &fakeAPIKeyDao{t: t, keys: map[string]*dto.APIKey{}}
+	service := NewAPIKeyService(fake, nil, nil, nil, mlog.New(io.Discard, &mlog.LevelVar{}))
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	key := newTestAPIKey(t, fake, func(k *dto.APIKey) { k.ExpiresAt = &expiresAt })
+
+	claims, apiErr := service.Authenticate(context.Background(), key)
+	if apiErr != nil {
+		t.Fatalf("Authenticate: %v", apiErr)
+	}
+	apiKey := fake.keys[claims.APIKeyID]
+	if apiKey == nil || claims.Identity != "POS" || claims.OrgID != "org-1" || claims.Exp != expiresAt {
+		t.Fatalf("claims tidak sesuai: %+v", claims)
+	}
+	if len(claims.Scopes) != 1 || claims.Scopes[0] != "product:read" || len(claims.Roles) != 1 || claims.Roles[0] != "ADMIN" {
+		t.Fatalf("scopes atau roles tidak sesuai: %+v", claims)
+	}
+	if len(fake.touched) != 1 || fake.touched[0] != apiKey.ID {
+		t.Fatalf("last used seharusnya diperbarui: %v", fake.touched)
+	}
+}
+
+func TestAPIKeyAuthenticateRejected(t *testing.T) {
+	fake := &fakeAPIKeyDao{t: t, keys: map[string]*dto.APIKey{}}
+	service := NewAPIKeyService(fake, nil, nil, nil, mlog.New(io.Discard, &mlog.LevelVar{}))
+	valid := newTestAPIKey(t, fake, nil)
+	revokedAt := time.Now().Add(-time.Minute).Unix()
+	revoked := newTestAPIKey(t, fake, func(k *dto.APIKey) { k.RevokedAt = &revokedAt })
+	expiresAt := time.Now().Unix()
+	expired := newTestAPIKey(t, fake, func(k *dto.APIKey) { k.ExpiresAt = &expiresAt })
+
+	// id milik key valid namun secret diganti
+	forged := valid[:len(valid)-1] + "0"
+	if forged == valid {
+		forged = valid[:len(valid)-1] + "1"
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		message string
+	}{
+		{"kosong", "", "apikey.invalid"},
+		{"prefix lain", "abc" + valid[len(APIKeyPrefix):], "apikey.invalid"},
+		{"bagian kurang", APIKeyPrefix + "_tanpasecret", "apikey.invalid"},
+		{"id tidak dikenal", APIKeyPrefix + "_0000000000000000_rahasia", "apikey.invalid"},
+		{"secret salah", forged, "apikey.invalid"},
+		{"sudah dicabut", revoked, "apikey.revoked"},
+		{"kadaluarsa", expired, "apikey.expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, apiErr := service.Authenticate(context.Background(), tt.key)
+			if apiErr == nil {
+				t.Fatal("api key seharusnya ditolak")
+			}
+			want := rest_err.NewUnauthorizedError(tt.message)
+			if apiErr.Status() != http.StatusUnauthorized || apiErr.Message() != want.Message() {
+				t.Fatalf("err = %d %s, want %d %s", apiErr.Status(), apiErr.Message(), http.StatusUnauthorized, want.Message())
+			}
+		})
+	}
+	if len(fake.touched) != 0 {
+		t.Fatalf("last used seharusnya tidak diperbarui untuk key yang ditolak: %v", fake.touched)
+	}
+}
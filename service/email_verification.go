@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmail"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// emailVerificationTTL umur tautan verifikasi email
+	emailVerificationTTL = 24 * time.Hour
+	// emailVerifyURLKey env alamat halaman verifikasi (contoh https://api.example.com/api/v1/email/verify),
+	// token ditambahkan sebagai query ?token=. jika kosong email hanya berisi token
+	emailVerifyURLKey = "EMAIL_VERIFY_URL"
+)
+
+// UnverifiedEmailPolicy perlakuan untuk akun yang emailnya belum diverifikasi
+type UnverifiedEmailPolicy int
+
+const (
+	// UnverifiedEmailAllow akun dapat digunakan sepenuhnya
+	UnverifiedEmailAllow UnverifiedEmailPolicy = iota
+	// UnverifiedEmailReadOnly akun dapat login namun route yang memerlukan permission hanya dapat diakses dengan GET
+	UnverifiedEmailReadOnly
+	// UnverifiedEmailBlockLogin akun tidak dapat login sampai email diverifikasi
+	UnverifiedEmailBlockLogin
+)
+
+var DefaultUnverifiedEmailPolicy = UnverifiedEmailReadOnly
+
+func NewEmailVerificationService(userDao dao.UserDaoAssumer, guard LoginGuardServiceAssumer, mailer mmail.MailerAssumer, policy UnverifiedEmailPolicy, log mlog.LoggerAssumer) EmailVerificationServiceAssumer {
+	return &emailVerificationService{
+		userDao: userDao,
+		guard:   guard,
+		mailer:  mailer,
+		policy:  policy,
+		log:     log,
+	}
+}
+
+type emailVerificationService struct {
+	userDao dao.UserDaoAssumer
+	guard   LoginGuardServiceAssumer
+	mailer  mmail.MailerAssumer
+	policy  UnverifiedEmailPolicy
+	log     mlog.LoggerAssumer
+}
+
+type EmailVerificationServiceAssumer interface {
+	SendVerification(ctx context.Context, user dto.User) rest_err.APIError
+	Verify(ctx context.Context, token string) rest_err.APIError
+	Resend(ctx context.Context, request dto.EmailVerificationResendRequest, clientIP string) rest_err.APIError
+	LoginRestriction(ctx context.Context, user *dto.User) (bool, rest_err.APIError)
+}
+
+// SendVerification mengirim tautan verifikasi jika email user belum diverifikasi
+func (e *emailVerificationService) SendVerification(ctx context.Context, user dto.User) rest_err.APIError {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := mjwt.SignLink(mjwt.PurposeEmailVerification, []string{string(user.Username), user.Email}, time.Now().Add(emailVerificationTTL))
+	if err != nil {
+		return rest_err.NewInternalServerError("email.verify_link_failed", err)
+	}
+
+	sendMailAsync(ctx, e.mailer, e.log, mmail.Message{
+		To:      user.Email,
+		Subject: "Verifikasi email",
+		Body:    verificationMailBody(user.Name, token),
+	}, mlog.String("username", string(user.Username)))
+
+	e.log.Info(ctx, "tautan verifikasi email dikirim", mlog.String("username", string(user.Username)))
+	return nil
+}
+
+// Verify memverifikasi email menggunakan token dari tautan, token tidak berlaku jika email sudah diganti
+func (e *emailVerificationService) Verify(ctx context.Context, token string) rest_err.APIError {
+	values, err := mjwt.VerifyLink(mjwt.PurposeEmailVerification, token)
+	if err != nil || len(values) != 2 {
+		e.log.Warn(ctx, "verifikasi email menggunakan token tidak valid")
+		return rest_err.NewBadRequestError("email.verify_link_invalid")
+	}
+	username, email := values[0], values[1]
+
+	verified, apiErr := e.userDao.MarkEmailVerified(ctx, username, email, time.Now().Unix())
+	if apiErr != nil {
+		return apiErr
+	}
+	if !verified {
+		// email sudah diganti atau tautan sudah pernah digunakan
+		user, apiErr := e.userDao.Get(ctx, username)
+		if apiErr == nil && user.EmailVerifiedAt != nil && user.Email == email {
+			return nil
+		}
+		return rest_err.NewBadRequestError("email.verify_link_invalid")
+	}
+
+	e.log.Info(ctx, "email terverifikasi", mlog.String("username", username))
+	return nil
+}
+
+// Resend mengirim ulang tautan verifikasi, hasilnya selalu sama baik email terdaftar maupun tidak.
+// setiap permintaan dihitung per email dan ip (lihat NewEmailVerificationGuardService) sehingga inbox user tidak dapat dibanjiri
+func (e *emailVerificationService) Resend(ctx context.Context, request dto.EmailVerificationResendRequest, clientIP string) rest_err.APIError {
+	if err := e.guard.Check(ctx, request.Email, clientIP); err != nil {
+		e.log.Warn(ctx, "kirim ulang verifikasi email ditolak, masih dalam masa penundaan", mlog.String("ip", clientIP))
+		return err
+	}
+	if err := e.guard.RegisterFailure(ctx, request.Email, clientIP); err != nil {
+		return err
+	}
+
+	user, err := e.userDao.GetByEmail(ctx, request.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return e.SendVerification(ctx, *user)
+}
+
+// LoginRestriction mengembalikan error jika user tidak boleh login,
+// atau true jika token user harus dibatasi read-only karena email belum diverifikasi
+func (e *emailVerificationService) LoginRestriction(ctx context.Context, user *dto.User) (bool, rest_err.APIError) {
+	if user.EmailVerifiedAt != nil {
+		return false, nil
+	}
+
+	switch e.policy {
+	case UnverifiedEmailBlockLogin:
+		e.log.Warn(ctx, "login ditolak, email belum diverifikasi", mlog.String("username", string(user.Username)))
+		return false, rest_err.NewForbiddenError("email.not_verified")
+	case UnverifiedEmailReadOnly:
+		return true, nil
+	}
+	return false, nil
+}
+
+func verificationMailBody(name string, token string) string {
+	instruction := fmt.Sprintf("Token verifikasi : %s", token)
+	if verifyURL := os.Getenv(emailVerifyURLKey); verifyURL != "" {
+		instruction = fmt.Sprintf("Buka tautan berikut untuk memverifikasi email :\n%s?token=%s", verifyURL, url.QueryEscape(token))
+	}
+	return fmt.Sprintf(`Halo %s,
+
+Silahkan verifikasi email anda.
+%s
+
+Tautan berlaku selama %d jam.
+`, name, instruction, int(emailVerificationTTL.Hours()))
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+func NewHealthService(dao dao.HealthDaoAssumer, storageDir string, log mlog.LoggerAssumer) HealthServiceAssumer {
+	return &healthService{
+		dao:        dao,
+		storageDir: storageDir,
+		log:        log,
+	}
+}
+
+type healthService struct {
+	dao          dao.HealthDaoAssumer
+	storageDir   string
+	log          mlog.LoggerAssumer
+	shuttingDown int32
+}
+
+type HealthServiceAssumer interface {
+	Liveness() dto.HealthReport
+	Readiness(ctx context.Context) dto.HealthReport
+	MarkShuttingDown()
+}
+
+// Liveness hanya menandakan bahwa proses masih berjalan, tidak mengecek dependency
+func (h *healthService) Liveness() dto.HealthReport {
+	return dto.HealthReport{
+		Status: dto.HealthStatusUp,
+		Checks: []dto.HealthCheck{},
+	}
+}
+
+// Readiness mengecek semua dependency yang dibutuhkan untuk melayani request.
+// endpoint ini publik sehingga hanya nama dan status pengecekan yang dikembalikan, rincian error dicatat pada log
+func (h *healthService) Readiness(ctx context.Context) dto.HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	checks := []dto.HealthCheck{
+		h.runCheck(ctx, "shutdown", func() error {
+			if atomic.LoadInt32(&h.shuttingDown) == 1 {
+				return fmt.Errorf("aplikasi sedang dimatikan")
+			}
+			return nil
+		}),
+		h.runCheck(ctx, "database", func() error {
+			return h.dao.Ping(ctx)
+		}),
+		h.runCheck(ctx, "migrations", func() error {
+			pending, err := h.dao.PendingMigrations(ctx)
+			if err != nil {
+				return err
+			}
+			if len(pending) != 0 {
+				return fmt.Errorf("migrasi belum diterapkan: %s", strings.Join(pending, ", "))
+			}
+			return nil
+		}),
+		h.runCheck(ctx, "storage", h.checkStorageWritable),
+	}
+
+	report := dto.HealthReport{
+		Status: dto.HealthStatusUp,
+		Checks: checks,
+	}
+	for _, check := range checks {
+		if check.Status != dto.HealthStatusUp {
+			report.Status = dto.HealthStatusDown
+			break
+		}
+	}
+	return report
+}
+
+// MarkShuttingDown membuat readiness melaporkan not-ready selama graceful shutdown
+func (h *healthService) MarkShuttingDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+// checkStorageWritable mencoba membuat dan menghapus file sementara pada folder penyimpanan gambar
+func (h *healthService) checkStorageWritable() error {
+	file, err := os.CreateTemp(h.storageDir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+func (h *healthService) runCheck(ctx context.Context, name string, check func() error) dto.HealthCheck {
+	start := time.Now()
+	err := check()
+	result := dto.HealthCheck{
+		Name:      name,
+		Status:    dto.HealthStatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = dto.HealthStatusDown
+		h.log.Warn(ctx, "pengecekan readiness gagal", mlog.String("check", name), mlog.Err(err))
+	}
+	return result
+}
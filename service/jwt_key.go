@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"time"
+)
+
+// jwtKeyReloadInterval seberapa sering setiap instance membaca ulang kunci dari database
+const jwtKeyReloadInterval = time.Minute
+
+// JWTKeyRotationPolicy jadwal rotasi kunci asimetris.
+// kunci baru dibuat setiap Interval dan dipublikasi di jwks selama Prepublish sebelum aktif,
+// kunci lama tetap memverifikasi selama MaxTokenLifetime setelah digantikan
+type JWTKeyRotationPolicy struct {
+	Interval         time.Duration
+	Prepublish       time.Duration
+	MaxTokenLifetime time.Duration
+}
+
+var DefaultJWTKeyRotationPolicy = JWTKeyRotationPolicy{
+	Interval:         30 * 24 * time.Hour,
+	Prepublish:       time.Hour,
+	MaxTokenLifetime: 10 * 24 * time.Hour,
+}
+
+func NewJWTKeyService(dao dao.JWTKeyDaoAssumer, policy JWTKeyRotationPolicy, log mlog.LoggerAssumer) JWTKeyServiceAssumer {
+	return &jwtKeyService{
+		dao:    dao,
+		policy: policy,
+		log:    log,
+	}
+}
+
+type jwtKeyService struct {
+	dao    dao.JWTKeyDaoAssumer
+	policy JWTKeyRotationPolicy
+	log    mlog.LoggerAssumer
+}
+
+type JWTKeyServiceAssumer interface {
+	Start(ctx context.Context) rest_err.APIError
+	Sync(ctx context.Context) rest_err.APIError
+}
+
+// Start memuat kunci pertama kali lalu menjalankan rotasi dan pemuatan ulang secara berkala.
+// tidak melakukan apa-apa pada mode HS256
+func (j *jwtKeyService) Start(ctx context.Context) rest_err.APIError {
+	if !mjwt.IsAsymmetric() {
+		return nil
+	}
+	if err := j.Sync(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(jwtKeyReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := j.Sync(ctx); err != nil {
+					j.log.Error(ctx, "sinkronisasi kunci jwt gagal", mlog.Err(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Sync membuat kunci baru jika sudah jadwalnya lalu memuat semua kunci yang masih berlaku ke mjwt
+func (j *jwtKeyService) Sync(ctx context.Context) rest_err.APIError {
+	if err := j.rotateIfDue(ctx); err != nil {
+		return err
+	}
+	return j.load(ctx)
+}
+
+func (j *jwtKeyService) rotateIfDue(ctx context.Context) rest_err.APIError {
+	now := time.Now()
+	stored, err := j.dao.FindUsable(ctx, now.Unix())
+	if err != nil {
+		return err
+	}
+
+	var current *dto.JWTKey
+	if len(stored) != 0 {
+		current = &stored[0]
+	}
+
+	activatesAt := now
+	currentKID := ""
+	if current != nil {
+		currentKID = current.KID
+		if current.Algorithm == mjwt.Algorithm() {
+			scheduled := time.Unix(current.ActivatesAt, 0).Add(j.policy.Interval)
+			if now.Before(scheduled.Add(-j.policy.Prepublish)) {
+				return nil
+			}
+			// kunci berikutnya aktif sesuai jadwal, atau setelah masa publikasi jika jadwal sudah lewat
+			activatesAt = scheduled
+			if earliest := now.Add(j.policy.Prepublish); activatesAt.Before(earliest) {
+				activatesAt = earliest
+			}
+		}
+		// jika algoritma berubah kunci baru langsung aktif karena kunci lama tidak dapat dipakai
+	}
+
+	key, genErr := mjwt.NewKey(activatesAt)
+	if genErr != nil {
+		return rest_err.NewInternalServerError("jwt_key.generate_failed", genErr)
+	}
+	sealed, genErr := mjwt.SealPrivateKey(key.Private)
+	if genErr != nil {
+		return rest_err.NewInternalServerError("jwt_key.encrypt_failed", genErr)
+	}
+
+	rotated, err := j.dao.Rotate(ctx, dto.JWTKey{
+		KID:         key.KID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  sealed,
+		CreatedAt:   now.Unix(),
+		ActivatesAt: key.ActivatesAt,
+	}, currentKID, activatesAt.Add(j.policy.MaxTokenLifetime).Unix())
+	if err != nil {
+		return err
+	}
+	if rotated {
+		j.log.Info(ctx, "kunci jwt baru dibuat",
+			mlog.String("kid", key.KID),
+			mlog.String("alg", key.Algorithm),
+			mlog.Int64("activates_at", key.ActivatesAt),
+			mlog.String("previous_kid", currentKID),
+		)
+	}
+	return nil
+}
+
+func (j *jwtKeyService) load(ctx context.Context) rest_err.APIError {
+	stored, err := j.dao.FindUsable(ctx, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	keys := make([]mjwt.Key, 0, len(stored))
+	for _, s := range stored {
+		private, openErr := mjwt.OpenPrivateKey(s.PrivateKey)
+		if openErr != nil {
+			j.log.Error(ctx, "kunci jwt tidak dapat dibuka", mlog.String("kid", s.KID), mlog.Err(openErr))
+			continue
+		}
+		key := mjwt.Key{
+			KID:         s.KID,
+			Algorithm:   s.Algorithm,
+			Private:     private,
+			ActivatesAt: s.ActivatesAt,
+		}
+		if s.RetiresAt != nil {
+			key.RetiresAt = *s.RetiresAt
+		}
+		keys = append(keys, key)
+	}
+
+	mjwt.SetKeys(keys)
+	return nil
+}
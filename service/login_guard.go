@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"strings"
+	"time"
+)
+
+// LoginGuardPolicy aturan penundaan login setelah terjadi kegagalan.
+// setelah FreeAttempts kegagalan, percobaan berikutnya ditunda BaseDelay * 2^(n-FreeAttempts-1)
+// dengan batas MaxDelay. setelah LockoutAfter kegagalan, login dikunci selama LockoutDuration.
+// hitungan dimulai ulang jika tidak ada kegagalan selama Window
+type LoginGuardPolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+var (
+	// DefaultUsernameGuardPolicy kebijakan untuk setiap username
+	DefaultUsernameGuardPolicy = LoginGuardPolicy{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    5,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+
+	// DefaultIPGuardPolicy kebijakan untuk setiap ip, lebih longgar karena
+	// beberapa user dapat berbagi ip yang sama (NAT kantor)
+	DefaultIPGuardPolicy = LoginGuardPolicy{
+		FreeAttempts:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    50,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+
+	// DefaultResetEmailGuardPolicy jeda antar permintaan reset password untuk email yang sama,
+	// setiap permintaan dihitung sehingga email berikutnya baru dapat dikirim setelah 1, 2, 4 ... menit
+	DefaultResetEmailGuardPolicy = LoginGuardPolicy{
+		FreeAttempts:    0,
+		BaseDelay:       time.Minute,
+		MaxDelay:        15 * time.Minute,
+		LockoutAfter:    5,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+
+	// DefaultResetIPGuardPolicy batas permintaan reset password dari satu ip untuk email mana pun
+	DefaultResetIPGuardPolicy = LoginGuardPolicy{
+		FreeAttempts:    5,
+		BaseDelay:       time.Minute,
+		MaxDelay:        15 * time.Minute,
+		LockoutAfter:    20,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+)
+
+// delayFor menghitung lama penundaan setelah failedCount kegagalan
+func (p LoginGuardPolicy) delayFor(failedCount int) time.Duration {
+	if failedCount >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if failedCount <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failedCount; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+func NewLoginGuardService(dao dao.LoginAttemptDaoAssumer, usernamePolicy LoginGuardPolicy, ipPolicy LoginGuardPolicy, log mlog.LoggerAssumer) LoginGuardServiceAssumer {
+	return &loginGuardService{
+		dao:             dao,
+		usernamePolicy:  usernamePolicy,
+		ipPolicy:        ipPolicy,
+		usernameKeyType: dto.LoginKeyUsername,
+		ipKeyType:       dto.LoginKeyIP,
+		limitedMessage:  "error.too_many_login_attempts",
+		log:             log,
+	}
+}
+
+// NewPasswordResetGuardService penundaan yang sama dengan login namun dihitung per email dan ip permintaan reset password,
+// dicatat pada table login_attempts dengan jenis kunci tersendiri sehingga tidak mempengaruhi hitungan login
+func NewPasswordResetGuardService(dao dao.LoginAttemptDaoAssumer, emailPolicy LoginGuardPolicy, ipPolicy LoginGuardPolicy, log mlog.LoggerAssumer) LoginGuardServiceAssumer {
+	return &loginGuardService{
+		dao:             dao,
+		usernamePolicy:  emailPolicy,
+		ipPolicy:        ipPolicy,
+		usernameKeyType: dto.ResetKeyEmail,
+		ipKeyType:       dto.ResetKeyIP,
+		limitedMessage:  "error.too_many_reset_requests",
+		log:             log,
+	}
+}
+
+// NewEmailVerificationGuardService penundaan permintaan kirim ulang tautan verifikasi email per email dan ip,
+// dicatat dengan jenis kunci tersendiri seperti NewPasswordResetGuardService
+func NewEmailVerificationGuardService(dao dao.LoginAttemptDaoAssumer, emailPolicy LoginGuardPolicy, ipPolicy LoginGuardPolicy, log mlog.LoggerAssumer) LoginGuardServiceAssumer {
+	return &loginGuardService{
+		dao:             dao,
+		usernamePolicy:  emailPolicy,
+		ipPolicy:        ipPolicy,
+		usernameKeyType: dto.VerifyKeyEmail,
+		ipKeyType:       dto.VerifyKeyIP,
+		limitedMessage:  "error.too_many_verification_requests",
+		log:             log,
+	}
+}
+
+type loginGuardService struct {
+	dao             dao.LoginAttemptDaoAssumer
+	usernamePolicy  LoginGuardPolicy
+	ipPolicy        LoginGuardPolicy
+	usernameKeyType string
+	ipKeyType       string
+	limitedMessage  string
+	log             mlog.LoggerAssumer
+}
+
+type LoginGuardServiceAssumer interface {
+	Check(ctx context.Context, username string, ip string) rest_err.APIError
+	RegisterFailure(ctx context.Context, username string, ip string) rest_err.APIError
+	RegisterSuccess(ctx context.Context, username string) rest_err.APIError
+	Unlock(ctx context.Context, request dto.LoginUnlockRequest) rest_err.APIError
+}
+
+// Check mengembalikan error 429 beserta Retry-After jika username atau ip sedang ditunda
+func (l *loginGuardService) Check(ctx context.Context, username string, ip string) rest_err.APIError {
+	now := time.Now().Unix()
+	var retryAfter int64
+
+	for _, key := range l.keys(username, ip) {
+		attempt, err := l.dao.Get(ctx, key.keyType, key.key)
+		if err != nil {
+			return err
+		}
+		if attempt != nil && attempt.LockedUntil > now && attempt.LockedUntil-now > retryAfter {
+			retryAfter = attempt.LockedUntil - now
+		}
+	}
+
+	if retryAfter > 0 {
+		return rest_err.NewTooManyRequestsError(l.limitedMessage, retryAfter, retryAfter)
+	}
+	return nil
+}
+
+// RegisterFailure mencatat kegagalan login untuk username dan ip lalu menerapkan penundaan
+func (l *loginGuardService) RegisterFailure(ctx context.Context, username string, ip string) rest_err.APIError {
+	now := time.Now()
+
+	for _, key := range l.keys(username, ip) {
+		failedCount, err := l.dao.RegisterFailure(ctx, key.keyType, key.key, now.Unix(), now.Add(-key.policy.Window).Unix())
+		if err != nil {
+			return err
+		}
+
+		delay := key.policy.delayFor(failedCount)
+		if delay == 0 {
+			continue
+		}
+		if err := l.dao.Lock(ctx, key.keyType, key.key, now.Add(delay).Unix()); err != nil {
+			return err
+		}
+		if failedCount >= key.policy.LockoutAfter {
+			l.log.Warn(ctx, "login dikunci sementara",
+				mlog.String("key_type", key.keyType),
+				mlog.String("key", key.key),
+				mlog.Int("failed_count", failedCount),
+				mlog.Duration("locked_ms", delay),
+			)
+		}
+	}
+	return nil
+}
+
+// RegisterSuccess menghapus catatan kegagalan username, catatan ip dibiarkan
+// agar penyerang tidak bisa mereset hitungan ip dengan login ke akun miliknya sendiri
+func (l *loginGuardService) RegisterSuccess(ctx context.Context, username string) rest_err.APIError {
+	return l.dao.Reset(ctx, l.usernameKeyType, normalizeLoginUsername(username))
+}
+
+// Unlock membuka kunci login username dan/atau ip oleh admin
+func (l *loginGuardService) Unlock(ctx context.Context, request dto.LoginUnlockRequest) rest_err.APIError {
+	if request.Username != "" {
+		if err := l.dao.Reset(ctx, l.usernameKeyType, normalizeLoginUsername(request.Username)); err != nil {
+			return err
+		}
+	}
+	if request.IP != "" {
+		if err := l.dao.Reset(ctx, l.ipKeyType, request.IP); err != nil {
+			return err
+		}
+	}
+	l.log.Info(ctx, "kunci login dibuka oleh admin", mlog.String("username", request.Username), mlog.String("ip", request.IP))
+	return nil
+}
+
+type loginGuardKey struct {
+	keyType string
+	key     string
+	policy  LoginGuardPolicy
+}
+
+func (l *loginGuardService) keys(username string, ip string) []loginGuardKey {
+	keys := []loginGuardKey{
+		{keyType: l.usernameKeyType, key: normalizeLoginUsername(username), policy: l.usernamePolicy},
+	}
+	if ip != "" {
+		keys = append(keys, loginGuardKey{keyType: l.ipKeyType, key: ip, policy: l.ipPolicy})
+	}
+	return keys
+}
+
+// normalizeLoginUsername username disimpan dalam huruf besar (lihat dto.UppercaseString)
+// sehingga "admin" dan "ADMIN" dihitung sebagai akun yang sama
+func normalizeLoginUsername(username string) string {
+	return strings.ToUpper(username)
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmail"
+	"github.com/muchlist/sagasql/utils/mtrace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sendMailAsync mengirim email di background agar waktu response tidak bergantung pada server email.
+// ctx request dibatalkan setelah response dikirim, request id dan span parent tetap diteruskan.
+// email baru dikirim setelah transaksi request di-commit agar tidak terkirim untuk perubahan yang dibatalkan
+func sendMailAsync(ctx context.Context, mailer mmail.MailerAssumer, log mlog.LoggerAssumer, msg mmail.Message, fields ...mlog.Field) {
+	mailCtx := mlog.ContextWithRequestID(context.Background(), mlog.RequestIDFromContext(ctx))
+	mailCtx = trace.ContextWithSpan(mailCtx, trace.SpanFromContext(ctx))
+	db.AfterCommit(ctx, func() {
+		go sendMail(mailCtx, mailer, log, msg, fields...)
+	})
+}
+
+func sendMail(ctx context.Context, mailer mmail.MailerAssumer, log mlog.LoggerAssumer, msg mmail.Message, fields ...mlog.Field) {
+	ctx, span := mtrace.Start(ctx, "mail.Send")
+	defer span.End()
+	if err := mailer.Send(ctx, msg); err != nil {
+		mtrace.RecordError(span, err)
+		log.Error(ctx, "email gagal dikirim", append(fields, mlog.String("subject", msg.Subject), mlog.Err(err))...)
+	}
+}
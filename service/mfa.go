@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/motp"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// mfaIssuerKey env nama aplikasi yang tampil pada aplikasi authenticator
+	mfaIssuerKey     = "MFA_ISSUER"
+	mfaDefaultIssuer = "sagasql"
+	// recoveryCodeCount jumlah recovery code yang diterbitkan setiap kali enrolment atau regenerasi
+	recoveryCodeCount = 10
+)
+
+func NewMFAService(dao dao.MFADaoAssumer, userDao dao.UserDaoAssumer, rbac RBACServiceAssumer, revocation TokenRevocationServiceAssumer, log mlog.LoggerAssumer) MFAServiceAssumer {
+	return &mfaService{
+		dao:        dao,
+		userDao:    userDao,
+		rbac:       rbac,
+		revocation: revocation,
+		log:        log,
+	}
+}
+
+type mfaService struct {
+	dao        dao.MFADaoAssumer
+	userDao    dao.UserDaoAssumer
+	rbac       RBACServiceAssumer
+	revocation TokenRevocationServiceAssumer
+	log        mlog.LoggerAssumer
+}
+
+type MFAServiceAssumer interface {
+	SealLegacySecrets(ctx context.Context) error
+	Enabled(ctx context.Context, username string) (bool, rest_err.APIError)
+	Enroll(ctx context.Context, username string) (*dto.MFAEnrollResponse, rest_err.APIError)
+	Enable(ctx context.Context, username string, code string) ([]string, rest_err.APIError)
+	Verify(ctx context.Context, username string, code string) rest_err.APIError
+	Disable(ctx context.Context, username string, roles []string, code string) rest_err.APIError
+	RegenerateRecoveryCodes(ctx context.Context, username string, code string) ([]string, rest_err.APIError)
+	Reset(ctx context.Context, username string) rest_err.APIError
+}
+
+// SealLegacySecrets mengenkripsi secret yang tersimpan tanpa enkripsi sebelum migrasi 0019,
+// dipanggil sekali ketika aplikasi dijalankan
+func (m *mfaService) SealLegacySecrets(ctx context.Context) error {
+	unsealed, err := m.dao.FindUnsealed(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mfa := range unsealed {
+		sealed, sealErr := mjwt.SealSecret(mjwt.PurposeTOTPSecret, mfa.Secret)
+		if sealErr != nil {
+			return sealErr
+		}
+		if err := m.dao.ReplaceSecret(ctx, mfa.Username, mfa.Secret, sealed); err != nil {
+			return err
+		}
+	}
+	if len(unsealed) != 0 {
+		m.log.Info(ctx, "secret 2FA lama dienkripsi", mlog.Int("count", len(unsealed)))
+	}
+	return nil
+}
+
+// getWithSecret seperti dao.Get dengan secret yang sudah didekripsi
+func (m *mfaService) getWithSecret(ctx context.Context, username string) (*dto.UserMFA, rest_err.APIError) {
+	mfa, err := m.dao.Get(ctx, username)
+	if err != nil || mfa == nil {
+		return mfa, err
+	}
+	secret, openErr := mjwt.OpenSecret(mjwt.PurposeTOTPSecret, mfa.Secret)
+	if openErr != nil {
+		return nil, rest_err.NewInternalServerError("mfa.secret_unreadable", openErr)
+	}
+	mfa.Secret = secret
+	return mfa, nil
+}
+
+// Enabled true jika user sudah menyelesaikan enrolment 2FA
+func (m *mfaService) Enabled(ctx context.Context, username string) (bool, rest_err.APIError) {
+	mfa, err := m.dao.Get(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.EnabledAt != nil, nil
+}
+
+// Enroll membuat secret baru yang belum aktif sampai kode pertama diverifikasi melalui Enable
+func (m *mfaService) Enroll(ctx context.Context, username string) (*dto.MFAEnrollResponse, rest_err.APIError) {
+	enabled, err := m.Enabled(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, rest_err.NewBadRequestError("mfa.already_enabled")
+	}
+
+	secret, genErr := motp.GenerateSecret()
+	if genErr != nil {
+		return nil, rest_err.NewInternalServerError("mfa.secret_failed", genErr)
+	}
+	sealed, genErr := mjwt.SealSecret(mjwt.PurposeTOTPSecret, secret)
+	if genErr != nil {
+		return nil, rest_err.NewInternalServerError("mfa.secret_failed", genErr)
+	}
+	if err := m.dao.StartEnrollment(ctx, username, sealed, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+
+	issuer := os.Getenv(mfaIssuerKey)
+	if issuer == "" {
+		issuer = mfaDefaultIssuer
+	}
+
+	m.log.Info(ctx, "enrolment 2FA dimulai", mlog.String("username", username))
+	return &dto.MFAEnrollResponse{
+		Secret: secret,
+		URI:    motp.URI(issuer, strings.ToUpper(username), secret),
+	}, nil
+}
+
+// Enable memverifikasi kode pertama dari aplikasi authenticator lalu mengaktifkan 2FA,
+// mengembalikan recovery code yang hanya ditampilkan sekali
+func (m *mfaService) Enable(ctx context.Context, username string, code string) ([]string, rest_err.APIError) {
+	mfa, err := m.getWithSecret(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || mfa.EnabledAt != nil {
+		return nil, rest_err.NewBadRequestError("mfa.no_pending_enrollment")
+	}
+
+	now := time.Now()
+	step, ok := motp.Validate(mfa.Secret, code, now)
+	if !ok {
+		m.log.Warn(ctx, "aktivasi 2FA gagal, kode salah", mlog.String("username", username))
+		return nil, rest_err.NewUnauthorizedError("mfa.code_invalid")
+	}
+
+	codes, hashes, genErr := newRecoveryCodes()
+	if genErr != nil {
+		return nil, rest_err.NewInternalServerError("mfa.recovery_code_failed", genErr)
+	}
+	enabled, err := m.dao.Enable(ctx, username, step, now.Unix(), hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, rest_err.NewBadRequestError("mfa.no_pending_enrollment")
+	}
+
+	m.log.Info(ctx, "2FA diaktifkan", mlog.String("username", username))
+	return codes, nil
+}
+
+// Verify mencocokkan kode TOTP atau recovery code, keduanya hanya dapat digunakan sekali
+func (m *mfaService) Verify(ctx context.Context, username string, code string) rest_err.APIError {
+	mfa, err := m.getWithSecret(ctx, username)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || mfa.EnabledAt == nil {
+		return rest_err.NewBadRequestError("mfa.not_enabled")
+	}
+
+	now := time.Now()
+	code = strings.TrimSpace(code)
+	if len(code) == motp.Digits {
+		step, ok := motp.Validate(mfa.Secret, code, now)
+		if ok {
+			used, err := m.dao.UseStep(ctx, username, step)
+			if err != nil {
+				return err
+			}
+			if used {
+				return nil
+			}
+			m.log.Warn(ctx, "kode 2FA dipakai ulang", mlog.String("username", username))
+		}
+	} else {
+		consumed, err := m.dao.ConsumeRecoveryCode(ctx, username, hashRecoveryCode(code), now.Unix())
+		if err != nil {
+			return err
+		}
+		if consumed {
+			m.log.Info(ctx, "recovery code digunakan", mlog.String("username", username))
+			return nil
+		}
+	}
+
+	m.log.Warn(ctx, "verifikasi 2FA gagal", mlog.String("username", username))
+	return rest_err.NewUnauthorizedError("mfa.code_invalid")
+}
+
+// Disable menonaktifkan 2FA setelah kode diverifikasi, ditolak jika salah satu role user mewajibkan 2FA
+func (m *mfaService) Disable(ctx context.Context, username string, roles []string, code string) rest_err.APIError {
+	required, err := m.rbac.MFARequired(ctx, roles)
+	if err != nil {
+		return err
+	}
+	if required {
+		return rest_err.NewForbiddenError("mfa.required_by_role")
+	}
+
+	if err := m.Verify(ctx, username, code); err != nil {
+		return err
+	}
+	if err := m.dao.Delete(ctx, username); err != nil {
+		return err
+	}
+
+	m.log.Info(ctx, "2FA dinonaktifkan", mlog.String("username", username))
+	return nil
+}
+
+// RegenerateRecoveryCodes mengganti seluruh recovery code, recovery code lama tidak berlaku lagi
+func (m *mfaService) RegenerateRecoveryCodes(ctx context.Context, username string, code string) ([]string, rest_err.APIError) {
+	if err := m.Verify(ctx, username, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, genErr := newRecoveryCodes()
+	if genErr != nil {
+		return nil, rest_err.NewInternalServerError("mfa.recovery_code_failed", genErr)
+	}
+	if err := m.dao.ReplaceRecoveryCodes(ctx, username, hashes, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+
+	m.log.Info(ctx, "recovery code 2FA diganti", mlog.String("username", username))
+	return codes, nil
+}
+
+// Reset menghapus 2FA user yang kehilangan perangkat dan recovery code, hanya untuk admin.
+// semua token user dicabut, jika role nya mewajibkan 2FA user diminta enrolment ulang ketika login
+func (m *mfaService) Reset(ctx context.Context, username string) rest_err.APIError {
+	if _, err := m.userDao.Get(ctx, username); err != nil {
+		return err
+	}
+	if err := m.dao.Delete(ctx, username); err != nil {
+		return err
+	}
+	if err := m.revocation.RevokeAllForUser(ctx, username); err != nil {
+		return err
+	}
+
+	m.log.Info(ctx, "2FA user direset", mlog.String("username", username))
+	return nil
+}
+
+// newRecoveryCodes membuat recovery code berformat xxxxx-xxxxx beserta hash nya
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := hex.EncodeToString(b)
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hanya hash recovery code yang disimpan, tanda hubung dan huruf besar diabaikan
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
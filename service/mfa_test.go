@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/motp"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeMFADao menyimpan satu user_mfa di memory, UseStep mengikuti query dao (last_used_step < step).
+// method yang tidak digunakan Verify menggagalkan test jika dipanggil
+type fakeMFADao struct {
+	t   *testing.T
+	mfa *dto.UserMFA
+}
+
+var _ dao.MFADaoAssumer = (*fakeMFADao)(nil)
+
+// unexpectedCall menggagalkan test ketika fake dao dipanggil pada method yang tidak disiapkan
+func unexpectedCall(t *testing.T, method string) rest_err.APIError {
+	t.Helper()
+	t.Errorf("%s tidak diharapkan dipanggil", method)
+	return rest_err.NewInternalServerError("test.unexpected_call", errors.New(method))
+}
+
+func (f *fakeMFADao) Get(_ context.Context, _ string) (*dto.UserMFA, rest_err.APIError) {
+	if f.mfa == nil {
+		return nil, nil
+	}
+	mfa := *f.mfa
+	return &mfa, nil
+}
+
+func (f *fakeMFADao) UseStep(_ context.Context, _ string, step int64) (bool, rest_err.APIError) {
+	if f.mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	f.mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (f *fakeMFADao) StartEnrollment(_ context.Context, _ string, _ string, _ int64) rest_err.APIError {
+	return unexpectedCall(f.t, "StartEnrollment")
+}
+
+func (f *fakeMFADao) Enable(_ context.Context, _ string, _ int64, _ int64, _ []string) (bool, rest_err.APIError) {
+	return false, unexpectedCall(f.t, "Enable")
+}
+
+func (f *fakeMFADao) ReplaceRecoveryCodes(_ context.Context, _ string, _ []string, _ int64) rest_err.APIError {
+	return unexpectedCall(f.t, "ReplaceRecoveryCodes")
+}
+
+func (f *fakeMFADao) ConsumeRecoveryCode(_ context.Context, _ string, _ string, _ int64) (bool, rest_err.APIError) {
+	return false, unexpectedCall(f.t, "ConsumeRecoveryCode")
+}
+
+func (f *fakeMFADao) Delete(_ context.Context, _ string) rest_err.APIError {
+	return unexpectedCall(f.t, "Delete")
+}
+
+func (f *fakeMFADao) FindUnsealed(_ context.Context) ([]dto.UserMFA, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "FindUnsealed")
+}
+
+func (f *fakeMFADao) ReplaceSecret(_ context.Context, _ string, _ string, _ string) rest_err.APIError {
+	return unexpectedCall(f.t, "ReplaceSecret")
+}
+
+// enabledMFA menyiapkan service dengan 2FA aktif untuk secret, secret disimpan tersegel seperti di database
+func enabledMFA(t *testing.T, secret string) (*fakeMFADao, MFAServiceAssumer) {
+	t.Helper()
+	t.Setenv("SECRET_KEY", "rahasia-untuk-test")
+	if err := mjwt.Init(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := mjwt.SealSecret(mjwt.PurposeTOTPSecret, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enabledAt := time.Now().Add(-time.Hour).Unix()
+	fake := &fakeMFADao{t: t, mfa: &dto.UserMFA{Username: "BUDI", Secret: sealed, EnabledAt: &enabledAt}}
+	return fake, NewMFAService(fake, nil, nil, nil, mlog.New(io.Discard, &mlog.LevelVar{}))
+}
+
+func TestMFAVerifyRejectsReusedCode(t *testing.T) {
+	secret, err := motp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, service := enabledMFA(t, secret)
+
+	code, err := motp.Code(secret, motp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiErr := service.Verify(context.Background(), "budi", code); apiErr != nil {
+		t.Fatalf("kode pertama seharusnya diterima: %v", apiErr)
+	}
+	if apiErr := service.Verify(context.Background(), "budi", code); apiErr == nil {
+		t.Fatal("kode yang sama seharusnya ditolak")
+	}
+}
+
+func TestMFAVerifyRejectsOlderStep(t *testing.T) {
+	secret, err := motp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, service := enabledMFA(t, secret)
+
+	current := motp.Step(time.Now())
+	fake.mfa.LastUsedStep = current
+	// kode step sebelumnya masih dalam toleransi skew namun lebih lama dari step terakhir yang dipakai
+	previous, err := motp.Code(secret, current-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiErr := service.Verify(context.Background(), "budi", previous); apiErr == nil {
+		t.Fatal("kode dengan step lebih lama seharusnya ditolak")
+	}
+
+	next, err := motp.Code(secret, current+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiErr := service.Verify(context.Background(), "budi", next); apiErr != nil {
+		t.Fatalf("kode step berikutnya seharusnya diterima: %v", apiErr)
+	}
+}
+
+func TestMFAVerifyRejectsWrongCode(t *testing.T) {
+	secret, err := motp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, service := enabledMFA(t, secret)
+
+	other, err := motp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := motp.Code(other, motp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiErr := service.Verify(context.Background(), "budi", code); apiErr == nil {
+		t.Fatal("kode dari secret lain seharusnya ditolak")
+	}
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/muchlist/sagasql/config"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mcrypt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/moidc"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OIDCStateTTL batas waktu antara redirect ke provider dan callback, juga umur cookie state
+const OIDCStateTTL = 10 * time.Minute
+
+// OIDCPolicy kebijakan menghubungkan identitas provider dengan user.
+// AutoProvision membuat user baru jika identitas belum terhubung,
+// LinkByEmail menghubungkan identitas ke user yang sudah ada dengan email yang sama (hanya jika provider menyatakan email terverifikasi),
+// RoleMapping memetakan nilai claim RolesClaim (misal group) ke role, DefaultRoles digunakan jika tidak ada yang cocok,
+// SyncRoles mengganti role user dengan hasil pemetaan pada setiap login
+type OIDCPolicy struct {
+	AutoProvision bool
+	LinkByEmail   bool
+	RolesClaim    string
+	RoleMapping   map[string]string
+	DefaultRoles  []string
+	SyncRoles     bool
+}
+
+// DefaultOIDCPolicy tidak menghubungkan identitas ke user yang sudah ada berdasarkan email,
+// aktifkan LinkByEmail hanya untuk provider yang verifikasi email nya dapat dipercaya
+var DefaultOIDCPolicy = OIDCPolicy{
+	AutoProvision: true,
+	LinkByEmail:   false,
+	RolesClaim:    "groups",
+	RoleMapping:   map[string]string{},
+	DefaultRoles:  []string{config.RoleNormal},
+	SyncRoles:     false,
+}
+
+var usernameUnsafeChars = regexp.MustCompile(`[^A-Z0-9_.-]+`)
+
+func NewOIDCService(dao dao.OIDCDaoAssumer, userDao dao.UserDaoAssumer, userService UserServiceAssumer, rbac RBACServiceAssumer, provider moidc.ProviderAssumer, crypto mcrypt.PasswordHasherAssumer, policy OIDCPolicy, log mlog.LoggerAssumer) OIDCServiceAssumer {
+	return &oidcService{
+		dao:         dao,
+		userDao:     userDao,
+		userService: userService,
+		rbac:        rbac,
+		provider:    provider,
+		crypto:      crypto,
+		policy:      policy,
+		log:         log,
+	}
+}
+
+type oidcService struct {
+	dao         dao.OIDCDaoAssumer
+	userDao     dao.UserDaoAssumer
+	userService UserServiceAssumer
+	rbac        RBACServiceAssumer
+	provider    moidc.ProviderAssumer
+	crypto      mcrypt.PasswordHasherAssumer
+	policy      OIDCPolicy
+	log         mlog.LoggerAssumer
+}
+
+type OIDCServiceAssumer interface {
+	Begin(ctx context.Context) (*dto.OIDCLoginResponse, rest_err.APIError)
+	Callback(ctx context.Context, code string, state string, boundState string) (*dto.UserLoginResponse, rest_err.APIError)
+}
+
+// Begin membuat state, nonce dan code verifier PKCE lalu mengembalikan alamat login provider
+func (o *oidcService) Begin(ctx context.Context) (*dto.OIDCLoginResponse, rest_err.APIError) {
+	if !o.provider.Enabled() {
+		return nil, rest_err.NewNotFoundError("oidc.disabled")
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := moidc.RandomString(32)
+		if err != nil {
+			return nil, rest_err.NewInternalServerError("oidc.state_failed", err)
+		}
+		values[i] = value
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	now := time.Now()
+	if err := o.dao.InsertState(ctx, dto.OIDCLoginState{
+		StateHash:    hashOIDCState(state),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		CreatedAt:    now.Unix(),
+		ExpiresAt:    now.Add(OIDCStateTTL).Unix(),
+	}); err != nil {
+		return nil, err
+	}
+
+	authURL, err := o.provider.AuthCodeURL(ctx, state, nonce, moidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		o.log.Error(ctx, "gagal membaca konfigurasi provider oidc", mlog.Err(err))
+		return nil, rest_err.NewAPIError("oidc.provider_unreachable", http.StatusBadGateway, "oidc_error", []interface{}{err.Error()})
+	}
+	return &dto.OIDCLoginResponse{AuthorizationURL: authURL, State: state}, nil
+}
+
+// Callback menukar authorization code, memverifikasi id token, menghubungkan atau membuat user
+// lalu menerbitkan access dan refresh token seperti login biasa.
+// boundState state dari cookie browser, harus sama dengan state pada query agar
+// penyerang tidak dapat membuat browser korban login dengan akun milik penyerang (login CSRF)
+func (o *oidcService) Callback(ctx context.Context, code string, state string, boundState string) (*dto.UserLoginResponse, rest_err.APIError) {
+	if !o.provider.Enabled() {
+		return nil, rest_err.NewNotFoundError("oidc.disabled")
+	}
+	if boundState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(boundState)) != 1 {
+		o.log.Warn(ctx, "callback oidc dengan state yang tidak terikat ke browser")
+		return nil, rest_err.NewBadRequestError("oidc.state_invalid")
+	}
+
+	loginState, err := o.dao.ConsumeState(ctx, hashOIDCState(state), time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if loginState == nil {
+		o.log.Warn(ctx, "callback oidc dengan state tidak valid")
+		return nil, rest_err.NewBadRequestError("oidc.state_invalid")
+	}
+
+	token, exchangeErr := o.provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if exchangeErr != nil {
+		o.log.Warn(ctx, "penukaran authorization code gagal", mlog.Err(exchangeErr))
+		return nil, rest_err.NewUnauthorizedError("oidc.failed")
+	}
+	idToken, verifyErr := o.provider.VerifyIDToken(ctx, token.IDToken, loginState.Nonce)
+	if verifyErr != nil {
+		o.log.Warn(ctx, "id token oidc ditolak", mlog.Err(verifyErr))
+		return nil, rest_err.NewUnauthorizedError("oidc.failed")
+	}
+
+	username, err := o.resolveUser(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if o.policy.SyncRoles {
+		if err := o.syncRoles(ctx, username, idToken); err != nil {
+			return nil, err
+		}
+	}
+
+	return o.userService.LoginExternal(ctx, username)
+}
+
+// resolveUser mencari user yang terhubung dengan identitas, menghubungkan berdasarkan email
+// atau membuat user baru sesuai policy
+func (o *oidcService) resolveUser(ctx context.Context, idToken *moidc.IDToken) (string, rest_err.APIError) {
+	issuer := o.provider.Issuer()
+	now := time.Now().Unix()
+
+	identity, err := o.dao.GetIdentity(ctx, issuer, idToken.Subject)
+	if err != nil {
+		return "", err
+	}
+	if identity != nil {
+		if err := o.dao.TouchIdentity(ctx, issuer, idToken.Subject, idToken.Email, now); err != nil {
+			return "", err
+		}
+		return identity.Username, nil
+	}
+
+	var existing *dto.User
+	if idToken.Email != "" {
+		existing, err = o.userDao.GetByEmail(ctx, idToken.Email)
+		if err != nil {
+			return "", err
+		}
+	}
+	if existing != nil {
+		if !o.policy.LinkByEmail || !idToken.EmailVerified {
+			o.log.Warn(ctx, "identitas oidc tidak dihubungkan, email sudah digunakan", mlog.String("username", string(existing.Username)), mlog.String("subject", idToken.Subject))
+			return "", rest_err.NewForbiddenError("oidc.email_taken")
+		}
+		if err := o.link(ctx, issuer, idToken, string(existing.Username), now); err != nil {
+			return "", err
+		}
+		o.log.Info(ctx, "identitas oidc dihubungkan berdasarkan email", mlog.String("username", string(existing.Username)), mlog.String("subject", idToken.Subject))
+		return string(existing.Username), nil
+	}
+
+	if !o.policy.AutoProvision {
+		return "", rest_err.NewForbiddenError("oidc.account_not_registered")
+	}
+	return o.provision(ctx, issuer, idToken, now)
+}
+
+// provision membuat user baru dengan password acak yang tidak diketahui siapapun,
+// user tersebut hanya dapat login melalui provider sampai password direset
+func (o *oidcService) provision(ctx context.Context, issuer string, idToken *moidc.IDToken, now int64) (string, rest_err.APIError) {
+	if idToken.Email == "" {
+		return "", rest_err.NewBadRequestError("oidc.email_missing")
+	}
+
+	roles := o.mapRoles(idToken)
+	if err := o.rbac.ValidateRoles(ctx, roles); err != nil {
+		return "", err
+	}
+
+	username, err := o.availableUsername(ctx, idToken)
+	if err != nil {
+		return "", err
+	}
+
+	randomPassword, genErr := moidc.RandomString(32)
+	if genErr != nil {
+		return "", rest_err.NewInternalServerError("oidc.password_failed", genErr)
+	}
+	hashPassword, err := o.crypto.GenerateHash(randomPassword)
+	if err != nil {
+		return "", err
+	}
+
+	name := idToken.Name
+	if name == "" {
+		name = username
+	}
+	user := dto.User{
+		Username:  dto.UppercaseString(username),
+		Email:     idToken.Email,
+		Name:      name,
+		Password:  hashPassword,
+		Roles:     roles,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if idToken.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+	if _, err := o.userDao.Insert(ctx, user); err != nil {
+		return "", err
+	}
+	if err := o.link(ctx, issuer, idToken, username, now); err != nil {
+		return "", err
+	}
+
+	o.log.Info(ctx, "user dibuat dari identitas oidc", mlog.String("username", username), mlog.Any("roles", roles), mlog.String("subject", idToken.Subject))
+	return username, nil
+}
+
+func (o *oidcService) link(ctx context.Context, issuer string, idToken *moidc.IDToken, username string, now int64) rest_err.APIError {
+	return o.dao.LinkIdentity(ctx, dto.UserIdentity{
+		Issuer:    issuer,
+		Subject:   idToken.Subject,
+		Username:  username,
+		Email:     idToken.Email,
+		CreatedAt: now,
+	})
+}
+
+// syncRoles mengganti role user jika hasil pemetaan claim berbeda, token lama dicabut oleh SetUserRoles
+func (o *oidcService) syncRoles(ctx context.Context, username string, idToken *moidc.IDToken) rest_err.APIError {
+	user, err := o.userDao.Get(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	roles := o.mapRoles(idToken)
+	current := append([]string(nil), user.Roles...)
+	sort.Strings(current)
+	if strings.Join(current, ",") == strings.Join(roles, ",") {
+		return nil
+	}
+	return o.rbac.SetUserRoles(ctx, username, dto.UserRolesRequest{Roles: roles})
+}
+
+// mapRoles memetakan nilai claim (array atau string dipisah spasi/koma) ke role, hasilnya terurut dan unik
+func (o *oidcService) mapRoles(idToken *moidc.IDToken) []string {
+	var values []string
+	switch claim := idToken.Claims[o.policy.RolesClaim].(type) {
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case string:
+		values = strings.FieldsFunc(claim, func(r rune) bool { return r == ' ' || r == ',' })
+	}
+
+	set := map[string]bool{}
+	for _, value := range values {
+		if role, ok := o.policy.RoleMapping[value]; ok {
+			set[normalizeRole(role)] = true
+		}
+	}
+	if len(set) == 0 {
+		for _, role := range o.policy.DefaultRoles {
+			set[normalizeRole(role)] = true
+		}
+	}
+
+	roles := make([]string, 0, len(set))
+	for role := range set {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// availableUsername membuat username dari preferred_username atau bagian lokal email,
+// ditambah akhiran angka jika sudah digunakan
+func (o *oidcService) availableUsername(ctx context.Context, idToken *moidc.IDToken) (string, rest_err.APIError) {
+	base := idToken.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(idToken.Email, "@", 2)[0]
+	}
+	base = usernameUnsafeChars.ReplaceAllString(strings.ToUpper(base), "_")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if base == "" {
+		base = "USER"
+	}
+
+	for i := 1; i <= 20; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s_%d", base, i)
+		}
+		taken, err := o.dao.UsernameTaken(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	return "", rest_err.NewBadRequestError("user.username_taken", base)
+}
+
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/muchlist/sagasql/config"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmail"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// orgMemberCacheLimit jumlah maksimal keanggotaan yang disimpan di cache sebelum entri kadaluarsa dibuang
+	orgMemberCacheLimit = 10000
+	// orgInvitationTTL umur undangan organisasi
+	orgInvitationTTL = 7 * 24 * time.Hour
+	// orgInviteURLKey env alamat halaman penerimaan undangan pada frontend (contoh https://app.example.com/invitation),
+	// token ditambahkan sebagai query ?token=. jika kosong email hanya berisi token
+	orgInviteURLKey = "ORG_INVITE_URL"
+)
+
+func NewOrgService(dao dao.OrgDaoAssumer, userDao dao.UserDaoAssumer, rbac RBACServiceAssumer, mailer mmail.MailerAssumer, log mlog.LoggerAssumer) OrgServiceAssumer {
+	return &orgService{
+		dao:     dao,
+		userDao: userDao,
+		rbac:    rbac,
+		mailer:  mailer,
+		log:     log,
+		members: make(map[string]orgMemberCache),
+	}
+}
+
+type orgService struct {
+	dao     dao.OrgDaoAssumer
+	userDao dao.UserDaoAssumer
+	rbac    RBACServiceAssumer
+	mailer  mmail.MailerAssumer
+	log     mlog.LoggerAssumer
+
+	mu      sync.RWMutex
+	members map[string]orgMemberCache
+}
+
+// orgMemberCache role user di dalam organisasi, dibaca oleh middleware pada setiap request
+type orgMemberCache struct {
+	roles    []string
+	member   bool
+	loadedAt time.Time
+}
+
+type OrgServiceAssumer interface {
+	Create(ctx context.Context, request dto.OrgRequest) (*dto.Organization, rest_err.APIError)
+	Find(ctx context.Context) ([]dto.Organization, rest_err.APIError)
+	FindByUser(ctx context.Context, username string) ([]dto.OrgMembership, rest_err.APIError)
+	FindMembers(ctx context.Context, orgID string) ([]dto.OrgMember, rest_err.APIError)
+	SetMember(ctx context.Context, orgID string, username string, request dto.OrgMemberRequest) rest_err.APIError
+	RemoveMember(ctx context.Context, orgID string, username string) rest_err.APIError
+	Invite(ctx context.Context, orgID string, invitedBy string, request dto.OrgInviteRequest) (*dto.OrgInvitation, rest_err.APIError)
+	FindInvitations(ctx context.Context, orgID string) ([]dto.OrgInvitation, rest_err.APIError)
+	CancelInvitation(ctx context.Context, orgID string, email string) rest_err.APIError
+	AcceptInvitation(ctx context.Context, username string, request dto.OrgInviteAcceptRequest) (string, rest_err.APIError)
+	OrgRoles(ctx context.Context, orgID string, username string) ([]string, bool, rest_err.APIError)
+	DefaultOrg(ctx context.Context, username string) (string, rest_err.APIError)
+}
+
+// Create membuat organisasi baru dengan request.Admin sebagai ORG_ADMIN pertama
+func (o *orgService) Create(ctx context.Context, request dto.OrgRequest) (*dto.Organization, rest_err.APIError) {
+	admin, err := o.userDao.Get(ctx, request.Admin)
+	if err != nil {
+		return nil, err
+	}
+
+	org := dto.Organization{
+		OrgID:     strings.ToLower(request.OrgID),
+		Name:      request.Name,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := o.dao.Insert(ctx, org, string(admin.Username), []string{config.RoleOrgAdmin}); err != nil {
+		return nil, err
+	}
+
+	o.invalidate(org.OrgID, string(admin.Username))
+	o.log.Info(ctx, "organisasi dibuat", mlog.String("org_id", org.OrgID), mlog.String("admin", string(admin.Username)))
+	return &org, nil
+}
+
+func (o *orgService) Find(ctx context.Context) ([]dto.Organization, rest_err.APIError) {
+	return o.dao.Find(ctx)
+}
+
+func (o *orgService) FindByUser(ctx context.Context, username string) ([]dto.OrgMembership, rest_err.APIError) {
+	return o.dao.FindByUser(ctx, username)
+}
+
+func (o *orgService) FindMembers(ctx context.Context, orgID string) ([]dto.OrgMember, rest_err.APIError) {
+	return o.dao.FindMembers(ctx, orgID)
+}
+
+// SetMember mengganti seluruh role anggota organisasi, hanya role organisasi yang dapat diberikan.
+// user yang belum menjadi anggota harus diundang melalui Invite agar tidak ditambahkan tanpa persetujuannya
+func (o *orgService) SetMember(ctx context.Context, orgID string, username string, request dto.OrgMemberRequest) rest_err.APIError {
+	roles := normalizeRoles(request.Roles)
+	if err := o.rbac.ValidateOrgRoles(ctx, roles); err != nil {
+		return err
+	}
+
+	if err := o.dao.SetMember(ctx, orgID, username, roles); err != nil {
+		return err
+	}
+
+	o.invalidate(orgID, username)
+	o.log.Info(ctx, "role anggota organisasi diubah", mlog.String("org_id", orgID), mlog.String("username", strings.ToUpper(username)), mlog.Any("roles", roles))
+	return nil
+}
+
+// RemoveMember mengeluarkan user dari organisasi, token yang sudah diterbitkan untuk organisasi tersebut
+// ditolak oleh middleware paling lambat setelah cache keanggotaan kadaluarsa
+func (o *orgService) RemoveMember(ctx context.Context, orgID string, username string) rest_err.APIError {
+	if err := o.dao.RemoveMember(ctx, orgID, username); err != nil {
+		return err
+	}
+
+	o.invalidate(orgID, username)
+	o.log.Info(ctx, "anggota dikeluarkan dari organisasi", mlog.String("org_id", orgID), mlog.String("username", username))
+	return nil
+}
+
+// Invite mengirim undangan ke email, pemilik email menjadi anggota setelah menerima undangan menggunakan akunnya.
+// response tidak membedakan email yang terdaftar maupun tidak
+func (o *orgService) Invite(ctx context.Context, orgID string, invitedBy string, request dto.OrgInviteRequest) (*dto.OrgInvitation, rest_err.APIError) {
+	roles := normalizeRoles(request.Roles)
+	if err := o.rbac.ValidateOrgRoles(ctx, roles); err != nil {
+		return nil, err
+	}
+
+	token, genErr := newResetToken()
+	if genErr != nil {
+		return nil, rest_err.NewInternalServerError("org.invitation_failed", genErr)
+	}
+
+	now := time.Now()
+	invitation := dto.OrgInvitation{
+		TokenHash: hashResetToken(token),
+		OrgID:     orgID,
+		Email:     strings.ToLower(request.Email),
+		Roles:     roles,
+		InvitedBy: strings.ToUpper(invitedBy),
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(orgInvitationTTL).Unix(),
+	}
+	if err := o.dao.Invite(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	sendMailAsync(ctx, o.mailer, o.log, mmail.Message{
+		To:      invitation.Email,
+		Subject: "Undangan organisasi",
+		Body:    invitationMailBody(orgID, token),
+	}, mlog.String("org_id", orgID))
+
+	o.log.Info(ctx, "undangan organisasi dibuat", mlog.String("org_id", orgID), mlog.String("invited_by", invitation.InvitedBy), mlog.Any("roles", roles))
+	return &invitation, nil
+}
+
+// FindInvitations undangan organisasi yang masih menunggu diterima
+func (o *orgService) FindInvitations(ctx context.Context, orgID string) ([]dto.OrgInvitation, rest_err.APIError) {
+	return o.dao.FindInvitations(ctx, orgID, time.Now().Unix())
+}
+
+func (o *orgService) CancelInvitation(ctx context.Context, orgID string, email string) rest_err.APIError {
+	if err := o.dao.CancelInvitation(ctx, orgID, email); err != nil {
+		return err
+	}
+
+	o.log.Info(ctx, "undangan organisasi dibatalkan", mlog.String("org_id", orgID))
+	return nil
+}
+
+// AcceptInvitation menjadikan user anggota organisasi jika email user sama dengan tujuan undangan,
+// mengembalikan org_id organisasi tersebut. undangan hanya dapat diterima satu kali
+func (o *orgService) AcceptInvitation(ctx context.Context, username string, request dto.OrgInviteAcceptRequest) (string, rest_err.APIError) {
+	user, err := o.userDao.Get(ctx, username)
+	if err != nil {
+		return "", err
+	}
+
+	orgID, err := o.dao.AcceptInvitation(ctx, hashResetToken(request.Token), string(user.Username), user.Email, time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+	if orgID == "" {
+		o.log.Warn(ctx, "undangan organisasi tidak valid", mlog.String("username", string(user.Username)))
+		return "", rest_err.NewBadRequestError("org.invitation_invalid")
+	}
+
+	o.invalidate(orgID, string(user.Username))
+	o.log.Info(ctx, "undangan organisasi diterima", mlog.String("org_id", orgID), mlog.String("username", string(user.Username)))
+	return orgID, nil
+}
+
+// OrgRoles role user di dalam organisasi dan status keanggotaannya, disimpan di memory selama permissionCacheTTL
+func (o *orgService) OrgRoles(ctx context.Context, orgID string, username string) ([]string, bool, rest_err.APIError) {
+	key := orgMemberKey(orgID, username)
+
+	o.mu.RLock()
+	cached, ok := o.members[key]
+	o.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < permissionCacheTTL {
+		return cached.roles, cached.member, nil
+	}
+
+	roles, member, err := o.dao.MemberRoles(ctx, orgID, username)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	o.mu.Lock()
+	if len(o.members) >= orgMemberCacheLimit {
+		for k, v := range o.members {
+			if now.Sub(v.loadedAt) >= permissionCacheTTL {
+				delete(o.members, k)
+			}
+		}
+	}
+	o.members[key] = orgMemberCache{roles: roles, member: member, loadedAt: now}
+	o.mu.Unlock()
+	return roles, member, nil
+}
+
+// DefaultOrg organisasi yang aktif ketika login
+func (o *orgService) DefaultOrg(ctx context.Context, username string) (string, rest_err.APIError) {
+	return o.dao.DefaultOrg(ctx, username)
+}
+
+func (o *orgService) invalidate(orgID string, username string) {
+	o.mu.Lock()
+	delete(o.members, orgMemberKey(orgID, username))
+	o.mu.Unlock()
+}
+
+func orgMemberKey(orgID string, username string) string {
+	return fmt.Sprintf("%s|%s", orgID, strings.ToUpper(username))
+}
+
+func invitationMailBody(orgID string, token string) string {
+	instruction := fmt.Sprintf("Token undangan : %s", token)
+	if inviteURL := os.Getenv(orgInviteURLKey); inviteURL != "" {
+		instruction = fmt.Sprintf("Buka tautan berikut untuk menerima undangan :\n%s?token=%s", inviteURL, url.QueryEscape(token))
+	}
+	return fmt.Sprintf(`Halo,
+
+Anda diundang menjadi anggota organisasi %s.
+%s
+
+Undangan hanya dapat diterima oleh akun dengan alamat email ini dan berlaku selama %d hari.
+Abaikan email ini jika anda tidak mengenal organisasi tersebut.
+`, orgID, instruction, int(orgInvitationTTL.Hours()/24))
+}
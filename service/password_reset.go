@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mcrypt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmail"
+	"github.com/muchlist/sagasql/utils/mtrace"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// passwordResetTTL umur token reset password
+	passwordResetTTL = 30 * time.Minute
+	// passwordResetURLKey env alamat halaman reset password pada frontend (contoh https://app.example.com/reset-password),
+	// token ditambahkan sebagai query ?token=. jika kosong email hanya berisi token
+	passwordResetURLKey = "PASSWORD_RESET_URL"
+)
+
+func NewPasswordResetService(dao dao.PasswordResetDaoAssumer, userDao dao.UserDaoAssumer, guard LoginGuardServiceAssumer, revocation TokenRevocationServiceAssumer, crypto mcrypt.PasswordHasherAssumer, mailer mmail.MailerAssumer, log mlog.LoggerAssumer) PasswordResetServiceAssumer {
+	return &passwordResetService{
+		dao:        dao,
+		userDao:    userDao,
+		guard:      guard,
+		revocation: revocation,
+		crypto:     crypto,
+		mailer:     mailer,
+		log:        log,
+	}
+}
+
+type passwordResetService struct {
+	dao        dao.PasswordResetDaoAssumer
+	userDao    dao.UserDaoAssumer
+	guard      LoginGuardServiceAssumer
+	revocation TokenRevocationServiceAssumer
+	crypto     mcrypt.PasswordHasherAssumer
+	mailer     mmail.MailerAssumer
+	log        mlog.LoggerAssumer
+}
+
+type PasswordResetServiceAssumer interface {
+	Forgot(ctx context.Context, request dto.PasswordForgotRequest, clientIP string) rest_err.APIError
+	Reset(ctx context.Context, request dto.PasswordResetRequest) rest_err.APIError
+}
+
+// Forgot mengirim token reset password ke email user. hasilnya selalu sama baik email terdaftar maupun tidak,
+// email dikirim di background agar waktu response juga tidak membedakan keduanya.
+// setiap permintaan dihitung per email dan ip (lihat NewPasswordResetGuardService) sehingga inbox user tidak dapat dibanjiri
+func (p *passwordResetService) Forgot(ctx context.Context, request dto.PasswordForgotRequest, clientIP string) rest_err.APIError {
+	if err := p.guard.Check(ctx, request.Email, clientIP); err != nil {
+		p.log.Warn(ctx, "permintaan reset password ditolak, masih dalam masa penundaan", mlog.String("ip", clientIP))
+		return err
+	}
+	if err := p.guard.RegisterFailure(ctx, request.Email, clientIP); err != nil {
+		return err
+	}
+
+	// token selalu dibuat dan query yang sama selalu dijalankan agar waktu response tidak membedakan email terdaftar
+	token, genErr := newResetToken()
+	if genErr != nil {
+		return rest_err.NewInternalServerError("token.reset_failed", genErr)
+	}
+
+	// token sebelumnya tetap berlaku agar permintaan orang lain tidak membatalkan tautan milik user,
+	// semua token user baru dibatalkan setelah reset berhasil
+	now := time.Now()
+	user, err := p.dao.InsertForEmail(ctx, request.Email, dto.PasswordResetToken{
+		TokenHash: hashResetToken(token),
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(passwordResetTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		p.log.Info(ctx, "permintaan reset password untuk email yang tidak terdaftar")
+		return nil
+	}
+
+	msg := mmail.Message{
+		To:      user.Email,
+		Subject: "Reset password",
+		Body:    resetMailBody(user.Name, token),
+	}
+	sendMailAsync(ctx, p.mailer, p.log, msg, mlog.String("username", string(user.Username)))
+
+	p.log.Info(ctx, "token reset password dibuat", mlog.String("username", string(user.Username)))
+	return nil
+}
+
+// Reset mengganti password menggunakan token dari email, token hanya dapat digunakan sekali.
+// semua sesi user dicabut karena password lama mungkin sudah diketahui orang lain
+func (p *passwordResetService) Reset(ctx context.Context, request dto.PasswordResetRequest) rest_err.APIError {
+	now := time.Now()
+	invalidToken := rest_err.NewBadRequestError("token.reset_invalid")
+
+	// policy password diperiksa sebelum token dipakai agar user dapat mencoba password lain dengan token yang sama
+	username, err := p.dao.Peek(ctx, hashResetToken(request.Token), now.Unix())
+	if err != nil {
+		return err
+	}
+	if username == "" {
+		p.log.Warn(ctx, "reset password menggunakan token tidak valid")
+		return invalidToken
+	}
+	user, err := p.userDao.Get(ctx, username)
+	if err != nil {
+		return err
+	}
+	if err := checkPasswordPolicy("new_password", request.NewPassword, user); err != nil {
+		return err
+	}
+
+	_, span := mtrace.Start(ctx, "bcrypt.Hash")
+	hashPassword, err := p.crypto.GenerateHash(request.NewPassword)
+	span.End()
+	if err != nil {
+		return err
+	}
+
+	username, err = p.dao.Reset(ctx, hashResetToken(request.Token), hashPassword, now.Unix())
+	if err != nil {
+		return err
+	}
+	if username == "" {
+		p.log.Warn(ctx, "reset password menggunakan token tidak valid")
+		return invalidToken
+	}
+	if err := p.revocation.RevokeAllForUser(ctx, username); err != nil {
+		return err
+	}
+
+	p.log.Info(ctx, "password direset melalui email", mlog.String("username", username))
+	return nil
+}
+
+// newResetToken membuat token acak 32 byte dalam bentuk hex
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashResetToken hanya hash token yang disimpan sehingga isi database tidak dapat dipakai untuk reset
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func resetMailBody(name string, token string) string {
+	instruction := fmt.Sprintf("Token reset password : %s", token)
+	if resetURL := os.Getenv(passwordResetURLKey); resetURL != "" {
+		instruction = fmt.Sprintf("Buka tautan berikut untuk mengganti password :\n%s?token=%s", resetURL, url.QueryEscape(token))
+	}
+	return fmt.Sprintf(`Halo %s,
+
+Kami menerima permintaan reset password untuk akun anda.
+%s
+
+Token berlaku selama %d menit dan hanya dapat digunakan satu kali.
+Abaikan email ini jika anda tidak meminta reset password.
+`, name, instruction, int(passwordResetTTL.Minutes()))
+}
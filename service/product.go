@@ -1,33 +1,38 @@
 package service
 
 import (
+	"context"
 	"github.com/muchlist/sagasql/dao"
 	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
 	"github.com/muchlist/sagasql/utils/rest_err"
 )
 
-func NewProductService(dao dao.ProductDaoAssumer) ProductServiceAssumer {
+func NewProductService(dao dao.ProductDaoAssumer, log mlog.LoggerAssumer) ProductServiceAssumer {
 	return &productService{
 		dao: dao,
+		log: log,
 	}
 }
 
 type productService struct {
 	dao dao.ProductDaoAssumer
+	log mlog.LoggerAssumer
 }
 
 type ProductServiceAssumer interface {
-	InsertProduct(product dto.Product) (*int64, rest_err.APIError)
-	EditProduct(request dto.Product) (*dto.Product, rest_err.APIError)
-	PutImage(id int64, imagePath string) (*dto.Product, rest_err.APIError)
-	DeleteProduct(productID int64) rest_err.APIError
-	GetProduct(productID int64) (*dto.Product, rest_err.APIError)
-	FindProducts(search string) ([]dto.Product, rest_err.APIError)
+	InsertProduct(ctx context.Context, product dto.Product) (*int64, rest_err.APIError)
+	EditProduct(ctx context.Context, request dto.Product) (*dto.Product, rest_err.APIError)
+	PutImage(ctx context.Context, id int64, imagePath string) (*dto.Product, rest_err.APIError)
+	DeleteProduct(ctx context.Context, productID int64) rest_err.APIError
+	GetProduct(ctx context.Context, productID int64) (*dto.Product, rest_err.APIError)
+	FindProducts(ctx context.Context, search string) ([]dto.Product, rest_err.APIError)
 }
 
 // InsertProduct melakukan register product
-func (u *productService) InsertProduct(product dto.Product) (*int64, rest_err.APIError) {
-	insertedProductID, err := u.dao.Insert(product)
+func (u *productService) InsertProduct(ctx context.Context, product dto.Product) (*int64, rest_err.APIError) {
+	insertedProductID, err := u.dao.Insert(ctx, product)
 	if err != nil {
 		return nil, err
 	}
@@ -35,8 +40,8 @@ func (u *productService) InsertProduct(product dto.Product) (*int64, rest_err.AP
 }
 
 // EditProduct
-func (u *productService) EditProduct(request dto.Product) (*dto.Product, rest_err.APIError) {
-	result, err := u.dao.Edit(request)
+func (u *productService) EditProduct(ctx context.Context, request dto.Product) (*dto.Product, rest_err.APIError) {
+	result, err := u.dao.Edit(ctx, request)
 	if err != nil {
 		return nil, err
 	}
@@ -44,26 +49,29 @@ func (u *productService) EditProduct(request dto.Product) (*dto.Product, rest_er
 }
 
 // DeleteProduct
-func (u *productService) DeleteProduct(productID int64) rest_err.APIError {
-	err := u.dao.Delete(productID)
+func (u *productService) DeleteProduct(ctx context.Context, productID int64) rest_err.APIError {
+	err := u.dao.Delete(ctx, productID)
 	if err != nil {
 		return err
 	}
+	u.log.Info(ctx, "product dihapus", mlog.Int64("product_id", productID))
 	return nil
 }
 
 // PutImage memasukkan lokasi file (path) ke dalam database
-func (u *productService) PutImage(id int64, imagePath string) (*dto.Product, rest_err.APIError) {
-	product, err := u.dao.UploadImage(id, imagePath)
+func (u *productService) PutImage(ctx context.Context, id int64, imagePath string) (*dto.Product, rest_err.APIError) {
+	product, err := u.dao.UploadImage(ctx, id, imagePath)
 	if err != nil {
 		return nil, err
 	}
+	mmetric.IncImageUpload()
+	u.log.Info(ctx, "gambar product diperbarui", mlog.Int64("product_id", id), mlog.String("image", imagePath))
 	return product, nil
 }
 
 // GetProduct mendapatkan product dari database
-func (u *productService) GetProduct(productID int64) (*dto.Product, rest_err.APIError) {
-	product, err := u.dao.Get(productID)
+func (u *productService) GetProduct(ctx context.Context, productID int64) (*dto.Product, rest_err.APIError) {
+	product, err := u.dao.Get(ctx, productID)
 	if err != nil {
 		return nil, err
 	}
@@ -71,14 +79,14 @@ func (u *productService) GetProduct(productID int64) (*dto.Product, rest_err.API
 }
 
 // FindProducts
-func (u *productService) FindProducts(search string) ([]dto.Product, rest_err.APIError) {
+func (u *productService) FindProducts(ctx context.Context, search string) ([]dto.Product, rest_err.APIError) {
 
 	var productList []dto.Product
 	var err rest_err.APIError
 	if len(search) > 0 {
-		productList, err = u.dao.Search(dto.UppercaseString(search))
+		productList, err = u.dao.Search(ctx, dto.UppercaseString(search))
 	} else {
-		productList, err = u.dao.Find()
+		productList, err = u.dao.Find(ctx)
 	}
 	if err != nil {
 		return nil, err
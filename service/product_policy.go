@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/config"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"strconv"
+	"strings"
+)
+
+// NewProductOwnerPolicy policy untuk mengubah product, digunakan bersama middle.Authorize
+func NewProductOwnerPolicy(dao dao.ProductDaoAssumer, rbac RBACServiceAssumer, log mlog.LoggerAssumer) *productOwnerPolicy {
+	return &productOwnerPolicy{
+		dao:  dao,
+		rbac: rbac,
+		log:  log,
+	}
+}
+
+type productOwnerPolicy struct {
+	dao  dao.ProductDaoAssumer
+	rbac RBACServiceAssumer
+	log  mlog.LoggerAssumer
+}
+
+// Authorize mengijinkan pemilik permission product:manage mengubah semua product,
+// selain itu hanya product yang dibuat oleh user itu sendiri (created_by)
+func (p *productOwnerPolicy) Authorize(ctx context.Context, claims *mjwt.CustomClaim, resourceID string) rest_err.APIError {
+	canManage, err := p.rbac.HasPermissions(ctx, claims.EffectiveRoles(), []string{config.PermProductManage})
+	if err != nil {
+		return err
+	}
+	if canManage {
+		return nil
+	}
+
+	productID, parseErr := strconv.ParseInt(resourceID, 10, 64)
+	if parseErr != nil {
+		return rest_err.From(rest_err.ErrInvalidID)
+	}
+	product, err := p.dao.Get(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(product.CreatedBy, claims.Identity) {
+		p.log.Warn(ctx, "percobaan mengubah product milik user lain",
+			mlog.String("username", claims.Identity),
+			mlog.Int64("product_id", productID),
+			mlog.String("owner", product.CreatedBy),
+		)
+		return rest_err.NewForbiddenError("product.owner_only")
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/config"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"strings"
+	"sync"
+	"time"
+)
+
+// permissionCacheTTL lama peta role -> permission disimpan di memory. perubahan dari instance lain
+// paling lambat berlaku setelah ttl ini, perubahan di instance sendiri berlaku langsung
+const permissionCacheTTL = 30 * time.Second
+
+func NewRBACService(dao dao.RBACDaoAssumer, userDao dao.UserDaoAssumer, revocation TokenRevocationServiceAssumer, log mlog.LoggerAssumer) RBACServiceAssumer {
+	return &rbacService{
+		dao:        dao,
+		userDao:    userDao,
+		revocation: revocation,
+		log:        log,
+	}
+}
+
+type rbacService struct {
+	dao        dao.RBACDaoAssumer
+	userDao    dao.UserDaoAssumer
+	revocation TokenRevocationServiceAssumer
+	log        mlog.LoggerAssumer
+
+	mu    sync.RWMutex
+	cache *roleCache
+}
+
+// roleCache peta role -> permission, role yang mewajibkan 2FA dan role organisasi hasil cache
+type roleCache struct {
+	permissions map[string]map[string]bool
+	mfaRequired map[string]bool
+	orgScoped   map[string]bool
+	loadedAt    time.Time
+}
+
+type RBACServiceAssumer interface {
+	FindRoles(ctx context.Context) ([]dto.Role, rest_err.APIError)
+	GetRole(ctx context.Context, name string) (*dto.Role, rest_err.APIError)
+	CreateRole(ctx context.Context, request dto.RoleRequest) (*dto.Role, rest_err.APIError)
+	EditRole(ctx context.Context, request dto.RoleRequest) (*dto.Role, rest_err.APIError)
+	DeleteRole(ctx context.Context, name string) rest_err.APIError
+	FindPermissions(ctx context.Context) ([]dto.Permission, rest_err.APIError)
+	SetUserRoles(ctx context.Context, username string, request dto.UserRolesRequest) rest_err.APIError
+	ValidateRoles(ctx context.Context, roles []string) rest_err.APIError
+	ValidateOrgRoles(ctx context.Context, roles []string) rest_err.APIError
+	HasPermissions(ctx context.Context, roles []string, permissions []string) (bool, rest_err.APIError)
+	SetRoleMFARequired(ctx context.Context, name string, request dto.RoleMFARequest) rest_err.APIError
+	MFARequired(ctx context.Context, roles []string) (bool, rest_err.APIError)
+}
+
+func (r *rbacService) FindRoles(ctx context.Context) ([]dto.Role, rest_err.APIError) {
+	return r.dao.FindRoles(ctx)
+}
+
+func (r *rbacService) GetRole(ctx context.Context, name string) (*dto.Role, rest_err.APIError) {
+	return r.dao.GetRole(ctx, normalizeRole(name))
+}
+
+// CreateRole membuat role baru, nama role disimpan dalam huruf besar
+func (r *rbacService) CreateRole(ctx context.Context, request dto.RoleRequest) (*dto.Role, rest_err.APIError) {
+	if err := r.validatePermissions(ctx, request.Permissions); err != nil {
+		return nil, err
+	}
+
+	role := dto.Role{
+		Name:        normalizeRole(request.Name),
+		Description: request.Description,
+		Permissions: request.Permissions,
+		OrgScoped:   request.OrgScoped,
+		CreatedAt:   time.Now().Unix(),
+	}
+	if err := r.dao.InsertRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	r.invalidate()
+	r.log.Info(ctx, "role dibuat", mlog.String("role", role.Name), mlog.Any("permissions", role.Permissions))
+	return r.dao.GetRole(ctx, role.Name)
+}
+
+// EditRole mengganti deskripsi dan seluruh permission role, role ADMIN tidak dapat diubah
+func (r *rbacService) EditRole(ctx context.Context, request dto.RoleRequest) (*dto.Role, rest_err.APIError) {
+	name := normalizeRole(request.Name)
+	if name == config.RoleAdmin {
+		return nil, rest_err.NewBadRequestError("rbac.admin_immutable")
+	}
+	if err := r.validatePermissions(ctx, request.Permissions); err != nil {
+		return nil, err
+	}
+
+	if err := r.dao.EditRole(ctx, dto.Role{
+		Name:        name,
+		Description: request.Description,
+		Permissions: request.Permissions,
+	}); err != nil {
+		return nil, err
+	}
+
+	r.invalidate()
+	r.log.Info(ctx, "permission role diubah", mlog.String("role", name), mlog.Any("permissions", request.Permissions))
+	return r.dao.GetRole(ctx, name)
+}
+
+// DeleteRole menghapus role beserta pemberiannya ke user, role ADMIN tidak dapat dihapus
+func (r *rbacService) DeleteRole(ctx context.Context, name string) rest_err.APIError {
+	name = normalizeRole(name)
+	if name == config.RoleAdmin {
+		return rest_err.NewBadRequestError("rbac.admin_undeletable")
+	}
+	if err := r.dao.DeleteRole(ctx, name); err != nil {
+		return err
+	}
+
+	r.invalidate()
+	r.log.Info(ctx, "role dihapus", mlog.String("role", name))
+	return nil
+}
+
+func (r *rbacService) FindPermissions(ctx context.Context) ([]dto.Permission, rest_err.APIError) {
+	return r.dao.FindPermissions(ctx)
+}
+
+// SetUserRoles mengganti role user lalu mencabut token nya, karena role tersimpan di dalam token
+// user harus login ulang agar role baru berlaku
+func (r *rbacService) SetUserRoles(ctx context.Context, username string, request dto.UserRolesRequest) rest_err.APIError {
+	if _, err := r.userDao.Get(ctx, username); err != nil {
+		return err
+	}
+
+	roles := normalizeRoles(request.Roles)
+	if err := r.ValidateRoles(ctx, roles); err != nil {
+		return err
+	}
+	if err := r.dao.SetUserRoles(ctx, username, roles); err != nil {
+		return err
+	}
+	if err := r.revocation.RevokeAllForUser(ctx, username); err != nil {
+		return err
+	}
+
+	r.log.Info(ctx, "role user diubah", mlog.String("username", username), mlog.Any("roles", roles))
+	return nil
+}
+
+// ValidateRoles memastikan semua role tersedia di database dan bukan role organisasi
+func (r *rbacService) ValidateRoles(ctx context.Context, roles []string) rest_err.APIError {
+	return r.validateRoles(ctx, roles, false)
+}
+
+// ValidateOrgRoles memastikan semua role tersedia dan merupakan role organisasi (org_scoped)
+func (r *rbacService) ValidateOrgRoles(ctx context.Context, roles []string) rest_err.APIError {
+	return r.validateRoles(ctx, roles, true)
+}
+
+// validateRoles role organisasi tidak dapat diberikan secara global dan sebaliknya,
+// sehingga admin organisasi tidak dapat memberikan role global seperti ADMIN
+func (r *rbacService) validateRoles(ctx context.Context, roles []string, orgScoped bool) rest_err.APIError {
+	cache, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	var unknown, misplaced []string
+	for _, role := range roles {
+		if _, ok := cache.permissions[role]; !ok {
+			unknown = append(unknown, role)
+			continue
+		}
+		if cache.orgScoped[role] != orgScoped {
+			misplaced = append(misplaced, role)
+		}
+	}
+	if len(unknown) != 0 {
+		return rest_err.NewBadRequestError("rbac.role_unknown", unknown)
+	}
+	if len(misplaced) != 0 {
+		if orgScoped {
+			return rest_err.NewBadRequestError("rbac.role_not_org", misplaced)
+		}
+		return rest_err.NewBadRequestError("rbac.role_org_only", misplaced)
+	}
+	return nil
+}
+
+// HasPermissions true jika gabungan permission dari roles memiliki semua permissions
+func (r *rbacService) HasPermissions(ctx context.Context, roles []string, permissions []string) (bool, rest_err.APIError) {
+	cache, err := r.load(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, permission := range permissions {
+		granted := false
+		for _, role := range roles {
+			if cache.permissions[role][permission] {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// SetRoleMFARequired mengatur kewajiban 2FA untuk role, berlaku juga untuk role ADMIN.
+// user dengan role tersebut diminta enrolment pada login berikutnya
+func (r *rbacService) SetRoleMFARequired(ctx context.Context, name string, request dto.RoleMFARequest) rest_err.APIError {
+	name = normalizeRole(name)
+	if err := r.dao.SetRoleMFARequired(ctx, name, request.Required); err != nil {
+		return err
+	}
+
+	r.invalidate()
+	r.log.Info(ctx, "kewajiban 2FA role diubah", mlog.String("role", name), mlog.Any("required", request.Required))
+	return nil
+}
+
+// MFARequired true jika salah satu role mewajibkan 2FA
+func (r *rbacService) MFARequired(ctx context.Context, roles []string) (bool, rest_err.APIError) {
+	cache, err := r.load(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roles {
+		if cache.mfaRequired[role] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// load mengembalikan peta role dari cache, membaca ulang dari database jika kadaluarsa
+func (r *rbacService) load(ctx context.Context) (*roleCache, rest_err.APIError) {
+	r.mu.RLock()
+	if r.cache != nil && time.Since(r.cache.loadedAt) < permissionCacheTTL {
+		cache := r.cache
+		r.mu.RUnlock()
+		return cache, nil
+	}
+	r.mu.RUnlock()
+
+	roles, err := r.dao.FindRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &roleCache{
+		permissions: make(map[string]map[string]bool, len(roles)),
+		mfaRequired: make(map[string]bool),
+		orgScoped:   make(map[string]bool),
+		loadedAt:    time.Now(),
+	}
+	for _, role := range roles {
+		permissions := make(map[string]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			permissions[permission] = true
+		}
+		cache.permissions[role.Name] = permissions
+		if role.MFARequired {
+			cache.mfaRequired[role.Name] = true
+		}
+		if role.OrgScoped {
+			cache.orgScoped[role.Name] = true
+		}
+	}
+
+	r.mu.Lock()
+	r.cache = cache
+	r.mu.Unlock()
+	return cache, nil
+}
+
+func (r *rbacService) invalidate() {
+	r.mu.Lock()
+	r.cache = nil
+	r.mu.Unlock()
+}
+
+func (r *rbacService) validatePermissions(ctx context.Context, permissions []string) rest_err.APIError {
+	available, err := r.dao.FindPermissions(ctx)
+	if err != nil {
+		return err
+	}
+
+	availableSet := make(map[string]bool, len(available))
+	for _, permission := range available {
+		availableSet[permission.Name] = true
+	}
+
+	var unknown []string
+	for _, permission := range permissions {
+		if !availableSet[permission] {
+			unknown = append(unknown, permission)
+		}
+	}
+	if len(unknown) != 0 {
+		return rest_err.NewBadRequestError("rbac.permission_unknown", unknown)
+	}
+	return nil
+}
+
+func normalizeRole(role string) string {
+	return strings.ToUpper(strings.TrimSpace(role))
+}
+
+func normalizeRoles(roles []string) []string {
+	normalized := make([]string, 0, len(roles))
+	for _, role := range roles {
+		normalized = append(normalized, normalizeRole(role))
+	}
+	return normalized
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/db"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"sync"
+	"time"
+)
+
+// revocationCacheTTL lama hasil "tidak dicabut" disimpan di memory. pencabutan yang dilakukan
+// instance lain paling lambat berlaku setelah ttl ini, pencabutan di instance sendiri berlaku langsung.
+// cache baru diubah setelah transaksi request di-commit (db.AfterCommit) agar pencabutan yang dibatalkan tidak tersimpan di memory
+const revocationCacheTTL = 30 * time.Second
+
+// revocationPurgeInterval seberapa sering catatan token yang sudah kadaluarsa dihapus dari database
+const revocationPurgeInterval = time.Hour
+
+func NewTokenRevocationService(dao dao.TokenRevocationDaoAssumer, refreshDao dao.RefreshTokenDaoAssumer, log mlog.LoggerAssumer) TokenRevocationServiceAssumer {
+	return &tokenRevocationService{
+		dao:           dao,
+		refreshDao:    refreshDao,
+		log:           log,
+		revokedJTI:    make(map[string]int64),
+		checkedJTI:    make(map[string]time.Time),
+		revokedBefore: make(map[string]revokedBeforeEntry),
+		families:      make(map[string]familyEntry),
+	}
+}
+
+type revokedBeforeEntry struct {
+	revokedBefore int64
+	checkedAt     time.Time
+}
+
+type familyEntry struct {
+	revoked   bool
+	checkedAt time.Time
+}
+
+type tokenRevocationService struct {
+	dao        dao.TokenRevocationDaoAssumer
+	refreshDao dao.RefreshTokenDaoAssumer
+	log        mlog.LoggerAssumer
+
+	mu sync.RWMutex
+	// revokedJTI jti yang pasti dicabut beserta exp nya, disimpan sampai token kadaluarsa
+	revokedJTI map[string]int64
+	// checkedJTI jti yang terakhir dicek tidak dicabut
+	checkedJTI map[string]time.Time
+	// revokedBefore batas waktu terbit (milidetik, eksklusif) per username
+	revokedBefore map[string]revokedBeforeEntry
+	// families status pencabutan sesi (family refresh token) yang terakhir dicek
+	families map[string]familyEntry
+}
+
+type TokenRevocationServiceAssumer interface {
+	StartPurge(ctx context.Context)
+	Revoke(ctx context.Context, claims *mjwt.CustomClaim) rest_err.APIError
+	Consume(ctx context.Context, claims *mjwt.CustomClaim) (bool, rest_err.APIError)
+	RevokeAllForUser(ctx context.Context, username string) rest_err.APIError
+	RevokeSession(ctx context.Context, familyID string) rest_err.APIError
+	RevokeOtherSessions(ctx context.Context, username string, keepFamilyID string) rest_err.APIError
+	IsRevoked(ctx context.Context, claims *mjwt.CustomClaim) (bool, rest_err.APIError)
+}
+
+// StartPurge menghapus catatan token yang sudah kadaluarsa secara berkala sampai ctx selesai.
+// berjalan di luar transaksi request sehingga kegagalan penghapusan tidak membatalkan pencabutan
+func (t *tokenRevocationService) StartPurge(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(revocationPurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.dao.PurgeExpired(ctx, time.Now().Unix()); err != nil {
+					t.log.Error(ctx, "gagal menghapus token kadaluarsa", mlog.Err(err))
+				}
+			}
+		}
+	}()
+}
+
+// Revoke mencabut satu token berdasarkan jti nya
+func (t *tokenRevocationService) Revoke(ctx context.Context, claims *mjwt.CustomClaim) rest_err.APIError {
+	if claims.TokenID == "" {
+		// token lama tanpa jti hanya dapat dicabut melalui RevokeAllForUser
+		return t.RevokeAllForUser(ctx, claims.Identity)
+	}
+
+	_, err := t.revoke(ctx, claims)
+	return err
+}
+
+// Consume mencabut token sekali pakai (misal token mfa pending) berdasarkan jti nya.
+// mengembalikan false jika token sudah pernah dipakai, termasuk oleh request lain yang berjalan bersamaan
+func (t *tokenRevocationService) Consume(ctx context.Context, claims *mjwt.CustomClaim) (bool, rest_err.APIError) {
+	if claims.TokenID == "" {
+		return false, nil
+	}
+	return t.revoke(ctx, claims)
+}
+
+func (t *tokenRevocationService) revoke(ctx context.Context, claims *mjwt.CustomClaim) (bool, rest_err.APIError) {
+	revoked, err := t.dao.Revoke(ctx, claims.TokenID, claims.Identity, claims.Exp)
+	if err != nil {
+		return false, err
+	}
+
+	db.AfterCommit(ctx, func() {
+		t.mu.Lock()
+		t.revokedJTI[claims.TokenID] = claims.Exp
+		delete(t.checkedJTI, claims.TokenID)
+		t.mu.Unlock()
+	})
+	return revoked, nil
+}
+
+// RevokeAllForUser mencabut semua token username yang sudah diterbitkan sampai saat ini.
+// batas disimpan dalam milidetik dan bersifat eksklusif sehingga token yang diterbitkan pada milidetik yang sama
+// ikut dicabut, sedangkan token hasil login ulang setelahnya tetap berlaku
+func (t *tokenRevocationService) RevokeAllForUser(ctx context.Context, username string) rest_err.APIError {
+	username = normalizeLoginUsername(username)
+	revokedBefore := time.Now().UnixNano()/int64(time.Millisecond) + 1
+	if err := t.dao.RevokeAllBefore(ctx, username, revokedBefore); err != nil {
+		return err
+	}
+
+	db.AfterCommit(ctx, func() {
+		t.mu.Lock()
+		t.revokedBefore[username] = revokedBeforeEntry{revokedBefore: revokedBefore, checkedAt: time.Now()}
+		t.mu.Unlock()
+	})
+
+	t.log.Info(ctx, "semua token user dicabut", mlog.String("username", username))
+	return nil
+}
+
+// RevokeSession mencabut satu sesi (family), access dan refresh token sesi tersebut tidak berlaku lagi
+func (t *tokenRevocationService) RevokeSession(ctx context.Context, familyID string) rest_err.APIError {
+	if err := t.refreshDao.RevokeFamily(ctx, familyID, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	db.AfterCommit(ctx, func() {
+		t.mu.Lock()
+		t.families[familyID] = familyEntry{revoked: true, checkedAt: time.Now()}
+		t.mu.Unlock()
+	})
+	return nil
+}
+
+// RevokeOtherSessions mencabut semua sesi username kecuali keepFamilyID ("" untuk mencabut semua sesi)
+func (t *tokenRevocationService) RevokeOtherSessions(ctx context.Context, username string, keepFamilyID string) rest_err.APIError {
+	familyIDs, err := t.refreshDao.RevokeUserFamilies(ctx, username, keepFamilyID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	db.AfterCommit(ctx, func() {
+		now := time.Now()
+		t.mu.Lock()
+		for _, familyID := range familyIDs {
+			t.families[familyID] = familyEntry{revoked: true, checkedAt: now}
+		}
+		t.mu.Unlock()
+	})
+
+	t.log.Info(ctx, "sesi lain user dicabut", mlog.String("username", username), mlog.Int("sessions", len(familyIDs)))
+	return nil
+}
+
+// IsRevoked mengecek jti dan batas waktu terbit token username, hasil disimpan sementara di memory
+func (t *tokenRevocationService) IsRevoked(ctx context.Context, claims *mjwt.CustomClaim) (bool, rest_err.APIError) {
+	revokedBefore, err := t.getRevokedBefore(ctx, claims.Identity)
+	if err != nil {
+		return false, err
+	}
+	if revokedBefore != 0 && claims.IssuedAtMs < revokedBefore {
+		return true, nil
+	}
+
+	if claims.FamilyID != "" {
+		revoked, err := t.isSessionRevoked(ctx, claims.FamilyID)
+		if err != nil {
+			return false, err
+		}
+		if revoked {
+			return true, nil
+		}
+	}
+
+	if claims.TokenID == "" {
+		return false, nil
+	}
+
+	now := time.Now()
+	t.mu.RLock()
+	_, revoked := t.revokedJTI[claims.TokenID]
+	checkedAt, checked := t.checkedJTI[claims.TokenID]
+	t.mu.RUnlock()
+	if revoked {
+		return true, nil
+	}
+	if checked && now.Sub(checkedAt) < revocationCacheTTL {
+		return false, nil
+	}
+
+	revoked, err = t.dao.IsRevoked(ctx, claims.TokenID)
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	t.evictExpired(now)
+	if revoked {
+		t.revokedJTI[claims.TokenID] = claims.Exp
+	} else {
+		t.checkedJTI[claims.TokenID] = now
+	}
+	t.mu.Unlock()
+	return revoked, nil
+}
+
+func (t *tokenRevocationService) getRevokedBefore(ctx context.Context, username string) (int64, rest_err.APIError) {
+	username = normalizeLoginUsername(username)
+	t.mu.RLock()
+	entry, ok := t.revokedBefore[username]
+	t.mu.RUnlock()
+	if ok && time.Since(entry.checkedAt) < revocationCacheTTL {
+		return entry.revokedBefore, nil
+	}
+
+	revokedBefore, err := t.dao.GetRevokedBefore(ctx, username)
+	if err != nil {
+		return 0, err
+	}
+
+	db.AfterCommit(ctx, func() {
+		t.mu.Lock()
+		t.revokedBefore[username] = revokedBeforeEntry{revokedBefore: revokedBefore, checkedAt: time.Now()}
+		t.mu.Unlock()
+	})
+	return revokedBefore, nil
+}
+
+func (t *tokenRevocationService) isSessionRevoked(ctx context.Context, familyID string) (bool, rest_err.APIError) {
+	t.mu.RLock()
+	entry, ok := t.families[familyID]
+	t.mu.RUnlock()
+	if ok && time.Since(entry.checkedAt) < revocationCacheTTL {
+		return entry.revoked, nil
+	}
+
+	family, err := t.refreshDao.GetFamily(ctx, familyID)
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	t.families[familyID] = familyEntry{revoked: family.RevokedAt != nil, checkedAt: time.Now()}
+	t.mu.Unlock()
+	return family.RevokedAt != nil, nil
+}
+
+// evictExpired membersihkan cache agar tidak tumbuh tanpa batas, dipanggil saat lock ditahan
+func (t *tokenRevocationService) evictExpired(now time.Time) {
+	for jti, exp := range t.revokedJTI {
+		if exp < now.Unix() {
+			delete(t.revokedJTI, jti)
+		}
+	}
+	for jti, checkedAt := range t.checkedJTI {
+		if now.Sub(checkedAt) >= revocationCacheTTL {
+			delete(t.checkedJTI, jti)
+		}
+	}
+	for username, entry := range t.revokedBefore {
+		if now.Sub(entry.checkedAt) >= revocationCacheTTL {
+			delete(t.revokedBefore, username)
+		}
+	}
+	for familyID, entry := range t.families {
+		if now.Sub(entry.checkedAt) >= revocationCacheTTL {
+			delete(t.families, familyID)
+		}
+	}
+}
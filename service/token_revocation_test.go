@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"io"
+	"testing"
+)
+
+// fakeTokenRevocationDao jti yang dicabut di memory, hanya Revoke yang digunakan Consume
+type fakeTokenRevocationDao struct {
+	t       *testing.T
+	revoked map[string]bool
+}
+
+var _ dao.TokenRevocationDaoAssumer = (*fakeTokenRevocationDao)(nil)
+
+func (f *fakeTokenRevocationDao) Revoke(_ context.Context, jti string, _ string, _ int64) (bool, rest_err.APIError) {
+	if f.revoked[jti] {
+		return false, nil
+	}
+	f.revoked[jti] = true
+	return true, nil
+}
+
+func (f *fakeTokenRevocationDao) IsRevoked(_ context.Context, _ string) (bool, rest_err.APIError) {
+	return false, unexpectedCall(f.t, "IsRevoked")
+}
+
+func (f *fakeTokenRevocationDao) RevokeAllBefore(_ context.Context, _ string, _ int64) rest_err.APIError {
+	return unexpectedCall(f.t, "RevokeAllBefore")
+}
+
+func (f *fakeTokenRevocationDao) GetRevokedBefore(_ context.Context, _ string) (int64, rest_err.APIError) {
+	return 0, unexpectedCall(f.t, "GetRevokedBefore")
+}
+
+func (f *fakeTokenRevocationDao) PurgeExpired(_ context.Context, _ int64) rest_err.APIError {
+	return unexpectedCall(f.t, "PurgeExpired")
+}
+
+func TestTokenRevocationConsumeOnce(t *testing.T) {
+	fake := &fakeTokenRevocationDao{t: t, revoked: map[string]bool{}}
+	service := NewTokenRevocationService(fake, nil, mlog.New(io.Discard, &mlog.LevelVar{}))
+	claims := &mjwt.CustomClaim{TokenID: "jti-1", Identity: "BUDI", Type: mjwt.MFAPending}
+
+	for i, want := range []bool{true, false} {
+		consumed, apiErr := service.Consume(context.Background(), claims)
+		if apiErr != nil {
+			t.Fatalf("Consume: %v", apiErr)
+		}
+		if consumed != want {
+			t.Fatalf("percobaan %d consumed = %v, want %v", i+1, consumed, want)
+		}
+	}
+}
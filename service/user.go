@@ -1,91 +1,389 @@
 package service
 
 import (
+	"context"
 	"github.com/muchlist/sagasql/dao"
 	"github.com/muchlist/sagasql/dto"
 	"github.com/muchlist/sagasql/utils/mcrypt"
 	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/mmetric"
+	"github.com/muchlist/sagasql/utils/mpassword"
+	"github.com/muchlist/sagasql/utils/mtrace"
 	"github.com/muchlist/sagasql/utils/rest_err"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
-func NewUserService(dao dao.UserDaoAssumer, crypto mcrypt.BcryptAssumer, jwt mjwt.JWTAssumer) UserServiceAssumer {
+func NewUserService(dao dao.UserDaoAssumer, refreshDao dao.RefreshTokenDaoAssumer, guard LoginGuardServiceAssumer, revocation TokenRevocationServiceAssumer, rbac RBACServiceAssumer, verification EmailVerificationServiceAssumer, mfa MFAServiceAssumer, orgs OrgServiceAssumer, crypto mcrypt.PasswordHasherAssumer, jwt mjwt.JWTAssumer, tokenPolicy mjwt.TokenPolicy, log mlog.LoggerAssumer) UserServiceAssumer {
 	return &userService{
-		dao:    dao,
-		crypto: crypto,
-		jwt:    jwt,
+		dao:          dao,
+		refreshDao:   refreshDao,
+		guard:        guard,
+		revocation:   revocation,
+		rbac:         rbac,
+		verification: verification,
+		mfa:          mfa,
+		orgs:         orgs,
+		crypto:       crypto,
+		jwt:          jwt,
+		tokenPolicy:  tokenPolicy,
+		log:          log,
 	}
 }
 
 type userService struct {
-	dao    dao.UserDaoAssumer
-	crypto mcrypt.BcryptAssumer
-	jwt    mjwt.JWTAssumer
+	dao          dao.UserDaoAssumer
+	refreshDao   dao.RefreshTokenDaoAssumer
+	guard        LoginGuardServiceAssumer
+	revocation   TokenRevocationServiceAssumer
+	rbac         RBACServiceAssumer
+	verification EmailVerificationServiceAssumer
+	mfa          MFAServiceAssumer
+	orgs         OrgServiceAssumer
+	crypto       mcrypt.PasswordHasherAssumer
+	jwt          mjwt.JWTAssumer
+	tokenPolicy  mjwt.TokenPolicy
+	log          mlog.LoggerAssumer
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 type UserServiceAssumer interface {
-	Login(login dto.UserLoginRequest) (*dto.UserLoginResponse, rest_err.APIError)
-	InsertUser(user dto.User) (*string, rest_err.APIError)
-	EditUser(request dto.User) (*dto.User, rest_err.APIError)
-	Refresh(payload dto.UserRefreshTokenRequest) (*dto.UserRefreshTokenResponse, rest_err.APIError)
-	DeleteUser(username string) rest_err.APIError
-	GetUser(username string) (*dto.User, rest_err.APIError)
-	FindUsers() ([]dto.User, rest_err.APIError)
+	Login(ctx context.Context, login dto.UserLoginRequest, clientIP string) (*dto.UserLoginResponse, rest_err.APIError)
+	LoginMFA(ctx context.Context, request dto.MFALoginRequest, clientIP string) (*dto.UserLoginResponse, rest_err.APIError)
+	EnrollMFALogin(ctx context.Context, request dto.MFAEnrollLoginRequest) (*dto.MFAEnrollResponse, rest_err.APIError)
+	LoginExternal(ctx context.Context, username string) (*dto.UserLoginResponse, rest_err.APIError)
+	InsertUser(ctx context.Context, user dto.User) (*string, rest_err.APIError)
+	InsertFirstUser(ctx context.Context, user dto.User) (*string, rest_err.APIError)
+	EditUser(ctx context.Context, request dto.User) (*dto.User, rest_err.APIError)
+	Refresh(ctx context.Context, payload dto.UserRefreshTokenRequest) (*dto.UserRefreshTokenResponse, rest_err.APIError)
+	SwitchOrg(ctx context.Context, claims *mjwt.CustomClaim, request dto.OrgSwitchRequest) (*dto.UserRefreshTokenResponse, rest_err.APIError)
+	Logout(ctx context.Context, accessClaims *mjwt.CustomClaim, payload dto.UserLogoutRequest) rest_err.APIError
+	RevokeAllTokens(ctx context.Context, username string) rest_err.APIError
+	ChangePassword(ctx context.Context, claims *mjwt.CustomClaim, request dto.UserChangePasswordRequest, clientIP string) rest_err.APIError
+	SetLanguage(ctx context.Context, username string, request dto.UserLanguageRequest) rest_err.APIError
+	ForcePasswordReset(ctx context.Context, username string, request dto.UserForcePasswordResetRequest) rest_err.APIError
+	DeleteUser(ctx context.Context, username string) rest_err.APIError
+	GetUser(ctx context.Context, username string) (*dto.User, rest_err.APIError)
+	FindUsers(ctx context.Context) ([]dto.User, rest_err.APIError)
 }
 
-// Login
-func (u *userService) Login(login dto.UserLoginRequest) (*dto.UserLoginResponse, rest_err.APIError) {
-	user, err := u.dao.Get(login.Username)
+// Login memeriksa penundaan login (brute force) sebelum mencocokkan password.
+// jika user menggunakan 2FA response hanya berisi token mfa pending untuk langkah kedua (LoginMFA)
+func (u *userService) Login(ctx context.Context, login dto.UserLoginRequest, clientIP string) (*dto.UserLoginResponse, rest_err.APIError) {
+	if err := u.guard.Check(ctx, login.Username, clientIP); err != nil {
+		mmetric.IncLogin(mmetric.LoginFailed)
+		u.log.Warn(ctx, "login ditolak, masih dalam masa penundaan", mlog.String("username", login.Username), mlog.String("ip", clientIP))
+		return nil, err
+	}
+
+	// error selain user tidak ditemukan (misal database tidak tersedia) dikembalikan apa adanya
+	// dan tidak dihitung sebagai login gagal
+	user, err := u.dao.Get(ctx, login.Username)
+	if err != nil && err.Status() != http.StatusNotFound {
+		return nil, err
+	}
+
+	// user yang tidak ditemukan tetap dicocokkan dengan dummy hash agar waktu response
+	// tidak membedakan username yang terdaftar dan yang tidak
+	passwordHash := u.dummyPasswordHash(ctx)
+	if user != nil {
+		passwordHash = user.Password
+	}
+	_, span := mtrace.Start(ctx, "password.Verify")
+	passwordMatch := u.crypto.IsPWAndHashPWMatch(login.Password, passwordHash)
+	span.End()
+	if user == nil || !passwordMatch {
+		mmetric.IncLogin(mmetric.LoginFailed)
+		if user == nil {
+			u.log.Warn(ctx, "login gagal, user tidak ditemukan", mlog.String("username", login.Username))
+		} else {
+			u.log.Warn(ctx, "login gagal, password salah", mlog.String("username", login.Username))
+		}
+		if err := u.guard.RegisterFailure(ctx, login.Username, clientIP); err != nil {
+			return nil, err
+		}
+		return nil, rest_err.From(rest_err.ErrInvalidCredentials)
+	}
+
+	u.rehashPassword(ctx, user, login.Password)
+
+	emailUnverified, err := u.verification.LoginRestriction(ctx, user)
 	if err != nil {
-		return nil, rest_err.NewBadRequestError("Username atau password tidak valid")
+		mmetric.IncLogin(mmetric.LoginFailed)
+		return nil, err
 	}
 
-	if !u.crypto.IsPWAndHashPWMatch(login.Password, user.Password) {
-		return nil, rest_err.NewUnauthorizedError("Username atau password tidak valid")
+	mfaPending, err := u.mfaPendingResponse(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if mfaPending != nil {
+		u.log.Info(ctx, "login menunggu verifikasi 2FA", mlog.String("username", mfaPending.Username))
+		return mfaPending, nil
 	}
 
-	AccessClaims := mjwt.CustomClaim{
-		Identity:    string(user.Username),
-		Name:        user.Name,
-		Roles:       user.Role,
-		ExtraMinute: 60 * 24 * 1, // 1 Hour
-		Type:        mjwt.Access,
-		Fresh:       true,
+	userResponse, err := u.issueSession(ctx, user, emailUnverified)
+	if err != nil {
+		return nil, err
 	}
 
-	RefreshClaims := mjwt.CustomClaim{
-		Identity:    string(user.Username),
-		Name:        user.Name,
-		Roles:       user.Role,
-		ExtraMinute: 60 * 24 * 10, // 60 days
-		Type:        mjwt.Refresh,
+	if err := u.guard.RegisterSuccess(ctx, login.Username); err != nil {
+		return nil, err
 	}
 
-	accessToken, err := u.jwt.GenerateToken(AccessClaims)
+	mmetric.IncLogin(mmetric.LoginSuccess)
+	u.log.Info(ctx, "login berhasil", mlog.String("username", userResponse.Username))
+	return userResponse, nil
+}
+
+// LoginMFA langkah kedua login, menukar token mfa pending dan kode 2FA dengan access dan refresh token.
+// jika user sedang enrolment (role mewajibkan 2FA) kode pertama sekaligus mengaktifkan 2FA.
+// kode yang salah dihitung sebagai login gagal pada login guard
+func (u *userService) LoginMFA(ctx context.Context, request dto.MFALoginRequest, clientIP string) (*dto.UserLoginResponse, rest_err.APIError) {
+	claims, err := u.readMFAToken(ctx, request.MFAToken)
 	if err != nil {
 		return nil, err
 	}
-	refreshToken, err := u.jwt.GenerateToken(RefreshClaims)
+	if err := u.guard.Check(ctx, claims.Identity, clientIP); err != nil {
+		mmetric.IncLogin(mmetric.LoginFailed)
+		return nil, err
+	}
+
+	user, err := u.dao.Get(ctx, claims.Identity)
 	if err != nil {
 		return nil, err
 	}
 
-	userResponse := dto.UserLoginResponse{
-		Username:     string(user.Username),
-		Email:        user.Email,
-		Name:         user.Name,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		Expired:      time.Now().Add(time.Minute * time.Duration(60*24*1)).Unix(),
+	enabled, err := u.mfa.Enabled(ctx, claims.Identity)
+	if err != nil {
+		return nil, err
+	}
+	var recoveryCodes []string
+	if enabled {
+		err = u.mfa.Verify(ctx, claims.Identity, request.Code)
+	} else {
+		recoveryCodes, err = u.mfa.Enable(ctx, claims.Identity, request.Code)
+	}
+	if err != nil {
+		mmetric.IncLogin(mmetric.LoginFailed)
+		if err := u.guard.RegisterFailure(ctx, claims.Identity, clientIP); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+
+	// token mfa pending hanya dapat ditukar sekali, request bersamaan dengan token yang sama hanya satu yang berhasil
+	consumed, err := u.revocation.Consume(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		u.log.Warn(ctx, "login 2FA menggunakan token mfa pending yang sudah dipakai", mlog.String("username", claims.Identity))
+		return nil, rest_err.From(rest_err.ErrTokenRevoked)
+	}
+
+	emailUnverified, err := u.verification.LoginRestriction(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	userResponse, err := u.issueSession(ctx, user, emailUnverified)
+	if err != nil {
+		return nil, err
+	}
+	userResponse.RecoveryCodes = recoveryCodes
+
+	if err := u.guard.RegisterSuccess(ctx, claims.Identity); err != nil {
+		return nil, err
+	}
+
+	mmetric.IncLogin(mmetric.LoginSuccess)
+	u.log.Info(ctx, "login berhasil dengan 2FA", mlog.String("username", userResponse.Username))
+	return userResponse, nil
+}
+
+// EnrollMFALogin memulai enrolment 2FA menggunakan token mfa pending, untuk user yang role nya
+// mewajibkan 2FA namun belum pernah enrolment
+func (u *userService) EnrollMFALogin(ctx context.Context, request dto.MFAEnrollLoginRequest) (*dto.MFAEnrollResponse, rest_err.APIError) {
+	claims, err := u.readMFAToken(ctx, request.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	return u.mfa.Enroll(ctx, claims.Identity)
+}
+
+// LoginExternal menyelesaikan login user yang sudah diautentikasi oleh identity provider eksternal (OIDC).
+// password tidak dicek, namun kebijakan verifikasi email dan 2FA tetap berlaku
+func (u *userService) LoginExternal(ctx context.Context, username string) (*dto.UserLoginResponse, rest_err.APIError) {
+	user, err := u.dao.Get(ctx, username)
+	if err != nil {
+		return nil, err
 	}
 
-	return &userResponse, nil
+	emailUnverified, err := u.verification.LoginRestriction(ctx, user)
+	if err != nil {
+		mmetric.IncLogin(mmetric.LoginFailed)
+		return nil, err
+	}
+
+	mfaPending, err := u.mfaPendingResponse(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if mfaPending != nil {
+		u.log.Info(ctx, "login eksternal menunggu verifikasi 2FA", mlog.String("username", mfaPending.Username))
+		return mfaPending, nil
+	}
+
+	userResponse, err := u.issueSession(ctx, user, emailUnverified)
+	if err != nil {
+		return nil, err
+	}
+
+	mmetric.IncLogin(mmetric.LoginSuccess)
+	u.log.Info(ctx, "login eksternal berhasil", mlog.String("username", userResponse.Username))
+	return userResponse, nil
 }
 
-// InsertUser melakukan register user
-func (u *userService) InsertUser(user dto.User) (*string, rest_err.APIError) {
+// mfaPendingResponse mengembalikan response berisi token mfa pending jika user menggunakan 2FA
+// atau role nya mewajibkan 2FA, nil jika login dapat langsung diselesaikan
+func (u *userService) mfaPendingResponse(ctx context.Context, user *dto.User) (*dto.UserLoginResponse, rest_err.APIError) {
+	enabled, err := u.mfa.Enabled(ctx, string(user.Username))
+	if err != nil {
+		return nil, err
+	}
+	required, err := u.rbac.MFARequired(ctx, user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled && !required {
+		return nil, nil
+	}
+
+	now := time.Now()
+	pendingClaims := mjwt.CustomClaim{
+		Identity: string(user.Username),
+		Name:     user.Name,
+		Roles:    user.Roles,
+		Exp:      u.tokenPolicy.ExpiresAt(user.Roles, mjwt.MFAPending, false, now, now),
+		Type:     mjwt.MFAPending,
+	}
+
+	_, span := mtrace.Start(ctx, "jwt.Generate")
+	mfaToken, err := u.jwt.GenerateToken(pendingClaims)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UserLoginResponse{
+		Username:              string(user.Username),
+		Email:                 user.Email,
+		Name:                  user.Name,
+		Roles:                 user.Roles,
+		Expired:               pendingClaims.Exp,
+		MustChangePassword:    user.MustChangePassword,
+		EmailVerified:         user.EmailVerifiedAt != nil,
+		MFARequired:           true,
+		MFAToken:              mfaToken,
+		MFAEnrollmentRequired: !enabled,
+	}, nil
+}
+
+// issueSession memulai sesi baru dan menerbitkan access serta refresh token.
+// sesi dimulai ketika login, setiap login membuat family refresh token baru.
+// id family juga dimasukkan ke access token agar sesi dapat dicabut secara utuh.
+// organisasi aktif diisi dengan organisasi pertama user
+func (u *userService) issueSession(ctx context.Context, user *dto.User, emailUnverified bool) (*dto.UserLoginResponse, rest_err.APIError) {
+	orgID, err := u.orgs.DefaultOrg(ctx, string(user.Username))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	familyID, genErr := mjwt.NewTokenID()
+	if genErr != nil {
+		return nil, rest_err.NewInternalServerError("token.id_failed", genErr)
+	}
+	if err := u.refreshDao.CreateFamily(ctx, familyID, string(user.Username), now.Unix()); err != nil {
+		return nil, err
+	}
+
+	AccessClaims := mjwt.CustomClaim{
+		FamilyID:           familyID,
+		Identity:           string(user.Username),
+		Name:               user.Name,
+		Roles:              user.Roles,
+		Exp:                u.tokenPolicy.ExpiresAt(user.Roles, mjwt.Access, true, now, now),
+		Type:               mjwt.Access,
+		Fresh:              true,
+		MustChangePassword: user.MustChangePassword,
+		EmailUnverified:    emailUnverified,
+		OrgID:              orgID,
+		Language:           user.Language,
+	}
+
+	_, span := mtrace.Start(ctx, "jwt.Generate")
+	accessToken, err := u.jwt.GenerateToken(AccessClaims)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := u.issueRefreshToken(ctx, user, familyID, orgID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UserLoginResponse{
+		Username:           string(user.Username),
+		Email:              user.Email,
+		Name:               user.Name,
+		Roles:              user.Roles,
+		AccessToken:        accessToken,
+		RefreshToken:       refreshToken,
+		Expired:            AccessClaims.Exp,
+		MustChangePassword: user.MustChangePassword,
+		EmailVerified:      user.EmailVerifiedAt != nil,
+		OrgID:              orgID,
+	}, nil
+}
+
+// InsertUser melakukan register user, role yang diberikan harus tersedia di database.
+// tautan verifikasi dikirim ke email user setelah tersimpan
+func (u *userService) InsertUser(ctx context.Context, user dto.User) (*string, rest_err.APIError) {
+	return u.insertUser(ctx, user, u.dao.Insert)
+}
+
+// InsertFirstUser register tanpa autentikasi untuk instalasi baru (/register-force),
+// hanya berhasil jika belum ada user sama sekali sehingga tidak dapat dipakai membuat akun setelah admin pertama ada
+func (u *userService) InsertFirstUser(ctx context.Context, user dto.User) (*string, rest_err.APIError) {
+	insertedUserID, err := u.insertUser(ctx, user, u.dao.InsertFirst)
+	if err != nil {
+		return nil, err
+	}
+	if insertedUserID == nil {
+		u.log.Warn(ctx, "register-force ditolak, sudah ada user", mlog.String("username", string(user.Username)))
+		return nil, rest_err.NewForbiddenError("user.register_force_closed")
+	}
+	return insertedUserID, nil
+}
+
+// insertUser menyimpan user menggunakan insert, hasil nil dari insert (user tidak disimpan) diteruskan ke pemanggil
+func (u *userService) insertUser(ctx context.Context, user dto.User, insert func(context.Context, dto.User) (*string, rest_err.APIError)) (*string, rest_err.APIError) {
+	user.Roles = normalizeRoles(user.Roles)
+	if err := u.rbac.ValidateRoles(ctx, user.Roles); err != nil {
+		return nil, err
+	}
+
+	_, span := mtrace.Start(ctx, "bcrypt.Hash")
 	hashPassword, err := u.crypto.GenerateHash(user.Password)
+	span.End()
 	if err != nil {
 		return nil, err
 	}
@@ -94,52 +392,117 @@ func (u *userService) InsertUser(user dto.User) (*string, rest_err.APIError) {
 	user.CreatedAt = time.Now().Unix()
 	user.UpdatedAt = time.Now().Unix()
 
-	insertedUserID, err := u.dao.Insert(user)
-	if err != nil {
+	insertedUserID, err := insert(ctx, user)
+	if err != nil || insertedUserID == nil {
+		return nil, err
+	}
+	u.log.Info(ctx, "user baru diregistrasi", mlog.String("username", *insertedUserID), mlog.Any("roles", user.Roles))
+
+	if err := u.verification.SendVerification(ctx, user); err != nil {
 		return nil, err
 	}
 	return insertedUserID, nil
 }
 
-// EditUser
-func (u *userService) EditUser(request dto.User) (*dto.User, rest_err.APIError) {
+// EditUser jika email diganti status verifikasi direset dan tautan verifikasi dikirim ke email baru.
+// perubahan selain email (misal name) tidak mengirim email verifikasi lagi
+func (u *userService) EditUser(ctx context.Context, request dto.User) (*dto.User, rest_err.APIError) {
+	current, err := u.dao.Get(ctx, string(request.Username))
+	if err != nil {
+		return nil, err
+	}
+
 	request.UpdatedAt = time.Now().Unix()
-	result, err := u.dao.Edit(request)
+	result, err := u.dao.Edit(ctx, request)
 	if err != nil {
 		return nil, err
 	}
+	if strings.EqualFold(current.Email, result.Email) {
+		return result, nil
+	}
+	if err := u.verification.SendVerification(ctx, *result); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
-// Refresh token
-func (u *userService) Refresh(payload dto.UserRefreshTokenRequest) (*dto.UserRefreshTokenResponse, rest_err.APIError) {
-	token, apiErr := u.jwt.ValidateToken(payload.RefreshToken)
+// Refresh menerbitkan access token baru dan merotasi refresh token.
+// refresh token hanya dapat digunakan sekali, jika token yang sudah dipakai dikirim kembali
+// (kemungkinan dicuri) seluruh family nya dicabut sehingga pencuri maupun pemilik harus login ulang
+func (u *userService) Refresh(ctx context.Context, payload dto.UserRefreshTokenRequest) (*dto.UserRefreshTokenResponse, rest_err.APIError) {
+	claims, apiErr := u.readRefreshToken(ctx, payload.RefreshToken)
 	if apiErr != nil {
 		return nil, apiErr
 	}
-	claims, apiErr := u.jwt.ReadToken(token)
+
+	if claims.FamilyID == "" || claims.TokenID == "" {
+		return nil, rest_err.NewUnauthorizedError("token.refresh_legacy")
+	}
+
+	revoked, apiErr := u.revocation.IsRevoked(ctx, claims)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if revoked {
+		u.log.Warn(ctx, "refresh menggunakan token yang sudah dicabut", mlog.String("username", claims.Identity))
+		return nil, rest_err.From(rest_err.ErrTokenRevoked)
+	}
+
+	family, apiErr := u.refreshDao.GetFamily(ctx, claims.FamilyID)
 	if apiErr != nil {
 		return nil, apiErr
 	}
+	if family.RevokedAt != nil {
+		u.log.Warn(ctx, "refresh menggunakan family yang sudah dicabut", mlog.String("username", claims.Identity), mlog.String("family_id", claims.FamilyID))
+		return nil, rest_err.From(rest_err.ErrTokenRevoked)
+	}
 
-	// cek apakah tipe claims token yang dikirim adalah tipe refresh (1)
-	if claims.Type != mjwt.Refresh {
-		return nil, rest_err.NewAPIError("Token tidak valid", http.StatusUnprocessableEntity, "jwt_error", []interface{}{"not a refresh token"})
+	now := time.Now()
+	sessionStart := time.Unix(family.CreatedAt, 0)
+	if u.tokenPolicy.SessionExpired(sessionStart, now) {
+		u.log.Info(ctx, "refresh ditolak, sesi melewati batas absolut", mlog.String("username", claims.Identity), mlog.String("family_id", claims.FamilyID))
+		return nil, rest_err.NewUnauthorizedError("token.session_expired")
+	}
+
+	firstUse, apiErr := u.refreshDao.MarkUsed(ctx, claims.TokenID, now.Unix())
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !firstUse {
+		// jti tidak dikenal atau sudah pernah dipakai, cabut seluruh family
+		if apiErr := u.refreshDao.RevokeFamily(ctx, claims.FamilyID, now.Unix()); apiErr != nil {
+			return nil, apiErr
+		}
+		u.log.Warn(ctx, "refresh token dipakai ulang, family dicabut", mlog.String("username", claims.Identity), mlog.String("family_id", claims.FamilyID))
+		return nil, rest_err.NewUnauthorizedError("token.refresh_reused")
 	}
 
 	// mendapatkan data terbaru dari user
-	user, apiErr := u.dao.Get(claims.Identity)
+	user, apiErr := u.dao.Get(ctx, claims.Identity)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	emailUnverified, apiErr := u.verification.LoginRestriction(ctx, user)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	orgID, apiErr := u.activeOrg(ctx, claims)
 	if apiErr != nil {
 		return nil, apiErr
 	}
 
 	AccessClaims := mjwt.CustomClaim{
-		Identity:    string(user.Username),
-		Name:        user.Name,
-		Roles:       user.Role,
-		ExtraMinute: time.Duration(60 * 60 * 1),
-		Type:        mjwt.Access,
-		Fresh:       false,
+		FamilyID:           claims.FamilyID,
+		Identity:           string(user.Username),
+		Name:               user.Name,
+		Roles:              user.Roles,
+		Exp:                u.tokenPolicy.ExpiresAt(user.Roles, mjwt.Access, false, now, sessionStart),
+		Type:               mjwt.Access,
+		Fresh:              false,
+		MustChangePassword: user.MustChangePassword,
+		EmailUnverified:    emailUnverified,
+		OrgID:              orgID,
+		Language:           user.Language,
 	}
 
 	accessToken, err := u.jwt.GenerateToken(AccessClaims)
@@ -147,26 +510,348 @@ func (u *userService) Refresh(payload dto.UserRefreshTokenRequest) (*dto.UserRef
 		return nil, err
 	}
 
+	refreshToken, err := u.issueRefreshToken(ctx, user, claims.FamilyID, orgID, sessionStart)
+	if err != nil {
+		return nil, err
+	}
+
 	userRefreshTokenResponse := dto.UserRefreshTokenResponse{
-		AccessToken: accessToken,
-		Expired:     time.Now().Add(time.Minute * time.Duration(60*60*1)).Unix(),
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Expired:      AccessClaims.Exp,
+		OrgID:        orgID,
 	}
 
+	mmetric.IncTokenRefresh()
 	return &userRefreshTokenResponse, nil
 }
 
+// activeOrg organisasi aktif pada token hasil refresh, tetap menggunakan organisasi pada refresh token
+// selama user masih menjadi anggotanya, selain itu kembali ke organisasi pertama user
+func (u *userService) activeOrg(ctx context.Context, claims *mjwt.CustomClaim) (string, rest_err.APIError) {
+	if claims.OrgID != "" {
+		_, member, err := u.orgs.OrgRoles(ctx, claims.OrgID, claims.Identity)
+		if err != nil {
+			return "", err
+		}
+		if member {
+			return claims.OrgID, nil
+		}
+	}
+	return u.orgs.DefaultOrg(ctx, claims.Identity)
+}
+
+// SwitchOrg menerbitkan access dan refresh token baru dengan organisasi aktif request.OrgID.
+// token baru tetap berada di family (sesi) yang sama sehingga batas absolut sesi dan logout tetap berlaku.
+// seperti /refresh, access token baru tidak fresh dan refresh token lama sesi tersebut tidak dapat dipakai lagi
+func (u *userService) SwitchOrg(ctx context.Context, claims *mjwt.CustomClaim, request dto.OrgSwitchRequest) (*dto.UserRefreshTokenResponse, rest_err.APIError) {
+	if claims.APIKeyID != "" || claims.FamilyID == "" {
+		return nil, rest_err.NewForbiddenError("org.switch_requires_login_token")
+	}
+
+	_, member, apiErr := u.orgs.OrgRoles(ctx, request.OrgID, claims.Identity)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !member {
+		return nil, rest_err.NewForbiddenError("org.not_member", request.OrgID)
+	}
+
+	family, apiErr := u.refreshDao.GetFamily(ctx, claims.FamilyID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	now := time.Now()
+	sessionStart := time.Unix(family.CreatedAt, 0)
+	if family.RevokedAt != nil || u.tokenPolicy.SessionExpired(sessionStart, now) {
+		return nil, rest_err.NewUnauthorizedError("token.session_expired")
+	}
+
+	user, apiErr := u.dao.Get(ctx, claims.Identity)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	AccessClaims := mjwt.CustomClaim{
+		FamilyID:           claims.FamilyID,
+		Identity:           string(user.Username),
+		Name:               user.Name,
+		Roles:              user.Roles,
+		Exp:                u.tokenPolicy.ExpiresAt(user.Roles, mjwt.Access, false, now, sessionStart),
+		Type:               mjwt.Access,
+		Fresh:              false,
+		MustChangePassword: user.MustChangePassword,
+		EmailUnverified:    claims.EmailUnverified,
+		OrgID:              request.OrgID,
+		Language:           user.Language,
+	}
+
+	accessToken, err := u.jwt.GenerateToken(AccessClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	// refresh token sesi yang masih berlaku diganti dengan refresh token untuk organisasi baru,
+	// sehingga setiap family tetap hanya memiliki satu refresh token yang dapat dipakai
+	if apiErr := u.refreshDao.MarkFamilyUsed(ctx, claims.FamilyID, now.Unix()); apiErr != nil {
+		return nil, apiErr
+	}
+	refreshToken, err := u.issueRefreshToken(ctx, user, claims.FamilyID, request.OrgID, sessionStart)
+	if err != nil {
+		return nil, err
+	}
+
+	u.log.Info(ctx, "organisasi aktif diganti", mlog.String("username", claims.Identity), mlog.String("from", claims.OrgID), mlog.String("to", request.OrgID))
+	return &dto.UserRefreshTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Expired:      AccessClaims.Exp,
+		OrgID:        request.OrgID,
+	}, nil
+}
+
+// Logout mencabut access token yang sedang digunakan beserta refresh token pasangannya (jika dikirim)
+func (u *userService) Logout(ctx context.Context, accessClaims *mjwt.CustomClaim, payload dto.UserLogoutRequest) rest_err.APIError {
+	var refreshClaims *mjwt.CustomClaim
+	if payload.RefreshToken != "" {
+		claims, apiErr := u.readRefreshToken(ctx, payload.RefreshToken)
+		if apiErr != nil {
+			return apiErr
+		}
+		if claims.Identity != accessClaims.Identity {
+			return rest_err.NewBadRequestError("token.refresh_not_owned")
+		}
+		refreshClaims = claims
+	}
+
+	if apiErr := u.revocation.Revoke(ctx, accessClaims); apiErr != nil {
+		return apiErr
+	}
+	if accessClaims.FamilyID != "" {
+		if apiErr := u.revocation.RevokeSession(ctx, accessClaims.FamilyID); apiErr != nil {
+			return apiErr
+		}
+	}
+	if refreshClaims != nil {
+		if apiErr := u.revocation.Revoke(ctx, refreshClaims); apiErr != nil {
+			return apiErr
+		}
+		if refreshClaims.FamilyID != "" && refreshClaims.FamilyID != accessClaims.FamilyID {
+			if apiErr := u.revocation.RevokeSession(ctx, refreshClaims.FamilyID); apiErr != nil {
+				return apiErr
+			}
+		}
+	}
+
+	u.log.Info(ctx, "logout berhasil", mlog.String("username", accessClaims.Identity))
+	return nil
+}
+
+// RevokeAllTokens mencabut semua token yang pernah diterbitkan untuk username
+func (u *userService) RevokeAllTokens(ctx context.Context, username string) rest_err.APIError {
+	if _, apiErr := u.dao.Get(ctx, username); apiErr != nil {
+		return apiErr
+	}
+	return u.revocation.RevokeAllForUser(ctx, username)
+}
+
+// SetLanguage mengganti preferensi bahasa user, berlaku pada access token berikutnya (login, refresh atau ganti organisasi)
+func (u *userService) SetLanguage(ctx context.Context, username string, request dto.UserLanguageRequest) rest_err.APIError {
+	return u.dao.SetLanguage(ctx, username, request.Language, time.Now().Unix())
+}
+
+// ChangePassword mengganti password user yang sedang login setelah mencocokkan password lama,
+// semua sesi lain dicabut sedangkan sesi yang digunakan tetap berlaku.
+// password lama yang salah dihitung sebagai login gagal pada login guard
+func (u *userService) ChangePassword(ctx context.Context, claims *mjwt.CustomClaim, request dto.UserChangePasswordRequest, clientIP string) rest_err.APIError {
+	if err := u.guard.Check(ctx, claims.Identity, clientIP); err != nil {
+		return err
+	}
+
+	user, err := u.dao.Get(ctx, claims.Identity)
+	if err != nil {
+		return err
+	}
+
+	_, span := mtrace.Start(ctx, "password.Verify")
+	passwordMatch := u.crypto.IsPWAndHashPWMatch(request.OldPassword, user.Password)
+	span.End()
+	if !passwordMatch {
+		u.log.Warn(ctx, "ganti password gagal, password lama salah", mlog.String("username", claims.Identity))
+		if err := u.guard.RegisterFailure(ctx, claims.Identity, clientIP); err != nil {
+			return err
+		}
+		return rest_err.NewBadRequestError("user.old_password_invalid")
+	}
+	if err := u.guard.RegisterSuccess(ctx, claims.Identity); err != nil {
+		return err
+	}
+	if err := checkPasswordPolicy("new_password", request.NewPassword, user); err != nil {
+		return err
+	}
+
+	_, span = mtrace.Start(ctx, "bcrypt.Hash")
+	hashPassword, err := u.crypto.GenerateHash(request.NewPassword)
+	span.End()
+	if err != nil {
+		return err
+	}
+
+	if _, err := u.dao.ChangePassword(ctx, dto.User{
+		Username:  user.Username,
+		Password:  hashPassword,
+		UpdatedAt: time.Now().Unix(),
+	}); err != nil {
+		return err
+	}
+
+	if err := u.revocation.RevokeOtherSessions(ctx, claims.Identity, claims.FamilyID); err != nil {
+		return err
+	}
+	// token lama tanpa family tidak dapat dibedakan sesinya, access token tanpa family juga tidak
+	// terikat ke sesi mana pun sehingga semua token yang sudah diterbitkan untuk user ikut dicabut
+	if claims.FamilyID == "" {
+		if err := u.revocation.RevokeAllForUser(ctx, claims.Identity); err != nil {
+			return err
+		}
+	}
+
+	u.log.Info(ctx, "password diganti", mlog.String("username", claims.Identity))
+	return nil
+}
+
+// ForcePasswordReset menandai user wajib mengganti password pada login berikutnya dan mencabut semua token nya
+func (u *userService) ForcePasswordReset(ctx context.Context, username string, request dto.UserForcePasswordResetRequest) rest_err.APIError {
+	var hashPassword *string
+	if request.TemporaryPassword != "" {
+		user, err := u.dao.Get(ctx, username)
+		if err != nil {
+			return err
+		}
+		if err := checkPasswordPolicy("temporary_password", request.TemporaryPassword, user); err != nil {
+			return err
+		}
+
+		_, span := mtrace.Start(ctx, "bcrypt.Hash")
+		hash, err := u.crypto.GenerateHash(request.TemporaryPassword)
+		span.End()
+		if err != nil {
+			return err
+		}
+		hashPassword = &hash
+	}
+
+	if err := u.dao.ForcePasswordChange(ctx, username, hashPassword, time.Now().Unix()); err != nil {
+		return err
+	}
+	if err := u.revocation.RevokeAllForUser(ctx, username); err != nil {
+		return err
+	}
+
+	u.log.Info(ctx, "user dipaksa mengganti password", mlog.String("username", username), mlog.Any("temporary_password", hashPassword != nil))
+	return nil
+}
+
+// issueRefreshToken mencatat jti refresh token baru pada family lalu menandatanganinya.
+// sessionStart adalah waktu login (created_at family) untuk membatasi umur absolut sesi,
+// orgID organisasi aktif yang dibawa ke access token hasil refresh
+func (u *userService) issueRefreshToken(ctx context.Context, user *dto.User, familyID string, orgID string, sessionStart time.Time) (string, rest_err.APIError) {
+	tokenID, genErr := mjwt.NewTokenID()
+	if genErr != nil {
+		return "", rest_err.NewInternalServerError("token.id_failed", genErr)
+	}
+
+	RefreshClaims := mjwt.CustomClaim{
+		TokenID:  tokenID,
+		FamilyID: familyID,
+		Identity: string(user.Username),
+		Name:     user.Name,
+		Roles:    user.Roles,
+		Exp:      u.tokenPolicy.ExpiresAt(user.Roles, mjwt.Refresh, false, time.Now(), sessionStart),
+		Type:     mjwt.Refresh,
+		OrgID:    orgID,
+	}
+
+	if err := u.refreshDao.InsertToken(ctx, dto.RefreshToken{
+		JTI:       tokenID,
+		FamilyID:  familyID,
+		ExpiresAt: RefreshClaims.Exp,
+	}); err != nil {
+		return "", err
+	}
+
+	_, span := mtrace.Start(ctx, "jwt.Generate")
+	refreshToken, err := u.jwt.GenerateToken(RefreshClaims)
+	span.End()
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// readRefreshToken memvalidasi token string dan memastikan tipenya adalah refresh token
+func (u *userService) readRefreshToken(ctx context.Context, tokenString string) (*mjwt.CustomClaim, rest_err.APIError) {
+	return u.readToken(ctx, tokenString, mjwt.Refresh)
+}
+
+// readToken memvalidasi token string dan memastikan tipenya sesuai tokenType
+// readMFAToken membaca token mfa pending dan menolaknya jika sudah dipakai untuk login
+func (u *userService) readMFAToken(ctx context.Context, tokenString string) (*mjwt.CustomClaim, rest_err.APIError) {
+	claims, err := u.readToken(ctx, tokenString, mjwt.MFAPending)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := u.revocation.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		u.log.Warn(ctx, "token mfa pending yang sudah dipakai digunakan kembali", mlog.String("username", claims.Identity))
+		return nil, rest_err.From(rest_err.ErrTokenRevoked)
+	}
+	return claims, nil
+}
+
+func (u *userService) readToken(ctx context.Context, tokenString string, tokenType int) (*mjwt.CustomClaim, rest_err.APIError) {
+	_, span := mtrace.Start(ctx, "jwt.Validate")
+	token, apiErr := u.jwt.ValidateToken(tokenString)
+	if apiErr != nil {
+		span.End()
+		return nil, apiErr
+	}
+	claims, apiErr := u.jwt.ReadToken(token)
+	span.End()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if claims.Type != tokenType {
+		u.log.Warn(ctx, "token yang dikirim tidak sesuai tipenya", mlog.String("username", claims.Identity), mlog.Int("type", claims.Type), mlog.Int("expected", tokenType))
+		return nil, rest_err.NewAPIError("token.invalid", http.StatusUnprocessableEntity, "jwt_error", []interface{}{tokenTypeNames[tokenType]})
+	}
+	return claims, nil
+}
+
+// tokenTypeNames pesan causes ketika tipe token tidak sesuai
+var tokenTypeNames = map[int]string{
+	mjwt.Access:     "not an access token",
+	mjwt.Refresh:    "not a refresh token",
+	mjwt.MFAPending: "not an mfa token",
+}
+
 // DeleteUser
-func (u *userService) DeleteUser(userName string) rest_err.APIError {
-	err := u.dao.Delete(userName)
+func (u *userService) DeleteUser(ctx context.Context, userName string) rest_err.APIError {
+	err := u.dao.Delete(ctx, userName)
 	if err != nil {
 		return err
 	}
+	u.log.Info(ctx, "user dihapus", mlog.String("username", userName))
 	return nil
 }
 
 // GetUser mendapatkan user dari database
-func (u *userService) GetUser(userName string) (*dto.User, rest_err.APIError) {
-	user, err := u.dao.Get(userName)
+func (u *userService) GetUser(ctx context.Context, userName string) (*dto.User, rest_err.APIError) {
+	user, err := u.dao.Get(ctx, userName)
 	if err != nil {
 		return nil, err
 	}
@@ -174,10 +859,55 @@ func (u *userService) GetUser(userName string) (*dto.User, rest_err.APIError) {
 }
 
 // FindUsers
-func (u *userService) FindUsers() ([]dto.User, rest_err.APIError) {
-	userList, err := u.dao.Find()
+func (u *userService) FindUsers(ctx context.Context) ([]dto.User, rest_err.APIError) {
+	userList, err := u.dao.Find(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return userList, nil
 }
+
+// rehashPassword memperbarui hash password yang dibuat dengan algoritma atau parameter lama
+// menggunakan password yang baru saja terverifikasi. kegagalan hanya dicatat agar login tetap berjalan
+func (u *userService) rehashPassword(ctx context.Context, user *dto.User, password string) {
+	if !u.crypto.NeedsRehash(user.Password) {
+		return
+	}
+
+	newHash, err := u.crypto.GenerateHash(password)
+	if err != nil {
+		u.log.Error(ctx, "gagal membuat ulang hash password", mlog.String("username", string(user.Username)), mlog.Err(err))
+		return
+	}
+	if err := u.dao.RehashPassword(ctx, string(user.Username), user.Password, newHash); err != nil {
+		u.log.Error(ctx, "gagal menyimpan hash password baru", mlog.String("username", string(user.Username)), mlog.Err(err))
+		return
+	}
+	u.log.Info(ctx, "hash password diperbarui", mlog.String("username", string(user.Username)))
+}
+
+// dummyPasswordHash hash dengan hasher default yang dicocokkan ketika username tidak ditemukan,
+// dibuat sekali ketika pertama kali dibutuhkan (setelah mcrypt.Init)
+func (u *userService) dummyPasswordHash(ctx context.Context) string {
+	u.dummyHashOnce.Do(func() {
+		hash, err := u.crypto.GenerateHash("dummy-password-untuk-username-tidak-dikenal")
+		if err != nil {
+			u.log.Error(ctx, "gagal membuat dummy hash password", mlog.Err(err))
+			return
+		}
+		u.dummyHash = hash
+	})
+	return u.dummyHash
+}
+
+// checkPasswordPolicy memeriksa password terhadap mpassword.Policy termasuk username dan email user,
+// dipakai ketika user belum diketahui pada saat validasi input di handler
+func checkPasswordPolicy(field string, password string, user *dto.User) rest_err.APIError {
+	err := mpassword.Validate(password, string(user.Username), user.Email)
+	if err == nil {
+		return nil
+	}
+
+	fields := []rest_err.FieldError{rest_err.NewFieldError(field, err)}
+	return rest_err.NewValidationError(rest_err.JoinFieldErrors(fields), fields)
+}
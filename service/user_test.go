@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/muchlist/sagasql/dao"
+	"github.com/muchlist/sagasql/dto"
+	"github.com/muchlist/sagasql/utils/mcrypt"
+	"github.com/muchlist/sagasql/utils/mjwt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// fakeUserDao user di memory untuk Login, getErr dikembalikan oleh Get jika diisi
+type fakeUserDao struct {
+	t      *testing.T
+	users  map[string]*dto.User
+	getErr rest_err.APIError
+}
+
+var _ dao.UserDaoAssumer = (*fakeUserDao)(nil)
+
+func (f *fakeUserDao) Get(_ context.Context, userName string) (*dto.User, rest_err.APIError) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	user, ok := f.users[userName]
+	if !ok {
+		return nil, rest_err.NewNotFoundError("db.no_rows")
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *fakeUserDao) Insert(_ context.Context, _ dto.User) (*string, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "Insert")
+}
+
+func (f *fakeUserDao) InsertFirst(_ context.Context, _ dto.User) (*string, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "InsertFirst")
+}
+
+func (f *fakeUserDao) Edit(_ context.Context, _ dto.User) (*dto.User, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "Edit")
+}
+
+func (f *fakeUserDao) Delete(_ context.Context, _ string) rest_err.APIError {
+	return unexpectedCall(f.t, "Delete")
+}
+
+func (f *fakeUserDao) ChangePassword(_ context.Context, _ dto.User) (*dto.User, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "ChangePassword")
+}
+
+func (f *fakeUserDao) SetLanguage(_ context.Context, _ string, _ string, _ int64) rest_err.APIError {
+	return unexpectedCall(f.t, "SetLanguage")
+}
+
+func (f *fakeUserDao) ForcePasswordChange(_ context.Context, _ string, _ *string, _ int64) rest_err.APIError {
+	return unexpectedCall(f.t, "ForcePasswordChange")
+}
+
+func (f *fakeUserDao) RehashPassword(_ context.Context, _ string, _ string, _ string) rest_err.APIError {
+	return unexpectedCall(f.t, "RehashPassword")
+}
+
+func (f *fakeUserDao) MarkEmailVerified(_ context.Context, _ string, _ string, _ int64) (bool, rest_err.APIError) {
+	return false, unexpectedCall(f.t, "MarkEmailVerified")
+}
+
+func (f *fakeUserDao) GetByEmail(_ context.Context, _ string) (*dto.User, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "GetByEmail")
+}
+
+func (f *fakeUserDao) Find(_ context.Context) ([]dto.User, rest_err.APIError) {
+	return nil, unexpectedCall(f.t, "Find")
+}
+
+// countingGuard login guard yang tidak pernah menunda, hanya menghitung login gagal
+type countingGuard struct {
+	t        *testing.T
+	failures int
+}
+
+var _ LoginGuardServiceAssumer = (*countingGuard)(nil)
+
+func (g *countingGuard) Check(_ context.Context, _ string, _ string) rest_err.APIError {
+	return nil
+}
+
+func (g *countingGuard) RegisterFailure(_ context.Context, _ string, _ string) rest_err.APIError {
+	g.failures++
+	return nil
+}
+
+func (g *countingGuard) RegisterSuccess(_ context.Context, _ string) rest_err.APIError {
+	return unexpectedCall(g.t, "RegisterSuccess")
+}
+
+func (g *countingGuard) Unlock(_ context.Context, _ dto.LoginUnlockRequest) rest_err.APIError {
+	return unexpectedCall(g.t, "Unlock")
+}
+
+// recordingHasher hasher tiruan yang mencatat setiap hash yang dicocokkan
+type recordingHasher struct {
+	verified []string
+}
+
+var _ mcrypt.PasswordHasherAssumer = (*recordingHasher)(nil)
+
+func (h *recordingHasher) GenerateHash(password string) (string, rest_err.APIError) {
+	return "hash:" + password, nil
+}
+
+func (h *recordingHasher) IsPWAndHashPWMatch(password string, hashPass string) bool {
+	h.verified = append(h.verified, hashPass)
+	return hashPass == "hash:"+password
+}
+
+func (h *recordingHasher) NeedsRehash(_ string) bool {
+	return false
+}
+
+func TestLoginRejected(t *testing.T) {
+	tests := []struct {
+		name         string
+		username     string
+		password     string
+		getErr       rest_err.APIError
+		wantCode     string
+		wantFailures int
+		wantVerified int
+	}{
+		{"password salah", "BUDI", "salah", nil, rest_err.CodeInvalidCredentials, 1, 1},
+		// username yang tidak terdaftar tetap menjalankan satu pencocokan hash
+		{"user tidak ditemukan", "SIAPA", "rahasia", nil, rest_err.CodeInvalidCredentials, 1, 1},
+		{"database tidak tersedia", "BUDI", "rahasia", rest_err.NewInternalServerError("db.error", errors.New("koneksi terputus")), rest_err.CodeInternal, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUserDao{t: t, users: map[string]*dto.User{"BUDI": {Username: "BUDI", Password: "hash:rahasia"}}, getErr: tt.getErr}
+			guard := &countingGuard{t: t}
+			hasher := &recordingHasher{}
+			service := NewUserService(users, nil, guard, nil, nil, nil, nil, nil, hasher, nil, mjwt.DefaultTokenPolicy, mlog.New(io.Discard, &mlog.LevelVar{}))
+
+			_, apiErr := service.Login(context.Background(), dto.UserLoginRequest{Username: tt.username, Password: tt.password}, "127.0.0.1")
+			if apiErr == nil || apiErr.Code() != tt.wantCode {
+				t.Fatalf("err = %v, want code %s", apiErr, tt.wantCode)
+			}
+			if guard.failures != tt.wantFailures {
+				t.Fatalf("login gagal tercatat %d kali, want %d", guard.failures, tt.wantFailures)
+			}
+			if len(hasher.verified) != tt.wantVerified {
+				t.Fatalf("hash dicocokkan %d kali, want %d", len(hasher.verified), tt.wantVerified)
+			}
+		})
+	}
+}
+
+func TestLoginUnknownUserUsesDefaultHasher(t *testing.T) {
+	users := &fakeUserDao{t: t, users: map[string]*dto.User{}}
+	hasher := &recordingHasher{}
+	service := NewUserService(users, nil, &countingGuard{t: t}, nil, nil, nil, nil, nil, hasher, nil, mjwt.DefaultTokenPolicy, mlog.New(io.Discard, &mlog.LevelVar{}))
+
+	for i := 0; i < 2; i++ {
+		_, apiErr := service.Login(context.Background(), dto.UserLoginRequest{Username: "SIAPA", Password: "rahasia"}, "127.0.0.1")
+		if apiErr == nil || apiErr.Status() != http.StatusUnauthorized {
+			t.Fatalf("err = %v, want 401", apiErr)
+		}
+	}
+	// dummy hash dibuat dengan hasher default sekali lalu dipakai ulang
+	if len(hasher.verified) != 2 || hasher.verified[0] == "" || hasher.verified[0] != hasher.verified[1] {
+		t.Fatalf("dummy hash tidak sesuai: %v", hasher.verified)
+	}
+}
+
+func TestChangePasswordWrongOldPasswordCountsFailure(t *testing.T) {
+	users := &fakeUserDao{t: t, users: map[string]*dto.User{"BUDI": {Username: "BUDI", Password: "hash:rahasia"}}}
+	guard := &countingGuard{t: t}
+	service := NewUserService(users, nil, guard, nil, nil, nil, nil, nil, &recordingHasher{}, nil, mjwt.DefaultTokenPolicy, mlog.New(io.Discard, &mlog.LevelVar{}))
+
+	request := dto.UserChangePasswordRequest{OldPassword: "salah", NewPassword: "Teh-Sore-2024"}
+	apiErr := service.ChangePassword(context.Background(), &mjwt.CustomClaim{Identity: "BUDI"}, request, "127.0.0.1")
+	if apiErr == nil || apiErr.Status() != http.StatusBadRequest {
+		t.Fatalf("err = %v, want 400", apiErr)
+	}
+	if guard.failures != 1 {
+		t.Fatalf("password lama salah tercatat %d kali, want 1", guard.failures)
+	}
+}
@@ -0,0 +1,68 @@
+package mcrypt
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	HasherBcrypt = "bcrypt"
+
+	// defaultBcryptCost sebelumnya bcrypt.MinCost (4), hash lama tersebut diperbarui ketika login
+	defaultBcryptCost = 12
+	minBcryptCost     = 10
+	maxBcryptCost     = bcrypt.MaxCost
+
+	// bcryptMaxPasswordBytes bcrypt mengabaikan byte setelah 72 byte pertama
+	bcryptMaxPasswordBytes = 72
+)
+
+var errBcryptPasswordTooLong = errors.New("password melebihi 72 byte, bcrypt akan memotongnya")
+
+type bcryptHasher struct {
+	cost int
+}
+
+func newBcryptHasher(cost int) *bcryptHasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (b *bcryptHasher) Name() string {
+	return HasherBcrypt
+}
+
+// Identify hash bcrypt berawalan $2a$, $2b$ atau $2y$
+func (b *bcryptHasher) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Hash menolak password lebih dari 72 byte agar tidak terpotong diam-diam,
+// batas ini juga diterapkan pada policy password (lihat MaxPasswordBytes)
+func (b *bcryptHasher) Hash(password string) (string, error) {
+	if len(password) > bcryptMaxPasswordBytes {
+		return "", errBcryptPasswordTooLong
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(passwordHash), nil
+}
+
+func (b *bcryptHasher) Verify(password string, hash string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+func (b *bcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return cost != b.cost
+}
+
+func (b *bcryptHasher) MaxPasswordBytes() int {
+	return bcryptMaxPasswordBytes
+}
@@ -1,35 +1,156 @@
 package mcrypt
 
 import (
+	"fmt"
 	"github.com/muchlist/sagasql/utils/rest_err"
-	"golang.org/x/crypto/bcrypt"
+	"os"
+	"strconv"
 )
 
-func NewCrypto() BcryptAssumer {
+const (
+	hasherKey       = "PASSWORD_HASHER"
+	bcryptCostKey   = "BCRYPT_COST"
+	argon2MemoryKey = "ARGON2_MEMORY"
+	argon2TimeKey   = "ARGON2_TIME"
+	argon2ThreadKey = "ARGON2_THREADS"
+)
+
+// Hasher satu algoritma hash password. setiap hash memiliki prefix format (misal $argon2id$ atau $2a$)
+// sehingga hash lama tetap dapat diverifikasi walaupun algoritma default sudah diganti
+type Hasher interface {
+	// Name nama algoritma yang digunakan pada env PASSWORD_HASHER
+	Name() string
+	// Identify true jika hash dibuat oleh hasher ini (berdasarkan prefix)
+	Identify(hash string) bool
+	Hash(password string) (string, error)
+	Verify(password string, hash string) bool
+	// Outdated true jika hash dibuat dengan parameter yang berbeda dari konfigurasi saat ini
+	Outdated(hash string) bool
+	// MaxPasswordBytes panjang password maksimal (byte) yang diproses utuh oleh Hash, 0 jika tidak dibatasi
+	MaxPasswordBytes() int
+}
+
+// registry daftar hasher yang dikenali, hasher pertama adalah default untuk hash baru
+var registry = []Hasher{
+	newArgon2idHasher(defaultArgon2Params),
+	newBcryptHasher(defaultBcryptCost),
+}
+
+// Init membaca konfigurasi hasher dari env, harus dipanggil setelah env diload.
+// PASSWORD_HASHER memilih algoritma untuk hash baru (argon2id atau bcrypt, default argon2id)
+func Init() error {
+	bcryptCost, err := envInt(bcryptCostKey, defaultBcryptCost)
+	if err != nil {
+		return err
+	}
+	if bcryptCost < minBcryptCost || bcryptCost > maxBcryptCost {
+		return fmt.Errorf("%s harus di antara %d dan %d", bcryptCostKey, minBcryptCost, maxBcryptCost)
+	}
+
+	params := defaultArgon2Params
+	memory, err := envInt(argon2MemoryKey, int(params.Memory))
+	if err != nil {
+		return err
+	}
+	iterations, err := envInt(argon2TimeKey, int(params.Iterations))
+	if err != nil {
+		return err
+	}
+	threads, err := envInt(argon2ThreadKey, int(params.Threads))
+	if err != nil {
+		return err
+	}
+	if memory < 8*threads || iterations < 1 || threads < 1 || threads > 255 {
+		return fmt.Errorf("parameter argon2 tidak valid, %s=%d %s=%d %s=%d", argon2MemoryKey, memory, argon2TimeKey, iterations, argon2ThreadKey, threads)
+	}
+	params.Memory = uint32(memory)
+	params.Iterations = uint32(iterations)
+	params.Threads = uint8(threads)
+
+	hashers := []Hasher{newArgon2idHasher(params), newBcryptHasher(bcryptCost)}
+
+	name := os.Getenv(hasherKey)
+	if name == "" {
+		name = HasherArgon2id
+	}
+	for i, h := range hashers {
+		if h.Name() == name {
+			// hasher default dipindah ke urutan pertama
+			hashers[0], hashers[i] = hashers[i], hashers[0]
+			registry = hashers
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s tidak didukung, gunakan %s atau %s", hasherKey, name, HasherArgon2id, HasherBcrypt)
+}
+
+// MaxPasswordBytes batas panjang password (byte) hasher default, 0 jika tidak dibatasi.
+// digunakan untuk mengisi mpassword.Policy.MaxBytes
+func MaxPasswordBytes() int {
+	return registry[0].MaxPasswordBytes()
+}
+
+func envInt(key string, fallback int) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s harus berupa angka", key)
+	}
+	return value, nil
+}
+
+func NewCrypto() PasswordHasherAssumer {
 	return &cryptoObj{}
 }
 
-type BcryptAssumer interface {
+type PasswordHasherAssumer interface {
 	GenerateHash(password string) (string, rest_err.APIError)
 	IsPWAndHashPWMatch(password string, hashPass string) bool
+	NeedsRehash(hashPass string) bool
 }
 
 type cryptoObj struct {
 }
 
-// GenerateHash membuat hashpassword, hash password 1 dengan yang lainnya akan berbeda meskipun
+// GenerateHash membuat hashpassword dengan hasher default, hash password 1 dengan yang lainnya akan berbeda meskipun
 // inputannya sama, sehingga untuk membandingkan hashpassword memerlukan method lain IsPWAndHashPWMatch
 func (c *cryptoObj) GenerateHash(password string) (string, rest_err.APIError) {
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	passwordHash, err := registry[0].Hash(password)
 	if err != nil {
-		restErr := rest_err.NewInternalServerError("Crypto error", err)
+		restErr := rest_err.NewInternalServerError("crypto.error", err)
 		return "", restErr
 	}
-	return string(passwordHash), nil
+	return passwordHash, nil
 }
 
-// IsPWAndHashPWMatch return true jika inputan password dan hashpassword sesuai
+// IsPWAndHashPWMatch return true jika inputan password dan hashpassword sesuai,
+// hasher dipilih berdasarkan prefix hash
 func (c *cryptoObj) IsPWAndHashPWMatch(password string, hashPass string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashPass), []byte(password))
-	return err == nil
+	h := identify(hashPass)
+	if h == nil {
+		return false
+	}
+	return h.Verify(password, hashPass)
+}
+
+// NeedsRehash true jika hash dibuat dengan algoritma selain default atau parameter lama,
+// dipanggil setelah password terverifikasi agar hash dapat diperbarui
+func (c *cryptoObj) NeedsRehash(hashPass string) bool {
+	h := identify(hashPass)
+	if h == nil {
+		return false
+	}
+	return h != registry[0] || h.Outdated(hashPass)
+}
+
+func identify(hash string) Hasher {
+	for _, h := range registry {
+		if h.Identify(hash) {
+			return h
+		}
+	}
+	return nil
 }
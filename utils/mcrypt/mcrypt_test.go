@@ -0,0 +1,194 @@
+package mcrypt
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2Params parameter kecil agar test tetap cepat
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+// initHasher menjalankan Init dengan hasher default name dan parameter argon2 kecil
+func initHasher(t *testing.T, name string, argon2Time string) {
+	t.Helper()
+	previous := registry
+	t.Cleanup(func() { registry = previous })
+
+	t.Setenv(hasherKey, name)
+	t.Setenv(bcryptCostKey, "10")
+	t.Setenv(argon2MemoryKey, "64")
+	t.Setenv(argon2TimeKey, argon2Time)
+	t.Setenv(argon2ThreadKey, "1")
+	if err := Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := newArgon2idHasher(testArgon2Params)
+
+	hash, err := h.Hash("kata-sandi-rahasia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") || !h.Identify(hash) {
+		t.Fatalf("hash bukan format PHC argon2id: %s", hash)
+	}
+	if !h.Verify("kata-sandi-rahasia", hash) {
+		t.Fatal("password yang benar seharusnya cocok")
+	}
+	if h.Verify("kata-sandi-lain", hash) {
+		t.Fatal("password yang salah seharusnya tidak cocok")
+	}
+
+	other, err := h.Hash("kata-sandi-rahasia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Fatal("salt seharusnya membuat hash berbeda untuk password yang sama")
+	}
+}
+
+func TestArgon2idOutdated(t *testing.T) {
+	hash, err := newArgon2idHasher(testArgon2Params).Hash("kata-sandi-rahasia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newArgon2idHasher(testArgon2Params).Outdated(hash) {
+		t.Fatal("hash dengan parameter yang sama seharusnya tidak outdated")
+	}
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	if !newArgon2idHasher(stronger).Outdated(hash) {
+		t.Fatal("hash dengan parameter lama seharusnya outdated")
+	}
+	// parameter dibaca dari hash sehingga hash lama tetap dapat diverifikasi
+	if !newArgon2idHasher(stronger).Verify("kata-sandi-rahasia", hash) {
+		t.Fatal("hash dengan parameter lama seharusnya tetap cocok")
+	}
+}
+
+func TestArgon2idRejectsMalformedHash(t *testing.T) {
+	h := newArgon2idHasher(testArgon2Params)
+	hash, err := h.Hash("kata-sandi-rahasia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+
+	tests := map[string]string{
+		"kosong":          "",
+		"bagian kurang":   strings.Join(parts[:5], "$"),
+		"versi lain":      strings.Replace(hash, "v=19", "v=16", 1),
+		"parameter rusak": strings.Replace(hash, "m=64,t=1,p=1", "m=x,t=1,p=1", 1),
+		"salt bukan b64":  strings.Join([]string{"", parts[1], parts[2], parts[3], "!!!", parts[5]}, "$"),
+		"key bukan b64":   strings.Join([]string{"", parts[1], parts[2], parts[3], parts[4], "!!!"}, "$"),
+		"algoritma lain":  strings.Replace(hash, "$argon2id$", "$argon2i$", 1),
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if h.Verify("kata-sandi-rahasia", input) {
+				t.Fatal("hash rusak seharusnya tidak cocok")
+			}
+		})
+	}
+}
+
+func TestBcryptPasswordLimit(t *testing.T) {
+	h := newBcryptHasher(minBcryptCost)
+
+	if _, err := h.Hash(strings.Repeat("a", bcryptMaxPasswordBytes+1)); err != errBcryptPasswordTooLong {
+		t.Fatalf("err = %v, want %v", err, errBcryptPasswordTooLong)
+	}
+	// batas dihitung dalam byte, bukan jumlah karakter
+	if _, err := h.Hash(strings.Repeat("é", bcryptMaxPasswordBytes/2+1)); err != errBcryptPasswordTooLong {
+		t.Fatalf("err = %v, want %v", err, errBcryptPasswordTooLong)
+	}
+
+	maxPassword := strings.Repeat("a", bcryptMaxPasswordBytes)
+	hash, err := h.Hash(maxPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Identify(hash) || !h.Verify(maxPassword, hash) {
+		t.Fatal("password 72 byte seharusnya dapat di-hash dan diverifikasi")
+	}
+}
+
+func TestMaxPasswordBytesFollowsDefaultHasher(t *testing.T) {
+	initHasher(t, HasherBcrypt, "1")
+	if got := MaxPasswordBytes(); got != bcryptMaxPasswordBytes {
+		t.Fatalf("MaxPasswordBytes bcrypt = %d, want %d", got, bcryptMaxPasswordBytes)
+	}
+	if _, apiErr := NewCrypto().GenerateHash(strings.Repeat("a", bcryptMaxPasswordBytes+1)); apiErr == nil {
+		t.Fatal("password lebih dari 72 byte seharusnya ditolak")
+	}
+
+	initHasher(t, HasherArgon2id, "1")
+	if got := MaxPasswordBytes(); got != 0 {
+		t.Fatalf("MaxPasswordBytes argon2id = %d, want 0", got)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	initHasher(t, HasherBcrypt, "1")
+	crypto := NewCrypto()
+	bcryptHash, apiErr := crypto.GenerateHash("kata-sandi-rahasia")
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if crypto.NeedsRehash(bcryptHash) {
+		t.Fatal("hash bcrypt seharusnya tidak perlu diperbarui selama bcrypt menjadi default")
+	}
+
+	initHasher(t, HasherArgon2id, "1")
+	if !crypto.IsPWAndHashPWMatch("kata-sandi-rahasia", bcryptHash) {
+		t.Fatal("hash bcrypt lama seharusnya tetap dapat diverifikasi")
+	}
+	if !crypto.NeedsRehash(bcryptHash) {
+		t.Fatal("hash bcrypt seharusnya diperbarui ketika default argon2id")
+	}
+	argonHash, apiErr := crypto.GenerateHash("kata-sandi-rahasia")
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if crypto.NeedsRehash(argonHash) {
+		t.Fatal("hash argon2id dengan parameter saat ini seharusnya tidak perlu diperbarui")
+	}
+
+	initHasher(t, HasherArgon2id, "2")
+	if !crypto.NeedsRehash(argonHash) {
+		t.Fatal("hash argon2id dengan parameter lama seharusnya diperbarui")
+	}
+	if crypto.NeedsRehash("bukan-hash") || crypto.IsPWAndHashPWMatch("kata-sandi-rahasia", "bukan-hash") {
+		t.Fatal("hash yang tidak dikenal seharusnya diabaikan")
+	}
+}
+
+func TestInitRejectsInvalidConfig(t *testing.T) {
+	previous := registry
+	t.Cleanup(func() { registry = previous })
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"hasher tidak dikenal", hasherKey, "md5"},
+		{"bcrypt cost terlalu kecil", bcryptCostKey, "4"},
+		{"bcrypt cost bukan angka", bcryptCostKey, "dua belas"},
+		{"memory argon2 terlalu kecil", argon2MemoryKey, "4"},
+		{"iterasi argon2 nol", argon2TimeKey, "0"},
+		{"thread argon2 melebihi batas", argon2ThreadKey, "256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if err := Init(); err == nil {
+				t.Fatalf("%s=%s seharusnya ditolak", tt.key, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package mi18n
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Bahasa yang memiliki catalog
+const (
+	ID = "id"
+	EN = "en"
+)
+
+// DefaultLang bahasa terakhir pada fallback chain, seluruh key wajib tersedia pada catalog bahasa ini
+const DefaultLang = ID
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// catalogs pesan per bahasa, dimuat dari locales/<bahasa>.json ketika aplikasi dijalankan
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	files, err := localesFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	result := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		raw, err := localesFS.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(raw, &catalog); err != nil {
+			panic(fmt.Sprintf("catalog %s tidak valid: %v", file.Name(), err))
+		}
+		result[strings.TrimSuffix(file.Name(), ".json")] = catalog
+	}
+	return result
+}
+
+// Supported mengembalikan bahasa catalog untuk lang (contoh en-US menjadi en), string kosong jika tidak didukung
+func Supported(lang string) string {
+	lang = Normalize(lang)
+	if _, ok := catalogs[lang]; ok {
+		return lang
+	}
+	if _, ok := catalogs[base(lang)]; ok {
+		return base(lang)
+	}
+	return ""
+}
+
+// Has true jika key tersedia pada catalog bahasa default
+func Has(key string) bool {
+	_, ok := catalogs[DefaultLang][key]
+	return ok
+}
+
+// Translate pesan key dalam bahasa lang dengan argumen format fmt.
+// fallback chain : lang (en-us), bahasa dasarnya (en), DefaultLang, lalu key itu sendiri
+// sehingga pesan literal yang bukan key tetap dapat digunakan
+func Translate(lang string, key string, args ...interface{}) string {
+	message := lookup(lang, key)
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// TranslateParams seperti Translate namun pesan berupa text/template dengan params bernama,
+// mengikuti format pesan ozzo-validation (contoh {{.min}})
+func TranslateParams(lang string, key string, params map[string]interface{}) string {
+	message := lookup(lang, key)
+	if len(params) == 0 || !strings.Contains(message, "{{") {
+		return message
+	}
+	tmpl, err := template.New("").Parse(message)
+	if err != nil {
+		return message
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return message
+	}
+	return buf.String()
+}
+
+func lookup(lang string, key string) string {
+	for _, candidate := range fallback(lang) {
+		if message, ok := catalogs[candidate][key]; ok {
+			return message
+		}
+	}
+	return key
+}
+
+// fallback urutan bahasa yang dicoba untuk lang
+func fallback(lang string) []string {
+	lang = Normalize(lang)
+	chain := make([]string, 0, 3)
+	if lang != "" {
+		chain = append(chain, lang)
+		if b := base(lang); b != lang {
+			chain = append(chain, b)
+		}
+	}
+	return append(chain, DefaultLang)
+}
+
+// Normalize menyeragamkan tag bahasa, contoh en_US menjadi en-us
+func Normalize(lang string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+}
+
+func base(lang string) string {
+	if i := strings.Index(lang, "-"); i > 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+// Negotiate memilih bahasa yang didukung dari header Accept-Language berdasarkan nilai q,
+// mengembalikan string kosong jika tidak ada yang didukung
+func Negotiate(acceptLanguage string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		lang := Normalize(fields[0])
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			langs = append(langs, weighted{lang: lang, q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+
+	for _, l := range langs {
+		if lang := Supported(l.lang); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+type langKey struct{}
+
+// ContextWithLang menyimpan bahasa yang dipilih untuk request kedalam ctx
+func ContextWithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// LangFromContext bahasa di dalam ctx, DefaultLang jika belum dipilih
+func LangFromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(langKey{}).(string); ok && lang != "" {
+		return lang
+	}
+	return DefaultLang
+}
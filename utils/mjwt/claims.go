@@ -1,21 +1,49 @@
 package mjwt
 
-import (
-	"time"
-)
-
 // Enum untuk tipe jwt
+// MFAPending diterbitkan setelah password cocok pada user yang menggunakan 2FA,
+// hanya dapat ditukar dengan access dan refresh token melalui verifikasi kode TOTP
 const (
 	Access int = iota
 	Refresh
+	MFAPending
 )
 
+// CustomClaim isi token, Exp wajib diisi sebelum GenerateToken (lihat TokenPolicy.ExpiresAt)
 type CustomClaim struct {
-	Identity    string
-	Name        string
-	Exp         int64
-	ExtraMinute time.Duration
-	Type        int
-	Fresh       bool
-	Roles       string
+	TokenID  string
+	FamilyID string
+	Identity string
+	Name     string
+	Exp      int64
+	// IssuedAtMs waktu terbit token dalam milidetik, token lama tanpa claim iat_ms menggunakan iat * 1000
+	IssuedAtMs int64
+	Type       int
+	Fresh      bool
+	Roles      []string
+	// MustChangePassword token hanya dapat digunakan pada route FreshAuth sampai password diganti
+	MustChangePassword bool
+	// EmailUnverified email user belum diverifikasi, route Require hanya dapat diakses dengan GET
+	EmailUnverified bool
+	// APIKeyID dan Scopes hanya terisi jika request menggunakan api key (tidak ada di dalam jwt).
+	// permission api key dibatasi pada Scopes selain permission dari role pemiliknya
+	APIKeyID string
+	Scopes   []string
+	// OrgID organisasi aktif, data yang dimiliki organisasi (product) dibatasi pada organisasi ini
+	OrgID string
+	// OrgRoles role user di dalam OrgID, diisi oleh middleware dari database (tidak ada di dalam jwt)
+	// sehingga perubahan keanggotaan berlaku tanpa login ulang
+	OrgRoles []string
+	// Language preferensi bahasa pesan response milik user, kosong jika mengikuti Accept-Language
+	Language string
+}
+
+// EffectiveRoles gabungan role global dan role di organisasi aktif, digunakan untuk mencari permission
+func (c *CustomClaim) EffectiveRoles() []string {
+	if len(c.OrgRoles) == 0 {
+		return c.Roles
+	}
+	roles := make([]string, 0, len(c.Roles)+len(c.OrgRoles))
+	roles = append(roles, c.Roles...)
+	return append(roles, c.OrgRoles...)
 }
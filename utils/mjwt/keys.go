@@ -0,0 +1,285 @@
+package mjwt
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Algoritma tanda tangan yang didukung
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// Key kunci asimetris yang digunakan untuk menandatangani dan memverifikasi token.
+// kunci dengan ActivatesAt terbaru yang sudah aktif dipakai untuk menandatangani,
+// kunci lain tetap dapat memverifikasi sampai RetiresAt (0 berarti belum dijadwalkan pensiun)
+type Key struct {
+	KID         string
+	Algorithm   string
+	Private     crypto.Signer
+	ActivatesAt int64
+	RetiresAt   int64
+}
+
+func (k Key) usableAt(now int64) bool {
+	return k.RetiresAt == 0 || now < k.RetiresAt
+}
+
+type keyRing struct {
+	mu   sync.RWMutex
+	keys []Key
+}
+
+var keys = &keyRing{}
+
+// SetKeys mengganti seluruh kunci asimetris yang dikenal, dipanggil oleh proses rotasi kunci
+func SetKeys(newKeys []Key) {
+	sorted := make([]Key, len(newKeys))
+	copy(sorted, newKeys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ActivatesAt > sorted[j].ActivatesAt
+	})
+
+	keys.mu.Lock()
+	keys.keys = sorted
+	keys.mu.Unlock()
+}
+
+// signingKey mengembalikan kunci aktif terbaru untuk algoritma yang dikonfigurasi
+func (r *keyRing) signingKey(now int64) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.Algorithm == algorithm && key.ActivatesAt <= now && key.usableAt(now) {
+			k := key
+			return &k, nil
+		}
+	}
+	return nil, fmt.Errorf("tidak ada kunci %s yang aktif", algorithm)
+}
+
+func (r *keyRing) find(kid string, now int64) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.KID == kid && key.usableAt(now) {
+			k := key
+			return &k, nil
+		}
+	}
+	return nil, fmt.Errorf("kid %s tidak dikenal", kid)
+}
+
+func (r *keyRing) published(now int64) []Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []Key
+	for _, key := range r.keys {
+		if key.usableAt(now) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case AlgHS256:
+		return jwt.SigningMethodHS256, nil
+	case AlgRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("algoritma jwt %s tidak didukung, gunakan %s, %s atau %s", alg, AlgHS256, AlgRS256, AlgEdDSA)
+}
+
+// NewKey membuat kunci privat baru untuk algoritma asimetris yang dikonfigurasi
+func NewKey(activatesAt time.Time) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("algoritma %s tidak menggunakan kunci asimetris", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		KID:         kid,
+		Algorithm:   algorithm,
+		Private:     private,
+		ActivatesAt: activatesAt.Unix(),
+	}, nil
+}
+
+// SealPrivateKey mengenkripsi kunci privat (PKCS8 PEM) dengan AES-GCM
+// menggunakan turunan SECRET_KEY agar aman disimpan di database
+func SealPrivateKey(private crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	plain := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return seal(jwtKeyPurpose, plain)
+}
+
+// OpenPrivateKey kebalikan dari SealPrivateKey
+func OpenPrivateKey(sealed string) (crypto.Signer, error) {
+	plain, err := open(jwtKeyPurpose, sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(plain)
+	if block == nil {
+		return nil, errors.New("format pem kunci tidak valid")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("tipe kunci tidak didukung")
+	}
+	return signer, nil
+}
+
+// Purpose enkripsi data sensitif yang disimpan di database, setiap purpose memakai kunci turunan SECRET_KEY yang berbeda
+const (
+	jwtKeyPurpose     = "jwt-key"
+	PurposeTOTPSecret = "totp-secret"
+)
+
+// SealSecret mengenkripsi secret (contoh secret TOTP) dengan AES-GCM seperti SealPrivateKey
+func SealSecret(purpose string, plain string) (string, error) {
+	return seal(purpose, []byte(plain))
+}
+
+// OpenSecret kebalikan dari SealSecret, gagal jika purpose atau SECRET_KEY berbeda
+func OpenSecret(purpose string, sealed string) (string, error) {
+	plain, err := open(purpose, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func seal(purpose string, plain []byte) (string, error) {
+	aead, err := encryption(purpose)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plain, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(purpose string, sealed string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := encryption(purpose)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < aead.NonceSize() {
+		return nil, errors.New("data terenkripsi tidak valid")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("data tidak dapat didekripsi, pastikan SECRET_KEY sama: %w", err)
+	}
+	return plain, nil
+}
+
+func encryption(purpose string) (cipher.AEAD, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret key belum diinisiasi")
+	}
+	derived := sha256.Sum256(append([]byte("sagasql-"+purpose+":"), secret...))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// JWK satu public key dalam format RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet isi dari /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS mengembalikan public key yang masih dapat memverifikasi token,
+// termasuk kunci berikutnya yang sudah dipublikasi tetapi belum aktif.
+// pada mode HS256 hasilnya kosong karena secret tidak boleh dipublikasi
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range keys.published(time.Now().Unix()) {
+		jwk := JWK{
+			Use: "sig",
+			Alg: key.Algorithm,
+			Kid: key.KID,
+		}
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -1,39 +1,99 @@
 package mjwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/muchlist/sagasql/utils/rest_err"
-	"log"
 	"net/http"
 	"os"
 	"time"
 )
 
 const (
-	CLAIMS    = "claims"
-	secretKey = "SECRET_KEY"
-
-	identityKey  = "identity"
-	nameKey      = "name"
-	rolesKey     = "roles"
-	tokenTypeKey = "type"
-	expKey       = "exp"
-	freshKey     = "fresh"
+	CLAIMS       = "claims"
+	secretKey    = "SECRET_KEY"
+	algorithmKey = "JWT_ALG"
+	// legacyHMACUntilKey batas waktu (RFC3339) token HS256 lama masih diterima pada mode asimetris
+	legacyHMACUntilKey = "JWT_LEGACY_HS256_UNTIL"
+
+	identityKey   = "identity"
+	nameKey       = "name"
+	rolesKey      = "roles"
+	tokenTypeKey  = "type"
+	expKey        = "exp"
+	freshKey      = "fresh"
+	jtiKey        = "jti"
+	iatKey        = "iat"
+	iatMsKey      = "iat_ms"
+	familyKey     = "fid"
+	mustChangeKey = "mcp"
+	unverifiedKey = "evu"
+	orgKey        = "org"
+	languageKey   = "lang"
 )
 
 var (
-	secret []byte
+	secret    []byte
+	algorithm string
+	// legacyHMACUntil unix time batas penerimaan token HS256 pada mode asimetris, 0 berarti selalu ditolak
+	legacyHMACUntil int64
 )
 
 func NewJwt() JWTAssumer {
 	return &jwtUtils{}
 }
 
-func Init() {
+// Init membaca secret key dan algoritma dari env, harus dipanggil setelah env diload.
+// secret tetap wajib pada mode asimetris karena digunakan untuk mengenkripsi kunci privat.
+// token HS256 yang diterbitkan sebelum migrasi hanya diterima jika JWT_LEGACY_HS256_UNTIL diisi,
+// batas tersebut tidak boleh melebihi maxTokenLifetime dari sekarang (umur token terpanjang, lihat TokenPolicy.MaxLifetime)
+func Init(maxTokenLifetime time.Duration) error {
 	secret = []byte(os.Getenv(secretKey))
 	if string(secret) == "" {
-		log.Fatal("Secret key tidak boleh kosong, ENV : SECRET_KEY")
+		return errors.New("secret key tidak boleh kosong, ENV : SECRET_KEY")
+	}
+
+	algorithm = os.Getenv(algorithmKey)
+	if algorithm == "" {
+		algorithm = AlgHS256
+	}
+	if _, err := signingMethod(algorithm); err != nil {
+		return err
+	}
+
+	legacyHMACUntil = 0
+	if until := os.Getenv(legacyHMACUntilKey); until != "" && IsAsymmetric() {
+		cutoff, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return fmt.Errorf("%s harus berformat RFC3339: %w", legacyHMACUntilKey, err)
+		}
+		if latest := time.Now().Add(maxTokenLifetime); cutoff.After(latest) {
+			return fmt.Errorf("%s paling lambat %s (umur token terpanjang)", legacyHMACUntilKey, latest.Format(time.RFC3339))
+		}
+		legacyHMACUntil = cutoff.Unix()
+	}
+	return nil
+}
+
+// acceptsHMAC true jika token HS256 dapat diverifikasi dengan secret pada waktu now
+func acceptsHMAC(now int64) bool {
+	if !IsAsymmetric() {
+		return true
 	}
+	return now < legacyHMACUntil
+}
+
+// Algorithm algoritma tanda tangan yang dikonfigurasi melalui env JWT_ALG
+func Algorithm() string {
+	return algorithm
+}
+
+// IsAsymmetric true jika token ditandatangani dengan RS256 atau EdDSA
+func IsAsymmetric() bool {
+	return algorithm != AlgHS256
 }
 
 type JWTAssumer interface {
@@ -48,21 +108,71 @@ type jwtUtils struct {
 // GenerateToken membuat token jwt untuk login header, untuk menguji nilai payloadnya
 // dapat menggunakan situs jwt.io
 func (j *jwtUtils) GenerateToken(claims CustomClaim) (string, rest_err.APIError) {
-	expired := time.Now().Add(time.Minute * claims.ExtraMinute).Unix()
+	now := time.Now()
+	if claims.Exp == 0 {
+		return "", rest_err.NewInternalServerError("token.generate_failed", errors.New("claim exp belum ditentukan"))
+	}
+
+	// jti dapat ditentukan pemanggil jika perlu dicatat sebelum token dikirim
+	tokenID := claims.TokenID
+	if tokenID == "" {
+		var err error
+		tokenID, err = NewTokenID()
+		if err != nil {
+			return "", rest_err.NewInternalServerError("token.id_failed", err)
+		}
+	}
 
 	jwtClaim := jwt.MapClaims{}
+	jwtClaim[jtiKey] = tokenID
+	jwtClaim[iatKey] = now.Unix()
+	// iat standar berupa detik, presisi milidetik dibutuhkan pengecekan pencabutan token
+	jwtClaim[iatMsKey] = now.UnixNano() / int64(time.Millisecond)
 	jwtClaim[identityKey] = claims.Identity
 	jwtClaim[nameKey] = claims.Name
 	jwtClaim[rolesKey] = claims.Roles
-	jwtClaim[expKey] = expired
+	jwtClaim[expKey] = claims.Exp
 	jwtClaim[tokenTypeKey] = claims.Type
 	jwtClaim[freshKey] = claims.Fresh
+	if claims.FamilyID != "" {
+		jwtClaim[familyKey] = claims.FamilyID
+	}
+	if claims.MustChangePassword {
+		jwtClaim[mustChangeKey] = true
+	}
+	if claims.EmailUnverified {
+		jwtClaim[unverifiedKey] = true
+	}
+	if claims.OrgID != "" {
+		jwtClaim[orgKey] = claims.OrgID
+	}
+	if claims.Language != "" {
+		jwtClaim[languageKey] = claims.Language
+	}
+
+	if !IsAsymmetric() {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaim)
+		signedToken, err := token.SignedString(secret)
+		if err != nil {
+			return "", rest_err.NewInternalServerError("token.sign_failed", err)
+		}
+		return signedToken, nil
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaim)
+	key, err := keys.signingKey(now.Unix())
+	if err != nil {
+		return "", rest_err.NewInternalServerError("token.sign_failed", err)
+	}
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return "", rest_err.NewInternalServerError("token.sign_failed", err)
+	}
 
-	signedToken, err := token.SignedString(secret)
+	token := jwt.NewWithClaims(method, jwtClaim)
+	token.Header["kid"] = key.KID
+	signedToken, err := token.SignedString(key.Private)
 	if err != nil {
-		return "", rest_err.NewInternalServerError("gagal menandatangani token", err)
+		return "", rest_err.NewInternalServerError("token.sign_failed", err)
 	}
 
 	return signedToken, nil
@@ -73,34 +183,101 @@ func (j *jwtUtils) GenerateToken(claims CustomClaim) (string, rest_err.APIError)
 func (j *jwtUtils) ReadToken(token *jwt.Token) (*CustomClaim, rest_err.APIError) {
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return nil, rest_err.NewInternalServerError("gagal mapping token", nil)
+		return nil, rest_err.NewInternalServerError("token.mapping_failed", nil)
 	}
 
 	customClaim := CustomClaim{
 		Identity: claims[identityKey].(string),
 		Name:     claims[nameKey].(string),
 		Exp:      int64(claims[expKey].(float64)),
-		Roles:    claims[rolesKey].(string),
+		Roles:    readRoles(claims[rolesKey]),
 		Type:     int(claims[tokenTypeKey].(float64)),
 		Fresh:    claims[freshKey].(bool),
 	}
 
+	// token yang diterbitkan sebelum adanya jti dan iat tetap dapat dibaca
+	if tokenID, ok := claims[jtiKey].(string); ok {
+		customClaim.TokenID = tokenID
+	}
+	if issuedAtMs, ok := claims[iatMsKey].(float64); ok {
+		customClaim.IssuedAtMs = int64(issuedAtMs)
+	} else if issuedAt, ok := claims[iatKey].(float64); ok {
+		customClaim.IssuedAtMs = int64(issuedAt) * 1000
+	}
+	if familyID, ok := claims[familyKey].(string); ok {
+		customClaim.FamilyID = familyID
+	}
+	if mustChange, ok := claims[mustChangeKey].(bool); ok {
+		customClaim.MustChangePassword = mustChange
+	}
+	if unverified, ok := claims[unverifiedKey].(bool); ok {
+		customClaim.EmailUnverified = unverified
+	}
+	if orgID, ok := claims[orgKey].(string); ok {
+		customClaim.OrgID = orgID
+	}
+	if language, ok := claims[languageKey].(string); ok {
+		customClaim.Language = language
+	}
+
 	return &customClaim, nil
 }
 
+// readRoles membaca claim roles berupa array, token lama berisi satu role berupa string
+func readRoles(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, role := range v {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	}
+	return []string{}
+}
+
+// NewTokenID membuat id acak untuk claim jti dan fid
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // ValidateToken memvalidasi apakah token string masukan valid, termasuk memvalidasi apabila field exp nya kadaluarsa
 func (j *jwtUtils) ValidateToken(tokenString string) (*jwt.Token, rest_err.APIError) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, rest_err.NewAPIError("Token signing method salah", http.StatusUnprocessableEntity, "jwt_error", nil)
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			// pada mode asimetris token HS256 tanpa kid hanya diterima sampai JWT_LEGACY_HS256_UNTIL
+			// agar token lama tetap berlaku sampai kadaluarsa setelah migrasi algoritma
+			if _, hasKid := token.Header["kid"]; hasKid || !acceptsHMAC(time.Now().Unix()) {
+				return nil, rest_err.NewAPIError("token.wrong_signing_method", http.StatusUnprocessableEntity, "jwt_error", nil)
+			}
+			return secret, nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+			kid, _ := token.Header["kid"].(string)
+			key, err := keys.find(kid, time.Now().Unix())
+			if err != nil {
+				return nil, err
+			}
+			// algoritma token harus sama dengan algoritma kunci (mencegah algorithm confusion)
+			if key.Algorithm != token.Method.Alg() {
+				return nil, rest_err.NewAPIError("token.wrong_signing_method", http.StatusUnprocessableEntity, "jwt_error", nil)
+			}
+			return key.Private.Public(), nil
 		}
-		return secret, nil
+		return nil, rest_err.NewAPIError("token.wrong_signing_method", http.StatusUnprocessableEntity, "jwt_error", nil)
 	})
 
 	// Jika expired akan muncul disini asalkan ada claims exp
 	if err != nil {
-		return nil, rest_err.NewAPIError("Token tidak valid", http.StatusUnprocessableEntity, "jwt_error", []interface{}{err.Error()})
+		return nil, rest_err.NewAPIError("token.invalid", http.StatusUnprocessableEntity, "jwt_error", []interface{}{err.Error()})
 	}
 
 	return token, nil
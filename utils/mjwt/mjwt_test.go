@@ -0,0 +1,205 @@
+package mjwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"testing"
+	"time"
+)
+
+// initJWT menjalankan Init dengan algoritma alg dan batas token HS256 lama until (kosong jika tidak ada)
+func initJWT(t *testing.T, alg string, until string) {
+	t.Helper()
+	t.Setenv(secretKey, "rahasia-untuk-test")
+	t.Setenv(algorithmKey, alg)
+	t.Setenv(legacyHMACUntilKey, until)
+	if err := Init(24 * time.Hour); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { SetKeys(nil) })
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		identityKey:  "BUDI",
+		nameKey:      "Budi",
+		rolesKey:     []string{"ADMIN"},
+		expKey:       time.Now().Add(time.Hour).Unix(),
+		tokenTypeKey: Access,
+		freshKey:     false,
+	}
+}
+
+func newRSAKey(t *testing.T, kid string) Key {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Key{KID: kid, Algorithm: AlgRS256, Private: private, ActivatesAt: time.Now().Add(-time.Hour).Unix()}
+}
+
+func newEdKey(t *testing.T, kid string) Key {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Key{KID: kid, Algorithm: AlgEdDSA, Private: private, ActivatesAt: time.Now().Add(-time.Hour).Unix()}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestGenerateAndReadToken(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgRS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			initJWT(t, alg, "")
+			if alg == AlgRS256 {
+				SetKeys([]Key{newRSAKey(t, "rsa-1")})
+			}
+			if alg == AlgEdDSA {
+				SetKeys([]Key{newEdKey(t, "ed-1")})
+			}
+
+			j := NewJwt()
+			before := time.Now().UnixNano() / int64(time.Millisecond)
+			signed, apiErr := j.GenerateToken(CustomClaim{Identity: "BUDI", Name: "Budi", Roles: []string{"ADMIN"}, Exp: time.Now().Add(time.Hour).Unix(), Type: Access})
+			if apiErr != nil {
+				t.Fatalf("GenerateToken: %v", apiErr)
+			}
+			token, apiErr := j.ValidateToken(signed)
+			if apiErr != nil {
+				t.Fatalf("ValidateToken: %v", apiErr)
+			}
+			claims, apiErr := j.ReadToken(token)
+			if apiErr != nil {
+				t.Fatalf("ReadToken: %v", apiErr)
+			}
+			if claims.Identity != "BUDI" || claims.TokenID == "" {
+				t.Fatalf("claims tidak sesuai: %+v", claims)
+			}
+			if claims.IssuedAtMs < before {
+				t.Fatalf("iat_ms %d lebih kecil dari waktu sebelum token dibuat %d", claims.IssuedAtMs, before)
+			}
+			if _, hasKid := token.Header["kid"]; hasKid != IsAsymmetric() {
+				t.Fatalf("header kid = %v, asimetris = %v", hasKid, IsAsymmetric())
+			}
+		})
+	}
+}
+
+func TestValidateTokenKid(t *testing.T) {
+	initJWT(t, AlgRS256, "")
+	active := newRSAKey(t, "aktif")
+	retired := newRSAKey(t, "pensiun")
+	retired.RetiresAt = time.Now().Add(-time.Minute).Unix()
+	SetKeys([]Key{active, retired})
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"kid dikenal", sign(t, jwt.SigningMethodRS256, "aktif", active.Private), true},
+		{"kid tidak dikenal", sign(t, jwt.SigningMethodRS256, "lain", active.Private), false},
+		{"tanpa kid", sign(t, jwt.SigningMethodRS256, "", active.Private), false},
+		{"kunci sudah pensiun", sign(t, jwt.SigningMethodRS256, "pensiun", retired.Private), false},
+		{"kid benar, kunci lain", sign(t, jwt.SigningMethodRS256, "aktif", newRSAKey(t, "palsu").Private), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, apiErr := NewJwt().ValidateToken(tt.token)
+			if (apiErr == nil) != tt.valid {
+				t.Fatalf("valid = %v, want %v (err %v)", apiErr == nil, tt.valid, apiErr)
+			}
+		})
+	}
+}
+
+func TestValidateTokenAlgorithmConfusion(t *testing.T) {
+	initJWT(t, AlgRS256, "")
+	rsaKey := newRSAKey(t, "rsa")
+	edKey := newEdKey(t, "ed")
+	SetKeys([]Key{rsaKey, edKey})
+
+	// public key rsa dalam bentuk pem yang dapat diketahui siapa saja melalui jwks
+	der, err := x509.MarshalPKIXPublicKey(rsaKey.Private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 dengan public key rsa sebagai secret", sign(t, jwt.SigningMethodHS256, "rsa", publicPEM)},
+		{"HS256 dengan SECRET_KEY dan kid", sign(t, jwt.SigningMethodHS256, "rsa", secret)},
+		{"EdDSA dengan kid kunci rsa", sign(t, jwt.SigningMethodEdDSA, "rsa", edKey.Private)},
+		{"RS256 dengan kid kunci ed25519", sign(t, jwt.SigningMethodRS256, "ed", rsaKey.Private)},
+		{"alg none", sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, apiErr := NewJwt().ValidateToken(tt.token); apiErr == nil {
+				t.Fatal("token seharusnya ditolak")
+			}
+		})
+	}
+}
+
+func TestValidateTokenLegacyHMAC(t *testing.T) {
+	t.Run("ditolak tanpa JWT_LEGACY_HS256_UNTIL", func(t *testing.T) {
+		initJWT(t, AlgEdDSA, "")
+		SetKeys([]Key{newEdKey(t, "ed")})
+		if _, apiErr := NewJwt().ValidateToken(sign(t, jwt.SigningMethodHS256, "", secret)); apiErr == nil {
+			t.Fatal("token HS256 seharusnya ditolak")
+		}
+	})
+
+	t.Run("diterima sebelum batas", func(t *testing.T) {
+		initJWT(t, AlgEdDSA, time.Now().Add(time.Hour).Format(time.RFC3339))
+		SetKeys([]Key{newEdKey(t, "ed")})
+		if _, apiErr := NewJwt().ValidateToken(sign(t, jwt.SigningMethodHS256, "", secret)); apiErr != nil {
+			t.Fatalf("token HS256 seharusnya diterima: %v", apiErr)
+		}
+	})
+
+	t.Run("ditolak setelah batas", func(t *testing.T) {
+		initJWT(t, AlgEdDSA, time.Now().Add(-time.Minute).Format(time.RFC3339))
+		SetKeys([]Key{newEdKey(t, "ed")})
+		if _, apiErr := NewJwt().ValidateToken(sign(t, jwt.SigningMethodHS256, "", secret)); apiErr == nil {
+			t.Fatal("token HS256 seharusnya ditolak")
+		}
+	})
+}
+
+func TestInitRejectsLegacyCutoffBeyondTokenLifetime(t *testing.T) {
+	t.Setenv(secretKey, "rahasia-untuk-test")
+	t.Setenv(algorithmKey, AlgRS256)
+
+	t.Setenv(legacyHMACUntilKey, time.Now().Add(48*time.Hour).Format(time.RFC3339))
+	if err := Init(24 * time.Hour); err == nil {
+		t.Fatal("batas lebih lama dari umur token seharusnya ditolak")
+	}
+
+	t.Setenv(legacyHMACUntilKey, "besok")
+	if err := Init(24 * time.Hour); err == nil {
+		t.Fatal("batas yang bukan RFC3339 seharusnya ditolak")
+	}
+}
@@ -0,0 +1,145 @@
+package mjwt
+
+import (
+	"time"
+)
+
+// Lifetime umur token untuk satu role
+// AccessFresh untuk access token hasil login (fresh), AccessRefreshed untuk access token hasil refresh
+type Lifetime struct {
+	AccessFresh     time.Duration
+	AccessRefreshed time.Duration
+	Refresh         time.Duration
+}
+
+// SessionLimit batas umur sesi (satu kali login).
+// Idle : sesi berakhir jika tidak melakukan refresh selama durasi ini, 0 berarti hanya dibatasi umur refresh token
+// Absolute : sesi berakhir setelah durasi ini sejak login walaupun selalu melakukan refresh, 0 berarti tidak dibatasi
+type SessionLimit struct {
+	Idle     time.Duration
+	Absolute time.Duration
+}
+
+// TokenPolicy kebijakan umur token, Roles menimpa Default untuk role tertentu.
+// jika user memiliki beberapa role yang diatur, umur terpendek yang digunakan.
+// MFAPending umur token antara login dan verifikasi kode 2FA, berlaku untuk semua role
+type TokenPolicy struct {
+	Default    Lifetime
+	Roles      map[string]Lifetime
+	Session    SessionLimit
+	MFAPending time.Duration
+}
+
+var DefaultTokenPolicy = TokenPolicy{
+	Default: Lifetime{
+		AccessFresh:     time.Hour,
+		AccessRefreshed: time.Hour,
+		Refresh:         10 * 24 * time.Hour,
+	},
+	Roles: map[string]Lifetime{},
+	Session: SessionLimit{
+		Idle:     0,
+		Absolute: 30 * 24 * time.Hour,
+	},
+	MFAPending: 5 * time.Minute,
+}
+
+// roleLifetime mengembalikan Lifetime untuk satu role, field yang kosong diisi dari Default
+func (p TokenPolicy) roleLifetime(role string) Lifetime {
+	l, ok := p.Roles[role]
+	if !ok {
+		return p.Default
+	}
+	if l.AccessFresh == 0 {
+		l.AccessFresh = p.Default.AccessFresh
+	}
+	if l.AccessRefreshed == 0 {
+		l.AccessRefreshed = p.Default.AccessRefreshed
+	}
+	if l.Refresh == 0 {
+		l.Refresh = p.Default.Refresh
+	}
+	return l
+}
+
+// lifetime menggabungkan Lifetime semua role yang diatur dengan mengambil umur terpendek
+func (p TokenPolicy) lifetime(roles []string) Lifetime {
+	var result *Lifetime
+	for _, role := range roles {
+		if _, ok := p.Roles[role]; !ok {
+			continue
+		}
+		l := p.roleLifetime(role)
+		if result == nil {
+			result = &l
+			continue
+		}
+		result.AccessFresh = minDuration(result.AccessFresh, l.AccessFresh)
+		result.AccessRefreshed = minDuration(result.AccessRefreshed, l.AccessRefreshed)
+		result.Refresh = minDuration(result.Refresh, l.Refresh)
+	}
+	if result == nil {
+		return p.Default
+	}
+	return *result
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// TTL umur token berdasarkan role, tipe token dan fresh
+func (p TokenPolicy) TTL(roles []string, tokenType int, fresh bool) time.Duration {
+	l := p.lifetime(roles)
+	switch {
+	case tokenType == MFAPending:
+		return p.MFAPending
+	case tokenType == Refresh:
+		if p.Session.Idle != 0 && p.Session.Idle < l.Refresh {
+			return p.Session.Idle
+		}
+		return l.Refresh
+	case fresh:
+		return l.AccessFresh
+	default:
+		return l.AccessRefreshed
+	}
+}
+
+// ExpiresAt menghitung claim exp untuk token yang diterbitkan pada now di dalam sesi yang dimulai pada sessionStart.
+// exp tidak pernah melewati batas absolut sesi
+func (p TokenPolicy) ExpiresAt(roles []string, tokenType int, fresh bool, now time.Time, sessionStart time.Time) int64 {
+	expiresAt := now.Add(p.TTL(roles, tokenType, fresh))
+	if p.Session.Absolute != 0 {
+		if sessionEnd := sessionStart.Add(p.Session.Absolute); sessionEnd.Before(expiresAt) {
+			expiresAt = sessionEnd
+		}
+	}
+	return expiresAt.Unix()
+}
+
+// SessionExpired true jika sesi yang dimulai pada sessionStart sudah melewati batas absolut
+func (p TokenPolicy) SessionExpired(sessionStart time.Time, now time.Time) bool {
+	return p.Session.Absolute != 0 && !now.Before(sessionStart.Add(p.Session.Absolute))
+}
+
+// MaxLifetime umur token terpanjang yang mungkin diterbitkan, digunakan untuk menentukan
+// berapa lama kunci lama harus tetap bisa memverifikasi token
+func (p TokenPolicy) MaxLifetime() time.Duration {
+	max := time.Duration(0)
+	lifetimes := []Lifetime{p.Default}
+	for role := range p.Roles {
+		lifetimes = append(lifetimes, p.roleLifetime(role))
+	}
+	for _, l := range lifetimes {
+		for _, d := range []time.Duration{l.AccessFresh, l.AccessRefreshed, l.Refresh} {
+			if d > max {
+				max = d
+			}
+		}
+	}
+	return max
+}
@@ -0,0 +1,68 @@
+package mjwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Purpose token bertanda tangan untuk tautan email, token satu purpose tidak dapat dipakai untuk purpose lain
+const (
+	PurposeEmailVerification = "email-verification"
+)
+
+var ErrInvalidSignedToken = errors.New("token tidak valid atau sudah kadaluarsa")
+
+type signedPayload struct {
+	Purpose string   `json:"p"`
+	Values  []string `json:"v"`
+	Exp     int64    `json:"exp"`
+}
+
+// SignLink membuat token bertanda tangan HMAC untuk tautan email (bukan jwt sehingga tidak dapat dipakai sebagai access token).
+// values ikut ditandatangani, contoh username dan email sehingga tautan tidak berlaku jika email diganti
+func SignLink(purpose string, values []string, expiresAt time.Time) (string, error) {
+	payload, err := json.Marshal(signedPayload{Purpose: purpose, Values: values, Exp: expiresAt.Unix()})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(linkSignature(encoded)), nil
+}
+
+// VerifyLink memeriksa tanda tangan, purpose dan kadaluarsa token lalu mengembalikan values nya
+func VerifyLink(purpose string, token string) ([]string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidSignedToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, linkSignature(parts[0])) {
+		return nil, ErrInvalidSignedToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidSignedToken
+	}
+	var payload signedPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, ErrInvalidSignedToken
+	}
+	if payload.Purpose != purpose || payload.Exp <= time.Now().Unix() {
+		return nil, ErrInvalidSignedToken
+	}
+	return payload.Values, nil
+}
+
+// linkSignature hmac dengan kunci turunan SECRET_KEY yang berbeda dari kunci jwt HS256
+func linkSignature(encodedPayload string) []byte {
+	key := sha256.Sum256(append([]byte("sagasql-signed-link:"), secret...))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(encodedPayload))
+	return mac.Sum(nil)
+}
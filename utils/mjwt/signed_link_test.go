@@ -0,0 +1,85 @@
+package mjwt
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedLinkRoundTrip(t *testing.T) {
+	initJWT(t, AlgHS256, "")
+
+	token, err := SignLink(PurposeEmailVerification, []string{"BUDI", "budi@example.com"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := VerifyLink(PurposeEmailVerification, token)
+	if err != nil {
+		t.Fatalf("VerifyLink: %v", err)
+	}
+	if len(values) != 2 || values[0] != "BUDI" || values[1] != "budi@example.com" {
+		t.Fatalf("values = %v", values)
+	}
+}
+
+func TestSignedLinkRejected(t *testing.T) {
+	initJWT(t, AlgHS256, "")
+
+	valid, err := SignLink(PurposeEmailVerification, []string{"BUDI", "budi@example.com"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := SignLink(PurposeEmailVerification, []string{"BUDI", "budi@example.com"}, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, ".")
+
+	// payload diganti dengan email lain namun tanda tangan tetap milik payload asli
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"p":"email-verification","v":["BUDI","penyerang@example.com"],"exp":9999999999}`))
+	// satu karakter tanda tangan diubah
+	signature := []byte(parts[1])
+	if signature[0] == 'A' {
+		signature[0] = 'B'
+	} else {
+		signature[0] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+	}{
+		{"kadaluarsa", PurposeEmailVerification, expired},
+		{"payload diubah", PurposeEmailVerification, forgedPayload + "." + parts[1]},
+		{"tanda tangan diubah", PurposeEmailVerification, parts[0] + "." + string(signature)},
+		{"tanpa tanda tangan", PurposeEmailVerification, parts[0]},
+		{"tanda tangan kosong", PurposeEmailVerification, parts[0] + "."},
+		{"purpose lain", "password-reset", valid},
+		{"bukan base64", PurposeEmailVerification, "!!!.???"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyLink(tt.purpose, tt.token); err != ErrInvalidSignedToken {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidSignedToken)
+			}
+		})
+	}
+}
+
+func TestSignedLinkRejectedWithOtherSecret(t *testing.T) {
+	initJWT(t, AlgHS256, "")
+	token, err := SignLink(PurposeEmailVerification, []string{"BUDI"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(secretKey, "secret-lain")
+	if err := Init(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyLink(PurposeEmailVerification, token); err != ErrInvalidSignedToken {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidSignedToken)
+	}
+}
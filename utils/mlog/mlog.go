@@ -0,0 +1,191 @@
+package mlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevelKey env untuk mengatur level log (debug, info, warn, error)
+const LogLevelKey = "LOG_LEVEL"
+
+// Level tingkat log, urutannya mengikuti slog
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+// ParseLevel membaca level dari string (debug, info, warn, error), default info
+func ParseLevel(level string) Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return LevelDebug
+	case "warn", "warning":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// LevelVar level yang dapat diubah saat runtime, digunakan bersama oleh beberapa logger.
+// nilai awalnya LevelInfo
+type LevelVar struct {
+	v int32
+}
+
+func (v *LevelVar) Level() Level {
+	return Level(atomic.LoadInt32(&v.v))
+}
+
+func (v *LevelVar) Set(level Level) {
+	atomic.StoreInt32(&v.v, int32(level))
+}
+
+// Field pasangan key value yang ditulis pada setiap baris log
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value.Milliseconds()}
+}
+
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err menambahkan field "error", nil akan diabaikan
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+type LoggerAssumer interface {
+	Debug(ctx context.Context, msg string, fields ...Field)
+	Info(ctx context.Context, msg string, fields ...Field)
+	Warn(ctx context.Context, msg string, fields ...Field)
+	Error(ctx context.Context, msg string, fields ...Field)
+	With(fields ...Field) LoggerAssumer
+}
+
+// New membuat logger json yang menulis ke w, baris dengan level dibawah level.Level() diabaikan
+func New(w io.Writer, level *LevelVar) LoggerAssumer {
+	return &logger{
+		out:   &lockedWriter{w: w},
+		level: level,
+	}
+}
+
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type logger struct {
+	out    *lockedWriter
+	level  *LevelVar
+	fields []Field
+}
+
+func (l *logger) Debug(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, LevelDebug, msg, fields)
+}
+
+func (l *logger) Info(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, LevelInfo, msg, fields)
+}
+
+func (l *logger) Warn(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, LevelWarn, msg, fields)
+}
+
+func (l *logger) Error(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, LevelError, msg, fields)
+}
+
+// With mengembalikan logger baru yang selalu menyertakan fields
+func (l *logger) With(fields ...Field) LoggerAssumer {
+	merged := make([]Field, 0, len(l.fields)+len(fields))
+	merged = append(merged, l.fields...)
+	merged = append(merged, fields...)
+	return &logger{
+		out:    l.out,
+		level:  l.level,
+		fields: merged,
+	}
+}
+
+// log menulis satu baris json. request_id dan trace_id diambil dari ctx jika tersedia
+func (l *logger) log(ctx context.Context, level Level, msg string, fields []Field) {
+	if level < l.level.Level() {
+		return
+	}
+
+	entry := make(map[string]interface{}, len(l.fields)+len(fields)+5)
+	entry["time"] = time.Now().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+	if ctx != nil {
+		if requestID := RequestIDFromContext(ctx); requestID != "" {
+			entry["request_id"] = requestID
+		}
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+			entry["trace_id"] = spanContext.TraceID().String()
+		}
+	}
+	for _, field := range l.fields {
+		entry[field.Key] = field.Value
+	}
+	for _, field := range fields {
+		entry[field.Key] = field.Value
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line = []byte(fmt.Sprintf(`{"level":"ERROR","msg":"gagal encode log","error":%q}`, err.Error()))
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	_, _ = l.out.w.Write(append(line, '\n'))
+}
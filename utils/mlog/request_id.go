@@ -0,0 +1,32 @@
+package mlog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type requestIDKey struct{}
+
+// HeaderRequestID header yang digunakan untuk menerima dan mengembalikan request id
+const HeaderRequestID = "X-Request-ID"
+
+// ContextWithRequestID menyimpan request id kedalam ctx
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext mengembalikan request id di dalam ctx, string kosong jika tidak ada
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// NewRequestID membuat request id acak 16 byte dalam bentuk hex
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,39 @@
+package mmail
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const fileDirKey = "MAIL_FILE_DIR"
+
+// fileDriver menyimpan setiap email sebagai file .eml, untuk pengujian lokal tanpa server smtp
+type fileDriver struct {
+	from string
+	dir  string
+}
+
+func newFileDriver(from string) (*fileDriver, error) {
+	dir := os.Getenv(fileDirKey)
+	if dir == "" {
+		dir = "./static/mail"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("folder email tidak dapat dibuat: %w", err)
+	}
+	return &fileDriver{from: from, dir: dir}, nil
+}
+
+func (f *fileDriver) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), recipient)
+	return ioutil.WriteFile(filepath.Join(f.dir, name), buildMessage(f.from, msg), 0o600)
+}
@@ -0,0 +1,106 @@
+package mmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/muchlist/sagasql/utils/mlog"
+	"os"
+)
+
+const (
+	// driverKey memilih pengirim email : smtp, file atau log (hanya pada mode development)
+	driverKey = "MAIL_DRIVER"
+	fromKey   = "MAIL_FROM"
+
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+	DriverLog  = "log"
+)
+
+// Message email teks biasa
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailerAssumer pengirim email, driver dapat diganti tanpa mengubah pemanggil
+type MailerAssumer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// driver diisi melalui Init setelah env diload
+var driver MailerAssumer
+
+func NewMailer() MailerAssumer {
+	return &mailer{}
+}
+
+type mailer struct {
+}
+
+// Send meneruskan email ke driver yang dipilih melalui env MAIL_DRIVER
+func (m *mailer) Send(ctx context.Context, msg Message) error {
+	if driver == nil {
+		return errors.New("mailer belum diinisiasi")
+	}
+	return driver.Send(ctx, msg)
+}
+
+// Init memilih driver berdasarkan env, harus dipanggil setelah env diload.
+// driver log menulis token reset dan verifikasi ke log sehingga hanya diizinkan jika development true,
+// MAIL_DRIVER kosong di luar development ditolak agar aplikasi tidak diam-diam memakai driver log
+func Init(development bool, log mlog.LoggerAssumer) error {
+	from := os.Getenv(fromKey)
+	if from == "" {
+		from = "no-reply@sagasql.local"
+	}
+
+	name := os.Getenv(driverKey)
+	if name == "" {
+		if !development {
+			return errors.New("MAIL_DRIVER wajib diisi (smtp atau file)")
+		}
+		name = DriverLog
+	}
+
+	switch name {
+	case DriverSMTP:
+		d, err := newSMTPDriver(from)
+		if err != nil {
+			return err
+		}
+		driver = d
+	case DriverFile:
+		d, err := newFileDriver(from)
+		if err != nil {
+			return err
+		}
+		driver = d
+	case DriverLog:
+		if !development {
+			return errors.New("MAIL_DRIVER log hanya dapat digunakan pada APP_ENV development")
+		}
+		driver = &logDriver{from: from, log: log}
+	default:
+		return fmt.Errorf("MAIL_DRIVER %s tidak didukung, gunakan smtp, file atau log", name)
+	}
+	return nil
+}
+
+// logDriver menulis email ke log, hanya untuk pengembangan lokal karena isi email ikut tercatat
+type logDriver struct {
+	from string
+	log  mlog.LoggerAssumer
+}
+
+func (l *logDriver) Send(ctx context.Context, msg Message) error {
+	l.log.Info(ctx, "email (driver log)",
+		mlog.String("from", l.from),
+		mlog.String("to", msg.To),
+		mlog.String("subject", msg.Subject),
+		mlog.String("body", msg.Body),
+	)
+	return nil
+}
@@ -0,0 +1,67 @@
+package mmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	smtpHostKey     = "MAIL_SMTP_HOST"
+	smtpPortKey     = "MAIL_SMTP_PORT"
+	smtpUsernameKey = "MAIL_SMTP_USERNAME"
+	smtpPasswordKey = "MAIL_SMTP_PASSWORD"
+)
+
+// smtpDriver mengirim email melalui server smtp, STARTTLS digunakan otomatis jika didukung server
+type smtpDriver struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+func newSMTPDriver(from string) (*smtpDriver, error) {
+	host := os.Getenv(smtpHostKey)
+	if host == "" {
+		return nil, errors.New("host smtp tidak boleh kosong, ENV : MAIL_SMTP_HOST")
+	}
+	port := os.Getenv(smtpPortKey)
+	if port == "" {
+		port = "587"
+	}
+
+	d := &smtpDriver{
+		from: from,
+		addr: net.JoinHostPort(host, port),
+	}
+	if username := os.Getenv(smtpUsernameKey); username != "" {
+		d.auth = smtp.PlainAuth("", username, os.Getenv(smtpPasswordKey), host)
+	}
+	return d, nil
+}
+
+func (s *smtpDriver) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, buildMessage(s.from, msg))
+}
+
+// buildMessage menyusun email teks biasa sesuai RFC 5322
+func buildMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,105 @@
+package mmetric
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+const namespace = "sagasql"
+
+// Label hasil login
+const (
+	LoginSuccess = "success"
+	LoginFailed  = "failed"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Jumlah request HTTP berdasarkan route template, method dan status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Durasi request HTTP berdasarkan route template dan method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "dao_query_duration_seconds",
+		Help:      "Durasi query database per method dao.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"dao", "method"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Jumlah percobaan login berdasarkan hasilnya.",
+	}, []string{"result"})
+
+	tokenRefreshes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "Jumlah access token yang diterbitkan melalui refresh token.",
+	})
+
+	imageUploads = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_uploads_total",
+		Help:      "Jumlah gambar yang berhasil diupload.",
+	})
+
+	// Registry menyimpan semua metric aplikasi, dipisah dari default registry
+	// agar hanya metric yang didaftarkan disini yang diexpose
+	Registry = prometheus.NewRegistry()
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		queryDuration,
+		logins,
+		tokenRefreshes,
+		imageUploads,
+	)
+}
+
+// RegisterPool mendaftarkan statistik pgxpool ke Registry
+func RegisterPool(pool *pgxpool.Pool) {
+	Registry.MustRegister(newPoolCollector(pool))
+}
+
+// ObserveHTTP mencatat satu request HTTP yang sudah selesai
+func ObserveHTTP(route string, method string, status int, start time.Time) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveQuery mencatat durasi query, dipanggil menggunakan defer di awal method dao
+// contoh : defer mmetric.ObserveQuery("product", "Get", time.Now())
+func ObserveQuery(dao string, method string, start time.Time) {
+	queryDuration.WithLabelValues(dao, method).Observe(time.Since(start).Seconds())
+}
+
+// IncLogin menambah counter login dengan result LoginSuccess atau LoginFailed
+func IncLogin(result string) {
+	logins.WithLabelValues(result).Inc()
+}
+
+// IncTokenRefresh menambah counter refresh token
+func IncTokenRefresh() {
+	tokenRefreshes.Inc()
+}
+
+// IncImageUpload menambah counter upload gambar
+func IncImageUpload() {
+	imageUploads.Inc()
+}
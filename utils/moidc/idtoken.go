@@ -0,0 +1,203 @@
+package moidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval jeda minimal membaca ulang jwks provider ketika menemukan kid yang belum dikenal
+const jwksRefreshInterval = time.Minute
+
+// supportedAlgs algoritma id token yang diterima, HS256 dan none ditolak
+var supportedAlgs = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// VerifyIDToken memverifikasi tanda tangan, issuer, audience, exp dan nonce id token
+func (p *provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*IDToken, error) {
+	if _, err := p.load(ctx); err != nil {
+		return nil, err
+	}
+
+	parser := jwt.Parser{ValidMethods: supportedAlgs}
+	token, err := parser.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.find(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("id token tidak valid: %w", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("id token tidak valid")
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != config.Issuer {
+		return nil, fmt.Errorf("issuer id token %s tidak dikenal", iss)
+	}
+	if !claims.VerifyAudience(config.ClientID, true) {
+		return nil, errors.New("audience id token tidak sesuai")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != config.ClientID {
+		return nil, errors.New("authorized party id token tidak sesuai")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id token tidak memiliki exp")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("nonce id token tidak sesuai")
+	}
+
+	idToken := IDToken{Claims: claims}
+	idToken.Issuer, _ = claims["iss"].(string)
+	idToken.Subject, _ = claims["sub"].(string)
+	idToken.Email, _ = claims["email"].(string)
+	idToken.Name, _ = claims["name"].(string)
+	idToken.PreferredUsername, _ = claims["preferred_username"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		idToken.EmailVerified = verified
+	case string:
+		// beberapa provider mengirim email_verified sebagai string
+		idToken.EmailVerified = verified == "true"
+	}
+	if idToken.Subject == "" {
+		return nil, errors.New("id token tidak memiliki sub")
+	}
+	return &idToken, nil
+}
+
+// keySet cache public key provider berdasarkan kid
+type keySet struct {
+	uri    string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	refreshedAt time.Time
+}
+
+func newKeySet(uri string, client *http.Client) *keySet {
+	return &keySet{
+		uri:    uri,
+		client: client,
+		keys:   map[string]crypto.PublicKey{},
+	}
+}
+
+// find mencari public key, jwks dibaca ulang jika kid belum dikenal (provider melakukan rotasi kunci)
+func (k *keySet) find(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(k.refreshedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("kunci %s tidak ditemukan pada jwks provider", kid)
+	}
+	if err := k.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := k.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("kunci %s tidak ditemukan pada jwks provider", kid)
+}
+
+// lookup jika token tidak memiliki kid dan provider hanya memiliki satu kunci, kunci tersebut yang digunakan
+func (k *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *keySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.uri, nil)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := doJSON(k.client, req, &set); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		key, err := raw.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[raw.Kid] = key
+	}
+	k.keys = keys
+	k.refreshedAt = time.Now()
+	return nil
+}
+
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curve %s tidak didukung", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("tipe kunci %s tidak didukung", j.Kty)
+}
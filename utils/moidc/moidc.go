@@ -0,0 +1,236 @@
+package moidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	issuerKey       = "OIDC_ISSUER"
+	clientIDKey     = "OIDC_CLIENT_ID"
+	clientSecretKey = "OIDC_CLIENT_SECRET"
+	redirectURLKey  = "OIDC_REDIRECT_URL"
+	scopesKey       = "OIDC_SCOPES"
+
+	defaultScopes = "openid email profile"
+	httpTimeout   = 10 * time.Second
+)
+
+// ErrDisabled dikembalikan jika OIDC_ISSUER tidak diisi
+var ErrDisabled = errors.New("login OIDC tidak diaktifkan")
+
+// Config koneksi ke identity provider, dibaca dari env melalui Init
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Token response token endpoint yang digunakan
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// IDToken isi id token yang sudah diverifikasi, Claims berisi seluruh claim untuk pemetaan role
+type IDToken struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Claims            map[string]interface{}
+}
+
+// ProviderAssumer client OpenID Connect untuk authorization code flow dengan PKCE
+type ProviderAssumer interface {
+	Enabled() bool
+	Issuer() string
+	AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code string, codeVerifier string) (*Token, error)
+	VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*IDToken, error)
+}
+
+// config diisi melalui Init setelah env diload
+var config *Config
+
+// Init membaca konfigurasi dari env, OIDC dinonaktifkan jika OIDC_ISSUER kosong.
+// discovery document baru dibaca ketika login pertama sehingga aplikasi tetap berjalan walaupun provider belum tersedia
+func Init() error {
+	issuer := strings.TrimSuffix(os.Getenv(issuerKey), "/")
+	if issuer == "" {
+		config = nil
+		return nil
+	}
+
+	cfg := Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv(clientIDKey),
+		ClientSecret: os.Getenv(clientSecretKey),
+		RedirectURL:  os.Getenv(redirectURLKey),
+		Scopes:       strings.Fields(os.Getenv(scopesKey)),
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return fmt.Errorf("%s dan %s wajib diisi jika %s diisi", clientIDKey, redirectURLKey, issuerKey)
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = strings.Fields(defaultScopes)
+	}
+	config = &cfg
+	return nil
+}
+
+func NewProvider() ProviderAssumer {
+	return &provider{
+		client: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+type provider struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+// discovery bagian dari /.well-known/openid-configuration yang digunakan
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (p *provider) Enabled() bool {
+	return config != nil
+}
+
+func (p *provider) Issuer() string {
+	if config == nil {
+		return ""
+	}
+	return config.Issuer
+}
+
+// AuthCodeURL alamat halaman login provider, code challenge menggunakan metode S256
+func (p *provider) AuthCodeURL(ctx context.Context, state string, nonce string, codeChallenge string) (string, error) {
+	d, err := p.load(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", config.RedirectURL)
+	query.Set("scope", strings.Join(config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange menukar authorization code dengan token, client secret dikirim jika diisi (confidential client)
+func (p *provider) Exchange(ctx context.Context, code string, codeVerifier string) (*Token, error) {
+	d, err := p.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", config.RedirectURL)
+	form.Set("client_id", config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	var token Token
+	if err := doJSON(p.client, req, &token); err != nil {
+		return nil, fmt.Errorf("token endpoint: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token endpoint tidak mengembalikan id_token")
+	}
+	return &token, nil
+}
+
+// load membaca discovery document sekali lalu disimpan, dicoba ulang pada pemanggilan berikutnya jika gagal
+func (p *provider) load(ctx context.Context) (*discovery, error) {
+	if config == nil {
+		return nil, ErrDisabled
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	if err := doJSON(p.client, req, &d); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != config.Issuer {
+		return nil, fmt.Errorf("issuer discovery %s tidak sama dengan %s", d.Issuer, config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery document tidak lengkap")
+	}
+
+	p.discovery = &d
+	p.keys = newKeySet(d.JWKSURI, p.client)
+	return p.discovery, nil
+}
+
+// doJSON menjalankan request dan decode response json, status selain 2xx dianggap error
+func doJSON(client *http.Client, req *http.Request, target interface{}) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("status %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, target)
+}
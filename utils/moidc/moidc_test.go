@@ -0,0 +1,257 @@
+package moidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "sagasql"
+	testKID      = "kunci-1"
+)
+
+// mockProvider identity provider tiruan dengan discovery, jwks dan token endpoint.
+// token endpoint memeriksa code_verifier terhadap code_challenge yang didaftarkan untuk code tersebut
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu         sync.Mutex
+	challenges map[string]string
+	idToken    string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{key: key, challenges: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": testKID,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		m.mu.Lock()
+		challenge, ok := m.challenges[r.PostForm.Get("code")]
+		idToken := m.idToken
+		m.mu.Unlock()
+		if !ok || r.PostForm.Get("grant_type") != "authorization_code" || CodeChallenge(r.PostForm.Get("code_verifier")) != challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "akses", "token_type": "Bearer", "id_token": idToken, "expires_in": 300})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	previous := config
+	config = &Config{
+		Issuer:      m.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:3500/api/v1/oidc/callback",
+		Scopes:      strings.Fields(defaultScopes),
+	}
+	t.Cleanup(func() { config = previous })
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// claims id token valid untuk nonce, dapat diubah oleh masing-masing kasus test
+func (m *mockProvider) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "12345",
+		"aud":            testClientID,
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "budi@example.com",
+		"email_verified": true,
+	}
+}
+
+func (m *mockProvider) sign(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims, kid string, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestCodeChallengeRFC7636(t *testing.T) {
+	// contoh dari RFC 7636 lampiran B
+	if got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("CodeChallenge = %s", got)
+	}
+}
+
+func TestRandomString(t *testing.T) {
+	first, err := RandomString(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RandomString(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || len(first) != 43 {
+		t.Fatalf("string acak tidak valid: %s, %s", first, second)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+
+	raw, err := NewProvider().AuthCodeURL(context.Background(), "state-1", "nonce-1", CodeChallenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, m.server.URL+"/authorize?") {
+		t.Fatalf("endpoint tidak sesuai: %s", raw)
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+		"scope":                 defaultScopes,
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestExchangePKCE(t *testing.T) {
+	m := newMockProvider(t)
+	verifier, err := RandomString(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.challenges["code-1"] = CodeChallenge(verifier)
+	m.idToken = m.sign(t, jwt.SigningMethodRS256, m.claims("nonce-1"), testKID, m.key)
+
+	p := NewProvider()
+	token, err := p.Exchange(context.Background(), "code-1", verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	idToken, err := p.VerifyIDToken(context.Background(), token.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if idToken.Subject != "12345" || idToken.Email != "budi@example.com" || !idToken.EmailVerified {
+		t.Fatalf("id token tidak sesuai: %+v", idToken)
+	}
+
+	if _, err := p.Exchange(context.Background(), "code-1", "verifier-lain"); err == nil {
+		t.Fatal("code verifier yang salah seharusnya ditolak")
+	}
+	if _, err := p.Exchange(context.Background(), "code-lain", verifier); err == nil {
+		t.Fatal("code yang tidak dikenal seharusnya ditolak")
+	}
+}
+
+func TestVerifyIDTokenRejected(t *testing.T) {
+	m := newMockProvider(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// public key provider dalam bentuk bytes, dapat diketahui siapa saja melalui jwks
+	publicKey := m.key.PublicKey.N.Bytes()
+
+	with := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		claims := m.claims("nonce-1")
+		change(claims)
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"issuer lain", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["iss"] = "https://penyerang.example.com" }), testKID, m.key)},
+		{"audience lain", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["aud"] = "client-lain" }), testKID, m.key)},
+		{"tanpa audience", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "aud") }), testKID, m.key)},
+		{"authorized party lain", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["azp"] = "client-lain" }), testKID, m.key)},
+		{"nonce lain", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["nonce"] = "nonce-2" }), testKID, m.key)},
+		{"tanpa nonce", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "nonce") }), testKID, m.key)},
+		{"kadaluarsa", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }), testKID, m.key)},
+		{"tanpa exp", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "exp") }), testKID, m.key)},
+		{"tanpa sub", m.sign(t, jwt.SigningMethodRS256, with(func(c jwt.MapClaims) { delete(c, "sub") }), testKID, m.key)},
+		{"ditandatangani kunci lain", m.sign(t, jwt.SigningMethodRS256, m.claims("nonce-1"), testKID, otherKey)},
+		{"kid tidak dikenal", m.sign(t, jwt.SigningMethodRS256, m.claims("nonce-1"), "kunci-lain", otherKey)},
+		{"alg HS256 dengan public key", m.sign(t, jwt.SigningMethodHS256, m.claims("nonce-1"), testKID, publicKey)},
+		{"alg none", m.sign(t, jwt.SigningMethodNone, m.claims("nonce-1"), testKID, jwt.UnsafeAllowNoneSignatureType)},
+	}
+	p := NewProvider()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.VerifyIDToken(context.Background(), tt.token, "nonce-1"); err == nil {
+				t.Fatal("id token seharusnya ditolak")
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	config.Issuer = m.server.URL + "/lain"
+
+	if _, err := NewProvider().AuthCodeURL(context.Background(), "state", "nonce", CodeChallenge("verifier")); err == nil {
+		t.Fatal("discovery dengan issuer berbeda seharusnya ditolak")
+	}
+}
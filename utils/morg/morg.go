@@ -0,0 +1,18 @@
+package morg
+
+import (
+	"context"
+)
+
+type orgIDKey struct{}
+
+// ContextWithOrgID menyimpan organisasi aktif kedalam ctx, diisi oleh middleware auth dari token
+func ContextWithOrgID(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgIDKey{}, orgID)
+}
+
+// OrgIDFromContext mengembalikan organisasi aktif di dalam ctx, string kosong jika tidak ada
+func OrgIDFromContext(ctx context.Context) string {
+	orgID, _ := ctx.Value(orgIDKey{}).(string)
+	return orgID
+}
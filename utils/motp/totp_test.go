@@ -0,0 +1,112 @@
+package motp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret secret ascii "12345678901234567890" dari RFC 6238 lampiran B dalam bentuk base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// nilai 8 digit dari RFC 6238 (SHA1), kode 6 digit adalah 6 digit terakhirnya
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfc6238Secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.want[len(tt.want)-Digits:]; code != want {
+			t.Errorf("Code pada %d = %s, want %s", tt.unix, code, want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	upper, err := Code(rfc6238Secret, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, err := Code(" "+strings.ToLower(rfc6238Secret)+" ", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upper != lower {
+		t.Fatalf("kode berbeda untuk secret huruf kecil: %s != %s", lower, upper)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("bukan-base32!", 1); err == nil {
+		t.Fatal("secret tidak valid seharusnya error")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	for offset := int64(-Skew - 1); offset <= Skew+1; offset++ {
+		code, err := Code(rfc6238Secret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfc6238Secret, code, now)
+		inWindow := offset >= -Skew && offset <= Skew
+		if ok != inWindow {
+			t.Fatalf("offset %d: valid = %v, want %v", offset, ok, inWindow)
+		}
+		// step yang dikembalikan dipakai pemanggil untuk menolak kode yang sama dipakai dua kali
+		if ok && step != current+offset {
+			t.Fatalf("offset %d: step = %d, want %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := Code(rfc6238Secret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{"", code[:Digits-1], code + "0", "abcdef"} {
+		if _, ok := Validate(rfc6238Secret, input, now); ok {
+			t.Errorf("kode %q seharusnya ditolak", input)
+		}
+	}
+	if _, ok := Validate(rfc6238Secret, " "+code+" ", now); !ok {
+		t.Error("spasi di sekitar kode seharusnya diabaikan")
+	}
+	if _, ok := Validate("bukan-base32!", code, now); ok {
+		t.Error("secret tidak valid seharusnya ditolak")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	first, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("secret acak seharusnya berbeda")
+	}
+	if _, err := encoding.DecodeString(first); err != nil || len(first) != 32 {
+		t.Fatalf("secret %q bukan base32 160 bit: %v", first, err)
+	}
+}
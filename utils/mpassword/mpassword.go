@@ -0,0 +1,231 @@
+package mpassword
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"github.com/muchlist/sagasql/utils/mi18n"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy aturan kekuatan password.
+// MinClasses jumlah jenis karakter minimal (huruf kecil, huruf besar, angka, simbol),
+// password sepanjang PassphraseLength atau lebih tidak wajib memenuhi MinClasses agar passphrase panjang tetap dapat digunakan.
+// ForbidUserInfo menolak password yang mengandung username atau email, Blocklist menolak password umum / bocor.
+// MaxBytes batas panjang dalam byte (karakter non ascii lebih dari 1 byte), diisi 72 ketika hasher bcrypt digunakan
+type Policy struct {
+	MinLength        int
+	MaxLength        int
+	MaxBytes         int
+	MinClasses       int
+	PassphraseLength int
+	MinUniqueChars   int
+	ForbidUserInfo   bool
+	Blocklist        bool
+}
+
+// DefaultPolicy mengikuti NIST SP 800-63B, panjang lebih diutamakan daripada kombinasi karakter
+var DefaultPolicy = Policy{
+	MinLength:        8,
+	MaxLength:        128,
+	MinClasses:       2,
+	PassphraseLength: 16,
+	MinUniqueChars:   5,
+	ForbidUserInfo:   true,
+	Blocklist:        true,
+}
+
+// policy yang digunakan oleh Validate, diganti melalui SetPolicy ketika aplikasi dijalankan
+var policy = DefaultPolicy
+
+// SetPolicy mengganti policy yang digunakan oleh Validate
+func SetPolicy(p Policy) {
+	policy = p
+}
+
+// CurrentPolicy policy yang sedang digunakan
+func CurrentPolicy() Policy {
+	return policy
+}
+
+// blocklistFileKey env path file daftar password bocor tambahan (satu password per baris),
+// contoh daftar top 100.000 dari SecLists atau Have I Been Pwned
+const blocklistFileKey = "PASSWORD_BLOCKLIST_FILE"
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords berisi daftar minimal yang di-embed ke binary,
+// daftar yang lebih lengkap ditambahkan dari PASSWORD_BLOCKLIST_FILE melalui Init
+var commonPasswords = parseList(commonPasswordsFile)
+
+func parseList(raw string) map[string]struct{} {
+	list := make(map[string]struct{})
+	for _, line := range strings.Split(raw, "\n") {
+		addToList(list, line)
+	}
+	return list
+}
+
+func addToList(list map[string]struct{}, line string) {
+	line = strings.ToLower(strings.TrimSpace(line))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	list[line] = struct{}{}
+}
+
+// Init menambahkan isi file PASSWORD_BLOCKLIST_FILE ke blocklist, harus dipanggil setelah env diload
+// dan sebelum request pertama. error jika env diisi namun file tidak dapat dibaca
+func Init() error {
+	path := os.Getenv(blocklistFileKey)
+	if path == "" {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s tidak dapat dibuka: %w", blocklistFileKey, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		addToList(commonPasswords, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s tidak dapat dibaca: %w", blocklistFileKey, err)
+	}
+	return nil
+}
+
+// BlocklistSize jumlah password pada blocklist
+func BlocklistSize() int {
+	return len(commonPasswords)
+}
+
+// Violation alasan password ditolak, Code dapat digunakan client untuk menampilkan pesan sendiri.
+// pesannya diambil dari catalog mi18n berdasarkan Key dan Params
+type Violation struct {
+	code   string
+	key    string
+	params map[string]interface{}
+}
+
+func (v *Violation) Code() string {
+	return v.code
+}
+
+func (v *Violation) Key() string {
+	return v.key
+}
+
+func (v *Violation) Params() map[string]interface{} {
+	return v.params
+}
+
+func (v *Violation) Error() string {
+	return mi18n.TranslateParams(mi18n.DefaultLang, v.key, v.params)
+}
+
+// Validate memeriksa password terhadap policy, userInputs berisi username, email atau nama user
+// yang tidak boleh menjadi bagian dari password. mengembalikan *Violation pertama yang ditemukan
+func Validate(password string, userInputs ...string) error {
+	length := utf8.RuneCountInString(password)
+	if policy.MinLength > 0 && length < policy.MinLength {
+		return &Violation{code: "password_too_short", key: "password.too_short", params: map[string]interface{}{"min": policy.MinLength}}
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		return &Violation{code: "password_too_long", key: "password.too_long", params: map[string]interface{}{"max": policy.MaxLength}}
+	}
+	if policy.MaxBytes > 0 && len(password) > policy.MaxBytes {
+		return &Violation{code: "password_too_long", key: "password.too_long_bytes", params: map[string]interface{}{"max": policy.MaxBytes}}
+	}
+
+	if policy.MinClasses > 0 && (policy.PassphraseLength == 0 || length < policy.PassphraseLength) {
+		if characterClasses(password) < policy.MinClasses {
+			return &Violation{code: "password_too_simple", key: "password.too_few_classes", params: map[string]interface{}{
+				"classes":    policy.MinClasses,
+				"passphrase": policy.PassphraseLength,
+			}}
+		}
+	}
+	if policy.MinUniqueChars > 0 && uniqueChars(password) < policy.MinUniqueChars {
+		return &Violation{code: "password_too_simple", key: "password.too_few_unique", params: map[string]interface{}{"unique": policy.MinUniqueChars}}
+	}
+
+	lower := strings.ToLower(password)
+	if policy.ForbidUserInfo {
+		for _, input := range userInfoParts(userInputs) {
+			if strings.Contains(lower, input) {
+				return &Violation{code: "password_contains_user_info", key: "password.user_info"}
+			}
+		}
+	}
+	if policy.Blocklist && isCommon(lower) {
+		return &Violation{code: "password_common", key: "password.common"}
+	}
+	return nil
+}
+
+// isCommon mencocokkan password dengan blocklist, termasuk setelah akhiran angka dan simbol dibuang
+func isCommon(lower string) bool {
+	if _, ok := commonPasswords[lower]; ok {
+		return true
+	}
+	base := strings.TrimRightFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if base == "" || base == lower {
+		return false
+	}
+	_, ok := commonPasswords[base]
+	return ok
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+func uniqueChars(password string) int {
+	seen := make(map[rune]struct{})
+	for _, r := range password {
+		seen[r] = struct{}{}
+	}
+	return len(seen)
+}
+
+// userInfoParts username dan email beserta bagian lokal email, bagian yang kurang dari 3 karakter diabaikan
+func userInfoParts(userInputs []string) []string {
+	parts := make([]string, 0, len(userInputs)*2)
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if at := strings.Index(input, "@"); at > 0 {
+			parts = append(parts, input[:at])
+		}
+		parts = append(parts, input)
+	}
+
+	result := parts[:0]
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= 3 {
+			result = append(result, part)
+		}
+	}
+	return result
+}
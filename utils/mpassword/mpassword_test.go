@@ -0,0 +1,145 @@
+package mpassword
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// usePolicy mengganti policy selama test berjalan
+func usePolicy(t *testing.T, p Policy) {
+	t.Helper()
+	previous := CurrentPolicy()
+	SetPolicy(p)
+	t.Cleanup(func() { SetPolicy(previous) })
+}
+
+// keepBlocklist mengembalikan blocklist seperti semula setelah test menambahkan isi file
+func keepBlocklist(t *testing.T) {
+	t.Helper()
+	previous := make(map[string]struct{}, len(commonPasswords))
+	for password := range commonPasswords {
+		previous[password] = struct{}{}
+	}
+	t.Cleanup(func() { commonPasswords = previous })
+}
+
+func TestValidate(t *testing.T) {
+	bcryptPolicy := DefaultPolicy
+	bcryptPolicy.MaxBytes = 72
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		password   string
+		userInputs []string
+		wantKey    string
+	}{
+		{"valid", DefaultPolicy, "Kuda-Laut-42", nil, ""},
+		{"passphrase tanpa kombinasi karakter", DefaultPolicy, "kudalautberenangjauh", nil, ""},
+		{"terlalu pendek", DefaultPolicy, "Ab1!", nil, "password.too_short"},
+		{"panjang dihitung per karakter", DefaultPolicy, "Ééééé1", nil, "password.too_short"},
+		{"terlalu panjang", DefaultPolicy, "Ab1" + strings.Repeat("x", 126), nil, "password.too_long"},
+		{"melebihi batas byte bcrypt", bcryptPolicy, "A1" + strings.Repeat("é", 40), nil, "password.too_long_bytes"},
+		{"ascii 72 byte dengan batas bcrypt", bcryptPolicy, "Ab1-" + strings.Repeat("kuda", 17), nil, ""},
+		{"satu jenis karakter", DefaultPolicy, "kudalaut", nil, "password.too_few_classes"},
+		{"karakter berbeda terlalu sedikit", DefaultPolicy, "abababababababababab", nil, "password.too_few_unique"},
+		{"mengandung username", DefaultPolicy, "Budi2024!x", []string{"budi"}, "password.user_info"},
+		{"mengandung bagian lokal email", DefaultPolicy, "Santoso-Kuda9", []string{"santoso@example.com"}, "password.user_info"},
+		{"user input pendek diabaikan", DefaultPolicy, "Kab12345xyz", []string{"ab"}, ""},
+		{"password umum", DefaultPolicy, "ILoveYou", nil, "password.common"},
+		{"password umum dengan akhiran angka dan simbol", DefaultPolicy, "Password123!", nil, "password.common"},
+		{"password umum dengan huruf besar", DefaultPolicy, "QWERTY123", nil, "password.common"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePolicy(t, tt.policy)
+			err := Validate(tt.password, tt.userInputs...)
+			if tt.wantKey == "" {
+				if err != nil {
+					t.Fatalf("password seharusnya diterima: %v", err)
+				}
+				return
+			}
+			violation, ok := err.(*Violation)
+			if !ok {
+				t.Fatalf("err = %v, want *Violation %s", err, tt.wantKey)
+			}
+			if violation.Key() != tt.wantKey {
+				t.Fatalf("key = %s, want %s", violation.Key(), tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestViolationMessage(t *testing.T) {
+	usePolicy(t, DefaultPolicy)
+
+	err := Validate("Ab1!")
+	violation, ok := err.(*Violation)
+	if !ok {
+		t.Fatalf("err = %v, want *Violation", err)
+	}
+	if violation.Code() != "password_too_short" || violation.Params()["min"] != DefaultPolicy.MinLength {
+		t.Fatalf("violation tidak sesuai: %s %v", violation.Code(), violation.Params())
+	}
+	// pesan diterjemahkan dari catalog beserta parameternya, bukan key mentah
+	if message := err.Error(); message == violation.Key() || !strings.Contains(message, "8") {
+		t.Fatalf("pesan tidak diterjemahkan: %s", message)
+	}
+}
+
+func TestPolicyDisabledRules(t *testing.T) {
+	usePolicy(t, Policy{MinLength: 4})
+
+	for _, password := range []string{"aaaa", "password", "budi1234"} {
+		if err := Validate(password, "budi"); err != nil {
+			t.Errorf("%s seharusnya diterima ketika aturan dimatikan: %v", password, err)
+		}
+	}
+}
+
+func TestInitLoadsBlocklistFile(t *testing.T) {
+	usePolicy(t, DefaultPolicy)
+	keepBlocklist(t)
+
+	if err := Validate("Kudalautbiru"); err != nil {
+		t.Fatalf("password seharusnya diterima sebelum blocklist dimuat: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	content := "# daftar tambahan\n\n  KudaLautBiru  \nzebrakuning\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	before := BlocklistSize()
+	t.Setenv(blocklistFileKey, path)
+	if err := Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	if got := BlocklistSize(); got != before+2 {
+		t.Fatalf("BlocklistSize = %d, want %d", got, before+2)
+	}
+	if violation, ok := Validate("Kudalautbiru").(*Violation); !ok || violation.Key() != "password.common" {
+		t.Fatal("password dari file blocklist seharusnya ditolak")
+	}
+	if _, ok := commonPasswords["# daftar tambahan"]; ok {
+		t.Fatal("baris komentar seharusnya diabaikan")
+	}
+}
+
+func TestInitBlocklistFileMissing(t *testing.T) {
+	keepBlocklist(t)
+
+	t.Setenv(blocklistFileKey, "")
+	if err := Init(); err != nil {
+		t.Fatalf("env kosong seharusnya diabaikan: %v", err)
+	}
+
+	t.Setenv(blocklistFileKey, filepath.Join(t.TempDir(), "tidak-ada.txt"))
+	if err := Init(); err == nil {
+		t.Fatal("file yang tidak ada seharusnya error")
+	}
+}
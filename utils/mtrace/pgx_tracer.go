@@ -0,0 +1,56 @@
+package mtrace
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// PgxTracer dipasang sebagai pgx.Logger pada ConnConfig. pgx v4 memanggil logger
+// setiap kali query selesai beserta ctx dan durasinya, sehingga span dibuat
+// mundur dari waktu mulai query sebagai child dari span yang ada di ctx
+type PgxTracer struct{}
+
+func NewPgxTracer() *PgxTracer {
+	return &PgxTracer{}
+}
+
+func (t *PgxTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	sql, ok := data["sql"].(string)
+	if !ok {
+		return
+	}
+
+	end := time.Now()
+	start := end
+	if elapsed, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-elapsed)
+	}
+
+	_, span := otel.Tracer(tracerName).Start(ctx, "pgx."+msg,
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatementKey.String(sql),
+		),
+	)
+
+	if rowCount, ok := data["rowCount"].(int); ok {
+		span.SetAttributes(attribute.Int("db.rows_affected", rowCount))
+	}
+	if level <= pgx.LogLevelError {
+		if err, ok := data["err"].(error); ok {
+			RecordError(span, err)
+		} else {
+			span.SetStatus(codes.Error, fmt.Sprint(data["err"]))
+		}
+	}
+	span.End(trace.WithTimestamp(end))
+}
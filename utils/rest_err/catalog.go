@@ -0,0 +1,149 @@
+package rest_err
+
+import (
+	"errors"
+	"github.com/muchlist/sagasql/utils/mi18n"
+	"net/http"
+	"strings"
+)
+
+// Kode error yang dikirim pada field code, client dapat mengandalkan nilai ini (tidak berubah antar versi)
+// sedangkan message dapat berubah sewaktu-waktu
+const (
+	CodeBadRequest             = "bad_request"
+	CodeValidationFailed       = "validation_failed"
+	CodeInvalidID              = "invalid_id"
+	CodeUnauthorized           = "unauthorized"
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeAccessTokenRequired    = "access_token_required"
+	CodeTokenRevoked           = "token_revoked"
+	CodeFreshTokenRequired     = "fresh_token_required"
+	CodeForbidden              = "forbidden"
+	CodePermissionDenied       = "permission_denied"
+	CodePasswordChangeRequired = "password_change_required"
+	CodeEmailUnverified        = "email_unverified"
+	CodeOrgRequired            = "org_required"
+	CodeNotFound               = "not_found"
+	CodeAlreadyExists          = "already_exists"
+	CodeReferenceViolation     = "reference_violation"
+	CodeFieldRequired          = "field_required"
+	CodeCheckViolation         = "check_violation"
+	CodeValueTooLong           = "value_too_long"
+	CodeTransactionConflict    = "transaction_conflict"
+	CodeRouteNotFound          = "route_not_found"
+	CodeMethodNotAllowed       = "method_not_allowed"
+	CodeTooManyRequests        = "too_many_requests"
+	CodeInternal               = "internal_server_error"
+)
+
+// DomainError error domain dengan status dan kode tetap. dapat dikembalikan langsung oleh handler
+// atau dibungkus dengan fmt.Errorf("%w, keterangan", err) untuk menambah keterangan pada message
+// (keterangan tersebut tidak diterjemahkan, gunakan With agar seluruh message diterjemahkan).
+// nilainya dipakai bersama sehingga diubah menjadi APIError baru melalui From
+type DomainError struct {
+	status int
+	code   string
+	// key pesan pada catalog mi18n
+	key string
+}
+
+func (e *DomainError) Error() string {
+	return mi18n.Translate(mi18n.DefaultLang, e.key)
+}
+
+func (e *DomainError) Status() int {
+	return e.status
+}
+
+func (e *DomainError) Code() string {
+	return e.code
+}
+
+// With membuat APIError dengan status dan kode e namun message dari key catalog lain beserta argumennya,
+// contoh ErrPermissionDenied.With("error.permission_required", "product:delete")
+func (e *DomainError) With(key string, args ...interface{}) APIError {
+	return &apiError{
+		AStatus:  e.status,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, key, args...),
+		AnError:  statusError(e.status),
+		ACode:    e.code,
+		ACauses:  []interface{}{},
+		key:      key,
+		args:     args,
+	}
+}
+
+// Sentinel error domain
+var (
+	ErrInvalidID              = &DomainError{http.StatusBadRequest, CodeInvalidID, "error.invalid_id"}
+	ErrUnauthorized           = &DomainError{http.StatusUnauthorized, CodeUnauthorized, "error.unauthorized"}
+	ErrInvalidCredentials     = &DomainError{http.StatusUnauthorized, CodeInvalidCredentials, "error.invalid_credentials"}
+	ErrAccessTokenRequired    = &DomainError{http.StatusUnauthorized, CodeAccessTokenRequired, "error.access_token_required"}
+	ErrTokenRevoked           = &DomainError{http.StatusUnauthorized, CodeTokenRevoked, "error.token_revoked"}
+	ErrFreshTokenRequired     = &DomainError{http.StatusUnauthorized, CodeFreshTokenRequired, "error.fresh_token_required"}
+	ErrPermissionDenied       = &DomainError{http.StatusForbidden, CodePermissionDenied, "error.permission_denied"}
+	ErrPasswordChangeRequired = &DomainError{http.StatusForbidden, CodePasswordChangeRequired, "error.password_change_required"}
+	ErrEmailUnverified        = &DomainError{http.StatusForbidden, CodeEmailUnverified, "error.email_unverified"}
+	ErrOrgRequired            = &DomainError{http.StatusForbidden, CodeOrgRequired, "error.org_required"}
+	ErrRouteNotFound          = &DomainError{http.StatusNotFound, CodeRouteNotFound, "error.route_not_found"}
+	ErrMethodNotAllowed       = &DomainError{http.StatusMethodNotAllowed, CodeMethodNotAllowed, "error.method_not_allowed"}
+)
+
+// From mengubah error apapun menjadi APIError. APIError dikembalikan apa adanya, DomainError (termasuk yang dibungkus)
+// menjadi APIError baru dengan message dari err.Error(), selain itu dianggap error 500
+func From(err error) APIError {
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		if err == domainErr {
+			return domainErr.With(domainErr.key)
+		}
+		return &apiError{
+			AStatus:  domainErr.status,
+			AMessage: err.Error(),
+			AnError:  statusError(domainErr.status),
+			ACode:    domainErr.code,
+			ACauses:  []interface{}{},
+			key:      err.Error(),
+		}
+	}
+
+	return NewInternalServerError("error.internal", err)
+}
+
+// NewStatusError membuat api error dengan field error dan code diturunkan dari status, contoh 413 menjadi request_entity_too_large
+func NewStatusError(message string, status int) APIError {
+	return &apiError{
+		AStatus:  status,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message),
+		key:      message,
+		AnError:  statusError(status),
+		ACode:    statusError(status),
+		ACauses:  []interface{}{},
+	}
+}
+
+// NewCodedError membuat api error dengan code dari catalog, field error diturunkan dari status
+func NewCodedError(message string, status int, code string, causes []interface{}) APIError {
+	return &apiError{
+		AStatus:  status,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message),
+		key:      message,
+		AnError:  statusError(status),
+		ACode:    code,
+		ACauses:  causes,
+	}
+}
+
+// statusError nilai field error berdasarkan status, contoh 404 menjadi not_found dan 503 menjadi service_unavailable
+func statusError(status int) string {
+	text := http.StatusText(status)
+	if text == "" && status >= http.StatusInternalServerError {
+		return "internal_server_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
@@ -0,0 +1,108 @@
+package rest_err
+
+import (
+	"fmt"
+	"github.com/muchlist/sagasql/utils/mi18n"
+	"sort"
+	"strings"
+)
+
+// ParamsError error validasi yang pesannya dapat diterjemahkan melalui key catalog dan params bernama,
+// contoh mpassword.Violation
+type ParamsError interface {
+	Code() string
+	Key() string
+	Params() map[string]interface{}
+}
+
+// ozzoError method yang dimiliki error ozzo-validation (validation.Error), pesan default nya berbahasa inggris
+// sehingga diterjemahkan berdasarkan code kecuali message nya sudah berupa key catalog
+type ozzoError interface {
+	Code() string
+	Message() string
+	Params() map[string]interface{}
+}
+
+// NewFieldError membuat FieldError dari error validasi satu field, message diterjemahkan ke bahasa default
+func NewFieldError(field string, err error) FieldError {
+	fieldError := FieldError{Field: field, Message: err.Error()}
+	switch e := err.(type) {
+	case ParamsError:
+		fieldError.Code = e.Code()
+		fieldError.Key = e.Key()
+		fieldError.Params = e.Params()
+	case ozzoError:
+		fieldError.Code = e.Code()
+		fieldError.Params = e.Params()
+		if mi18n.Has(e.Message()) {
+			fieldError.Key = e.Message()
+		} else if mi18n.Has(e.Code()) {
+			fieldError.Key = e.Code()
+		}
+	case interface{ Code() string }:
+		fieldError.Code = e.Code()
+	}
+	return fieldError.localize(mi18n.DefaultLang)
+}
+
+func (f FieldError) localize(lang string) FieldError {
+	if f.Key != "" {
+		f.Message = mi18n.TranslateParams(lang, f.Key, f.Params)
+	}
+	return f
+}
+
+// JoinFieldErrors menyusun message error validasi dengan format yang sama dengan ozzo-validation,
+// contoh "email: format email tidak valid; name: tidak boleh kosong."
+func JoinFieldErrors(fields []FieldError) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	sorted := make([]FieldError, len(fields))
+	copy(sorted, fields)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Field < sorted[j].Field
+	})
+
+	parts := make([]string, 0, len(sorted))
+	for _, field := range sorted {
+		parts = append(parts, fmt.Sprintf("%s: %s", field.Field, field.Message))
+	}
+	return strings.Join(parts, "; ") + "."
+}
+
+// Localize membuat salinan err dengan message dan rincian field diterjemahkan ke bahasa lang,
+// dipanggil oleh middle.ErrorHandler sesuai bahasa request
+func Localize(err APIError, lang string) APIError {
+	switch e := err.(type) {
+	case *retryAfterError:
+		return &retryAfterError{apiError: e.apiError.localize(lang), retryAfter: e.retryAfter}
+	case *apiError:
+		return e.localize(lang)
+	}
+	return err
+}
+
+func (e *apiError) localize(lang string) *apiError {
+	localized := *e
+	if e.key != "" {
+		localized.AMessage = mi18n.Translate(lang, e.key, e.args...)
+	}
+
+	var fields []FieldError
+	if len(e.ACauses) > 0 {
+		localized.ACauses = make([]interface{}, 0, len(e.ACauses))
+		for _, cause := range e.ACauses {
+			if field, ok := cause.(FieldError); ok {
+				field = field.localize(lang)
+				fields = append(fields, field)
+				cause = field
+			}
+			localized.ACauses = append(localized.ACauses, cause)
+		}
+	}
+	if e.ACode == CodeValidationFailed && len(fields) > 0 {
+		localized.AMessage = JoinFieldErrors(fields)
+	}
+	return &localized
+}
@@ -2,22 +2,34 @@ package rest_err
 
 import (
 	"fmt"
+	"github.com/muchlist/sagasql/utils/mi18n"
 	"net/http"
 )
 
-//APIError interface untuk mengembalikan error
+//APIError interface untuk mengembalikan error.
+// message pada constructor berupa key catalog mi18n (utils/mi18n/locales) beserta argumen format nya,
+// pesan literal yang tidak ada pada catalog tetap ditampilkan apa adanya
 type APIError interface {
 	Message() string
 	Status() int
+	// Code kode error yang stabil untuk dibaca client (lihat catalog.go), lebih spesifik daripada field error
+	Code() string
 	Error() string
 	Causes() []interface{}
+	RequestID() string
+	WithRequestID(requestID string) APIError
 }
 
 type apiError struct {
 	AStatus  int           `json:"status"`
 	AMessage string        `json:"message"`
 	AnError  string        `json:"error"`
+	ACode    string        `json:"code"`
 	ACauses  []interface{} `json:"causes"`
+	AReqID   string        `json:"request_id,omitempty"`
+	// key dan args sumber AMessage pada catalog mi18n, digunakan Localize untuk menerjemahkan ulang sesuai bahasa request
+	key  string
+	args []interface{}
 }
 
 func (e *apiError) Status() int {
@@ -28,6 +40,10 @@ func (e *apiError) Message() string {
 	return e.AMessage
 }
 
+func (e *apiError) Code() string {
+	return e.ACode
+}
+
 func (e *apiError) Error() string {
 	return fmt.Sprintf("message: %s - status: %d - error: %s - causes: [ %v ]",
 		e.Message(), e.Status(), e.AnError, e.ACauses)
@@ -37,32 +53,63 @@ func (e *apiError) Causes() []interface{} {
 	return e.ACauses
 }
 
+func (e *apiError) RequestID() string {
+	return e.AReqID
+}
+
+// WithRequestID menyertakan request id pada response error agar dapat dicocokkan dengan log
+func (e *apiError) WithRequestID(requestID string) APIError {
+	e.AReqID = requestID
+	return e
+}
+
 // NewAPIError membuat api error baru dengan mendifinisikan semua isinyas
 func NewAPIError(message string, statusCode int, err string, causes []interface{}) APIError {
 	return &apiError{
 		AStatus:  statusCode,
-		AMessage: message,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message),
+		key:      message,
 		AnError:  err,
+		ACode:    err,
 		ACauses:  causes,
 	}
 }
 
 // NewNotFoundError membuat api error ketika objek yang dicari tidak ditemukan
-func NewNotFoundError(message string) APIError {
+func NewNotFoundError(message string, args ...interface{}) APIError {
 	return &apiError{
 		AStatus:  http.StatusNotFound,
-		AMessage: message,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message, args...),
+		key:      message,
+		args:     args,
 		AnError:  "not_found",
+		ACode:    CodeNotFound,
 		ACauses:  []interface{}{},
 	}
 }
 
 // NewUnauthorizedError membuat api error user yang tidak diijinkan masuk
-func NewUnauthorizedError(message string) APIError {
+func NewUnauthorizedError(message string, args ...interface{}) APIError {
 	return &apiError{
 		AStatus:  http.StatusUnauthorized,
-		AMessage: message,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message, args...),
+		key:      message,
+		args:     args,
 		AnError:  "unauthorized",
+		ACode:    CodeUnauthorized,
+		ACauses:  []interface{}{},
+	}
+}
+
+// NewForbiddenError membuat api error user yang sudah login namun tidak memiliki hak akses
+func NewForbiddenError(message string, args ...interface{}) APIError {
+	return &apiError{
+		AStatus:  http.StatusForbidden,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message, args...),
+		key:      message,
+		args:     args,
+		AnError:  "forbidden",
+		ACode:    CodeForbidden,
 		ACauses:  []interface{}{},
 	}
 }
@@ -71,8 +118,10 @@ func NewUnauthorizedError(message string) APIError {
 func NewInternalServerError(message string, err error) APIError {
 	result := &apiError{
 		AStatus:  http.StatusInternalServerError,
-		AMessage: message,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message),
+		key:      message,
 		AnError:  "internal_server_error",
+		ACode:    CodeInternal,
 		ACauses:  []interface{}{},
 	}
 	if err != nil {
@@ -82,11 +131,123 @@ func NewInternalServerError(message string, err error) APIError {
 }
 
 // NewBadRequestError membuat error jika kesalahan ada pada user
-func NewBadRequestError(message string) APIError {
+func NewBadRequestError(message string, args ...interface{}) APIError {
 	return &apiError{
 		AStatus:  http.StatusBadRequest,
-		AMessage: message,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message, args...),
+		key:      message,
+		args:     args,
 		AnError:  "bad_request",
+		ACode:    CodeBadRequest,
 		ACauses:  []interface{}{},
 	}
 }
+
+// RetryAfterError api error yang menyertakan waktu tunggu (detik) sebelum request boleh diulang,
+// nilainya dikirim pada header Retry-After
+type RetryAfterError interface {
+	APIError
+	RetryAfter() int64
+}
+
+type retryAfterError struct {
+	*apiError
+	retryAfter int64
+}
+
+func (e *retryAfterError) RetryAfter() int64 {
+	return e.retryAfter
+}
+
+func (e *retryAfterError) WithRequestID(requestID string) APIError {
+	e.AReqID = requestID
+	return e
+}
+
+// NewTooManyRequestsError membuat error 429 ketika request harus ditunda selama retryAfter detik
+func NewTooManyRequestsError(message string, retryAfter int64, args ...interface{}) APIError {
+	return &retryAfterError{
+		apiError: &apiError{
+			AStatus:  http.StatusTooManyRequests,
+			AMessage: mi18n.Translate(mi18n.DefaultLang, message, args...),
+			AnError:  "too_many_requests",
+			ACode:    CodeTooManyRequests,
+			ACauses:  []interface{}{},
+			key:      message,
+			args:     args,
+		},
+		retryAfter: retryAfter,
+	}
+}
+
+// NewRetryableError membuat error sementara (contoh deadlock), request aman untuk diulang setelah retryAfter detik
+func NewRetryableError(message string, statusCode int, code string, retryAfter int64, causes []interface{}) APIError {
+	return &retryAfterError{
+		apiError: &apiError{
+			AStatus:  statusCode,
+			AMessage: mi18n.Translate(mi18n.DefaultLang, message),
+			key:      message,
+			AnError:  statusError(statusCode),
+			ACode:    code,
+			ACauses:  causes,
+		},
+		retryAfter: retryAfter,
+	}
+}
+
+// ErrorReference pengganti causes error internal pada response, nilainya dicatat ke log bersama causes aslinya
+type ErrorReference struct {
+	Reference string `json:"reference"`
+}
+
+// Redact membuat salinan err dengan causes diganti reference, agar rincian error internal
+// (pesan database, nama kolom, alamat koneksi dll) tidak dikirim ke client
+func Redact(err APIError, reference string) APIError {
+	causes := []interface{}{ErrorReference{Reference: reference}}
+	switch e := err.(type) {
+	case *retryAfterError:
+		redacted := *e.apiError
+		redacted.ACauses = causes
+		return &retryAfterError{apiError: &redacted, retryAfter: e.retryAfter}
+	case *apiError:
+		redacted := *e
+		redacted.ACauses = causes
+		return &redacted
+	}
+	return &apiError{
+		AStatus:  err.Status(),
+		AMessage: err.Message(),
+		AnError:  statusError(err.Status()),
+		ACode:    err.Code(),
+		ACauses:  causes,
+		AReqID:   err.RequestID(),
+		key:      err.Message(),
+	}
+}
+
+// FieldError kesalahan input pada satu field, Code diisi jika validator menyediakan kode error.
+// Key dan Params sumber Message pada catalog mi18n jika tersedia, lihat NewFieldError
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code,omitempty"`
+	Message string                 `json:"message"`
+	Key     string                 `json:"-"`
+	Params  map[string]interface{} `json:"-"`
+}
+
+// NewValidationError membuat error 400 dengan rincian per field pada causes,
+// message umumnya JoinFieldErrors(fields) dan disusun ulang ketika diterjemahkan
+func NewValidationError(message string, fields []FieldError) APIError {
+	causes := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		causes = append(causes, field)
+	}
+	return &apiError{
+		AStatus:  http.StatusBadRequest,
+		AMessage: mi18n.Translate(mi18n.DefaultLang, message),
+		key:      message,
+		AnError:  "bad_request",
+		ACode:    CodeValidationFailed,
+		ACauses:  causes,
+	}
+}
@@ -2,26 +2,87 @@ package sql_err
 
 import (
 	"errors"
-	"fmt"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
 	"github.com/muchlist/sagasql/utils/rest_err"
+	"net/http"
+	"strings"
 )
 
+// retryAfterSeconds waktu tunggu yang disarankan sebelum mengulang transaksi yang gagal karena bentrok
+const retryAfterSeconds = 1
+
+// ConstraintCause rincian constraint yang dilanggar, dikirim pada causes
+type ConstraintCause struct {
+	Constraint string `json:"constraint,omitempty"`
+	Field      string `json:"field,omitempty"`
+}
+
 func ParseError(err error) rest_err.APIError {
-	if err == pgx.ErrNoRows {
-		return rest_err.NewBadRequestError(fmt.Sprintf("tidak ada data yang sesuai dengan id yang diberikan"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return rest_err.NewNotFoundError("db.no_rows")
 	}
 
 	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) {
-		switch pgErr.Code {
-		case pgerrcode.UniqueViolation:
-			return rest_err.NewBadRequestError("input yang diberikan mengalami konflik dengan data existing")
-		case pgerrcode.UndefinedColumn:
-			return rest_err.NewInternalServerError("galat pada query database, column tidak tersedia", err)
-		}
-	}
-	return rest_err.NewInternalServerError("galat", err)
+	if !errors.As(err, &pgErr) {
+		return rest_err.NewInternalServerError("db.error", err)
+	}
+
+	switch pgErr.Code {
+	case pgerrcode.UniqueViolation:
+		return constraintError("db.unique_violation",
+			http.StatusConflict, rest_err.CodeAlreadyExists, pgErr)
+	case pgerrcode.ForeignKeyViolation:
+		return constraintError("db.foreign_key_violation",
+			http.StatusConflict, rest_err.CodeReferenceViolation, pgErr)
+	case pgerrcode.NotNullViolation:
+		return constraintError("db.not_null_violation", http.StatusBadRequest, rest_err.CodeFieldRequired, pgErr)
+	case pgerrcode.CheckViolation:
+		return constraintError("db.check_violation", http.StatusBadRequest, rest_err.CodeCheckViolation, pgErr)
+	case pgerrcode.StringDataRightTruncationDataException:
+		return constraintError("db.value_too_long", http.StatusBadRequest, rest_err.CodeValueTooLong, pgErr)
+	case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected:
+		return rest_err.NewRetryableError("db.transaction_conflict",
+			http.StatusServiceUnavailable, rest_err.CodeTransactionConflict, retryAfterSeconds, []interface{}{})
+	case pgerrcode.InsufficientPrivilege:
+		// termasuk pelanggaran policy row level security saat insert atau update
+		return rest_err.From(rest_err.ErrPermissionDenied)
+	case pgerrcode.UndefinedColumn:
+		return rest_err.NewInternalServerError("db.undefined_column", err)
+	}
+	return rest_err.NewInternalServerError("db.error", err)
+}
+
+// constraintError membuat api error dengan nama constraint dan field yang dilanggar pada causes.
+// nilai input pada pgErr.Detail tidak disertakan
+func constraintError(message string, statusCode int, code string, pgErr *pgconn.PgError) rest_err.APIError {
+	causes := []interface{}{}
+	cause := ConstraintCause{
+		Constraint: pgErr.ConstraintName,
+		Field:      constraintField(pgErr),
+	}
+	if cause.Constraint != "" || cause.Field != "" {
+		causes = append(causes, cause)
+	}
+	return rest_err.NewCodedError(message, statusCode, code, causes)
+}
+
+// constraintField nama field yang dilanggar. not null mengisi ColumnName, sedangkan unique dan foreign key
+// hanya menyebutkannya pada detail dengan format Key (field)=(nilai)
+func constraintField(pgErr *pgconn.PgError) string {
+	if pgErr.ColumnName != "" {
+		return pgErr.ColumnName
+	}
+	detail := pgErr.Detail
+	start := strings.Index(detail, "Key (")
+	if start < 0 {
+		return ""
+	}
+	detail = detail[start+len("Key ("):]
+	end := strings.Index(detail, ")=")
+	if end < 0 {
+		return ""
+	}
+	return detail[:end]
 }
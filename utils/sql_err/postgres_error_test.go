@@ -0,0 +1,131 @@
+package sql_err
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/muchlist/sagasql/utils/rest_err"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorStatusAndCode(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"no rows", pgx.ErrNoRows, http.StatusNotFound, rest_err.CodeNotFound},
+		{"no rows dibungkus", fmt.Errorf("query user: %w", pgx.ErrNoRows), http.StatusNotFound, rest_err.CodeNotFound},
+		{"unique", &pgconn.PgError{Code: pgerrcode.UniqueViolation}, http.StatusConflict, rest_err.CodeAlreadyExists},
+		{"foreign key", &pgconn.PgError{Code: pgerrcode.ForeignKeyViolation}, http.StatusConflict, rest_err.CodeReferenceViolation},
+		{"not null", &pgconn.PgError{Code: pgerrcode.NotNullViolation}, http.StatusBadRequest, rest_err.CodeFieldRequired},
+		{"check", &pgconn.PgError{Code: pgerrcode.CheckViolation}, http.StatusBadRequest, rest_err.CodeCheckViolation},
+		{"string terlalu panjang", &pgconn.PgError{Code: pgerrcode.StringDataRightTruncationDataException}, http.StatusBadRequest, rest_err.CodeValueTooLong},
+		{"serialization failure", &pgconn.PgError{Code: pgerrcode.SerializationFailure}, http.StatusServiceUnavailable, rest_err.CodeTransactionConflict},
+		{"deadlock", &pgconn.PgError{Code: pgerrcode.DeadlockDetected}, http.StatusServiceUnavailable, rest_err.CodeTransactionConflict},
+		{"insufficient privilege", &pgconn.PgError{Code: pgerrcode.InsufficientPrivilege}, http.StatusForbidden, rest_err.CodePermissionDenied},
+		{"undefined column", &pgconn.PgError{Code: pgerrcode.UndefinedColumn}, http.StatusInternalServerError, rest_err.CodeInternal},
+		{"sqlstate lain", &pgconn.PgError{Code: pgerrcode.DivisionByZero}, http.StatusInternalServerError, rest_err.CodeInternal},
+		{"pg error dibungkus", fmt.Errorf("insert: %w", &pgconn.PgError{Code: pgerrcode.UniqueViolation}), http.StatusConflict, rest_err.CodeAlreadyExists},
+		{"bukan error database", errors.New("koneksi terputus"), http.StatusInternalServerError, rest_err.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := ParseError(tt.err)
+			if apiErr.Status() != tt.wantStatus || apiErr.Code() != tt.wantCode {
+				t.Fatalf("status %d code %s, want %d %s", apiErr.Status(), apiErr.Code(), tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestParseErrorTransactionConflictIsRetryable(t *testing.T) {
+	for _, code := range []string{pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected} {
+		apiErr := ParseError(&pgconn.PgError{Code: code})
+		retryable, ok := apiErr.(rest_err.RetryAfterError)
+		if !ok {
+			t.Fatalf("%s seharusnya RetryAfterError", code)
+		}
+		if retryable.RetryAfter() != retryAfterSeconds {
+			t.Fatalf("%s RetryAfter = %d, want %d", code, retryable.RetryAfter(), retryAfterSeconds)
+		}
+	}
+
+	if _, ok := ParseError(&pgconn.PgError{Code: pgerrcode.UniqueViolation}).(rest_err.RetryAfterError); ok {
+		t.Fatal("unique violation seharusnya tidak dapat diulang")
+	}
+}
+
+func TestParseErrorConstraintCause(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   *pgconn.PgError
+		want  ConstraintCause
+		empty bool
+	}{
+		{
+			name: "field dari detail unique",
+			err: &pgconn.PgError{
+				Code:           pgerrcode.UniqueViolation,
+				ConstraintName: "users_email_key",
+				Detail:         "Key (email)=(budi@example.com) already exists.",
+			},
+			want: ConstraintCause{Constraint: "users_email_key", Field: "email"},
+		},
+		{
+			name: "field dari detail foreign key",
+			err: &pgconn.PgError{
+				Code:           pgerrcode.ForeignKeyViolation,
+				ConstraintName: "products_org_id_fkey",
+				Detail:         `Key (org_id)=(42) is not present in table "orgs".`,
+			},
+			want: ConstraintCause{Constraint: "products_org_id_fkey", Field: "org_id"},
+		},
+		{
+			name: "field dari column name not null",
+			err:  &pgconn.PgError{Code: pgerrcode.NotNullViolation, ColumnName: "name"},
+			want: ConstraintCause{Field: "name"},
+		},
+		{
+			name: "detail tanpa key",
+			err:  &pgconn.PgError{Code: pgerrcode.CheckViolation, ConstraintName: "products_price_check", Detail: "Failing row contains (1, -5)."},
+			want: ConstraintCause{Constraint: "products_price_check"},
+		},
+		{
+			name:  "tanpa constraint dan field",
+			err:   &pgconn.PgError{Code: pgerrcode.StringDataRightTruncationDataException},
+			empty: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			causes := ParseError(tt.err).Causes()
+			if tt.empty {
+				if len(causes) != 0 {
+					t.Fatalf("causes = %v, want kosong", causes)
+				}
+				return
+			}
+			if len(causes) != 1 || causes[0] != tt.want {
+				t.Fatalf("causes = %v, want %v", causes, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorOmitsDetailValue(t *testing.T) {
+	apiErr := ParseError(&pgconn.PgError{
+		Code:           pgerrcode.UniqueViolation,
+		ConstraintName: "users_email_key",
+		Detail:         "Key (email)=(budi@example.com) already exists.",
+	})
+	// nilai input dari detail postgres tidak boleh bocor ke response
+	if text := fmt.Sprintf("%s %v", apiErr.Message(), apiErr.Causes()); strings.Contains(text, "budi@example.com") {
+		t.Fatalf("response mengandung nilai input: %s", text)
+	}
+}